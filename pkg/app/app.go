@@ -52,6 +52,9 @@ type App struct {
 	watch bool
 
 	contextExtractors map[string]func(context.Context) string
+
+	// +optional
+	commands []*cobra.Command
 }
 
 // RunFunc defines the application's startup callback function.
@@ -144,6 +147,14 @@ func WithWatchConfig() Option {
 	}
 }
 
+// WithCommands adds subcommands to the application, e.g. maintenance tools
+// which should be shipped with the server binary.
+func WithCommands(cmds ...*cobra.Command) Option {
+	return func(app *App) {
+		app.commands = append(app.commands, cmds...)
+	}
+}
+
 func WithLoggerContextExtractor(contextExtractors map[string]func(context.Context) string) Option {
 	return func(app *App) {
 		app.contextExtractors = contextExtractors
@@ -235,6 +246,8 @@ func (app *App) buildCommand() {
 
 	version.AddFlags(fs)
 
+	cmd.AddCommand(app.commands...)
+
 	if !app.noConfig {
 		AddConfigFlag(fs, app.name, app.watch)
 	}
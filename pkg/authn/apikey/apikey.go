@@ -0,0 +1,337 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+const (
+	// nameClaim is the extra claim WithName passes the key name in.
+	nameClaim = "apikey.name"
+)
+
+var (
+	ErrKeyInvalid          = errors.Unauthorized("KeyInvalid", "API key is invalid")
+	ErrKeyExpired          = errors.Unauthorized("KeyExpired", "API key has expired")
+	ErrKeyRevoked          = errors.Unauthorized("KeyRevoked", "API key has been revoked")
+	ErrRefreshNotSupported = errors.BadRequest("RefreshNotSupported", "API keys can not be refreshed")
+	ErrKeyNotFound         = errors.NotFound("KeyNotFound", "API key not found")
+)
+
+// encoding is the alphabet of key ids and secrets, it contains no `_`.
+var encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// APIKey represents a database record of an API key.
+type APIKey struct {
+	ID         string         `gorm:"primaryKey;size:32" json:"id"`
+	Subject    string         `gorm:"index;size:255" json:"subject"`
+	Name       string         `gorm:"size:255" json:"name"`
+	KeyHash    string         `gorm:"size:64" json:"-"`
+	TenantID   string         `gorm:"size:255" json:"tenantID,omitempty"`
+	Roles      []string       `gorm:"serializer:json" json:"roles,omitempty"`
+	Scopes     []string       `gorm:"serializer:json" json:"scopes,omitempty"`
+	Extra      map[string]any `gorm:"serializer:json" json:"extra,omitempty"`
+	ExpiresAt  *time.Time     `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time     `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time     `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+// TableName returns the table name of the API keys.
+func (APIKey) TableName() string {
+	return "api_key"
+}
+
+// Active reports whether the key is neither revoked nor expired.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// WithName set the display name of a created key.
+func WithName(name string) authn.ClaimsOption {
+	return authn.WithExtra(nameClaim, name)
+}
+
+// WithExpiresAt set when a created key expires, overriding the default expiration.
+func WithExpiresAt(expiresAt time.Time) authn.ClaimsOption {
+	return func(c *authn.Claims) {
+		c.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	}
+}
+
+type options struct {
+	prefix           string
+	expiration       time.Duration
+	lastUsedInterval time.Duration
+}
+
+// Option is apikey option.
+type Option func(*options)
+
+// WithPrefix set the prefix of the keys (default `ak`).
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithExpiration set how long a created key is valid. The default 0 creates
+// keys which never expire.
+func WithExpiration(expiration time.Duration) Option {
+	return func(o *options) {
+		o.expiration = expiration
+	}
+}
+
+// WithLastUsedInterval set how often the last used time of a key is
+// written (default 1m), to avoid a database write on every request.
+func WithLastUsedInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.lastUsedInterval = interval
+	}
+}
+
+// Authenticator authenticates API keys stored in a database.
+type Authenticator struct {
+	db   *gorm.DB
+	opts *options
+}
+
+// Ensure Authenticator implements the authn.Authenticator and authn.TokenMatcher interfaces.
+var (
+	_ authn.Authenticator = (*Authenticator)(nil)
+	_ authn.TokenMatcher  = (*Authenticator)(nil)
+)
+
+// New creates an API key Authenticator and migrates the key table.
+func New(db *gorm.DB, opts ...Option) (*Authenticator, error) {
+	o := &options{
+		prefix:           "ak",
+		lastUsedInterval: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := db.AutoMigrate(&APIKey{}); err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{db: db, opts: o}, nil
+}
+
+// Sign creates an API key for the user. The key is only returned here, the
+// database stores its hash. Roles, scopes, tenant and extra claims are
+// stored with the key and returned by ParseClaims.
+func (a *Authenticator) Sign(ctx context.Context, userID string, opts ...authn.ClaimsOption) (authn.IToken, error) {
+	claims := (&authn.Claims{}).ApplyClaimsOptions(opts...)
+	name, _ := claims.Extra[nameClaim].(string)
+	delete(claims.Extra, nameClaim)
+
+	id, err := randomString(10)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomString(20)
+	if err != nil {
+		return nil, err
+	}
+	token := a.opts.prefix + "_" + id + "_" + secret
+
+	key := &APIKey{
+		ID:        id,
+		Subject:   userID,
+		Name:      name,
+		KeyHash:   hashKey(token),
+		TenantID:  claims.TenantID,
+		Roles:     claims.Roles,
+		Scopes:    claims.Scopes,
+		Extra:     claims.Extra,
+		CreatedAt: time.Now(),
+	}
+	switch {
+	case claims.ExpiresAt != nil:
+		key.ExpiresAt = &claims.ExpiresAt.Time
+	case a.opts.expiration > 0:
+		expiresAt := key.CreatedAt.Add(a.opts.expiration)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := a.db.WithContext(ctx).Create(key).Error; err != nil {
+		return nil, err
+	}
+
+	info := &keyInfo{ID: id, Token: token, Type: "Bearer"}
+	if key.ExpiresAt != nil {
+		info.ExpiresAt = key.ExpiresAt.Unix()
+	}
+	return info, nil
+}
+
+// Refresh is not supported, API keys are long-lived.
+func (a *Authenticator) Refresh(ctx context.Context, refreshToken string) (authn.IToken, error) {
+	return nil, ErrRefreshNotSupported
+}
+
+// Destroy revokes the API key.
+func (a *Authenticator) Destroy(ctx context.Context, accessToken string) error {
+	key, err := a.lookup(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	return a.Revoke(ctx, key.Subject, key.ID)
+}
+
+// ParseClaims checks the API key and returns the claims stored with it. The
+// key id is returned as the jti claim.
+func (a *Authenticator) ParseClaims(ctx context.Context, accessToken string) (*authn.Claims, error) {
+	key, err := a.lookup(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, ErrKeyRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= a.opts.lastUsedInterval {
+		// Last used tracking is best effort, it must not fail the request.
+		_ = a.db.WithContext(ctx).Model(&APIKey{}).
+			Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-a.opts.lastUsedInterval)).
+			Update("last_used_at", now).Error
+	}
+
+	claims := &authn.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       key.ID,
+			Subject:  key.Subject,
+			IssuedAt: jwt.NewNumericDate(key.CreatedAt),
+		},
+		TenantID: key.TenantID,
+		Roles:    key.Roles,
+		Scopes:   key.Scopes,
+		Extra:    key.Extra,
+	}
+	if key.ExpiresAt != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*key.ExpiresAt)
+	}
+	return claims, nil
+}
+
+// Release is a no-op, the database connection is owned by the caller.
+func (a *Authenticator) Release() error {
+	return nil
+}
+
+// MatchToken reports whether the token has the format of an API key.
+func (a *Authenticator) MatchToken(token string) bool {
+	_, ok := a.parseID(token)
+	return ok
+}
+
+// Get returns the API key with the given id.
+func (a *Authenticator) Get(ctx context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	err := a.db.WithContext(ctx).Where("id = ?", id).First(&key).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List returns the API keys of the subject, including revoked and expired ones.
+func (a *Authenticator) List(ctx context.Context, subject string) ([]*APIKey, error) {
+	var keys []*APIKey
+	if err := a.db.WithContext(ctx).Where("subject = ?", subject).Order("created_at").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke revokes the API key of the subject with the given id.
+func (a *Authenticator) Revoke(ctx context.Context, subject string, id string) error {
+	result := a.db.WithContext(ctx).Model(&APIKey{}).
+		Where("id = ? AND subject = ? AND revoked_at IS NULL", id, subject).
+		Update("revoked_at", time.Now())
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		if _, err := a.Get(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup finds the key record of the token and checks the secret.
+func (a *Authenticator) lookup(ctx context.Context, token string) (*APIKey, error) {
+	id, ok := a.parseID(token)
+	if !ok {
+		return nil, ErrKeyInvalid
+	}
+
+	key, err := a.Get(ctx, id)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, ErrKeyInvalid
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashKey(token))) != 1 {
+		return nil, ErrKeyInvalid
+	}
+	return key, nil
+}
+
+// parseID returns the id of a token of the form `<prefix>_<id>_<secret>`.
+func (a *Authenticator) parseID(token string) (string, bool) {
+	rest, ok := strings.CutPrefix(token, a.opts.prefix+"_")
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	return id, ok && id != "" && secret != ""
+}
+
+// hashKey returns the hex encoded SHA-256 hash of the key. The secret is
+// random, so a fast hash is sufficient.
+func hashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
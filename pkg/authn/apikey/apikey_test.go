@@ -0,0 +1,94 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authn/jwt"
+)
+
+func newAuthenticator(t *testing.T, opts ...Option) *Authenticator {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	a, err := New(db, opts...)
+	require.NoError(t, err)
+	return a
+}
+
+func TestSignAndParseClaims(t *testing.T) {
+	ctx := context.Background()
+	a := newAuthenticator(t)
+
+	key, err := a.Sign(ctx, "svc-1", WithName("ci"), authn.WithScopes("read"), authn.WithTenant("tenant-a"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key.GetToken(), "ak_"))
+	assert.Zero(t, key.GetExpiresAt())
+
+	claims, err := a.ParseClaims(ctx, key.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "svc-1", claims.Subject)
+	assert.Equal(t, "tenant-a", claims.TenantID)
+	assert.True(t, claims.HasScope("read"))
+
+	keys, err := a.List(ctx, "svc-1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "ci", keys[0].Name)
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.NotContains(t, keys[0].KeyHash, key.GetToken())
+
+	_, err = a.ParseClaims(ctx, key.GetToken()+"x")
+	assert.ErrorIs(t, err, ErrKeyInvalid)
+
+	require.NoError(t, a.Destroy(ctx, key.GetToken()))
+	_, err = a.ParseClaims(ctx, key.GetToken())
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+	assert.NotErrorIs(t, err, ErrKeyInvalid)
+}
+
+func TestExpiredKey(t *testing.T) {
+	ctx := context.Background()
+	a := newAuthenticator(t)
+
+	key, err := a.Sign(ctx, "svc-1", WithExpiresAt(time.Now().Add(-time.Minute)))
+	require.NoError(t, err)
+
+	_, err = a.ParseClaims(ctx, key.GetToken())
+	assert.ErrorIs(t, err, ErrKeyExpired)
+	assert.NotErrorIs(t, err, ErrKeyRevoked)
+}
+
+func TestMulti(t *testing.T) {
+	ctx := context.Background()
+	keys := newAuthenticator(t)
+	tokens := jwt.New(nil)
+	multi := authn.NewMulti(tokens, keys)
+
+	key, err := keys.Sign(ctx, "svc-1")
+	require.NoError(t, err)
+	pair, err := multi.Sign(ctx, "user-1")
+	require.NoError(t, err)
+
+	claims, err := multi.ParseClaims(ctx, key.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "svc-1", claims.Subject)
+
+	claims, err = multi.ParseClaims(ctx, pair.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+}
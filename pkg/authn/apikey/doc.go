@@ -0,0 +1,13 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+// Package apikey implements an authn.Authenticator for long-lived API keys
+// used by machine-to-machine clients. Keys have the form
+// `ak_<id>_<secret>`, only their SHA-256 hash is stored.
+//
+// Combine it with jwt.JWTAuth through authn.NewMulti to accept both kinds
+// of tokens in the authentication middleware.
+package apikey // import "github.com/ydcloud-dy/publicPkg/pkg/authn/apikey"
@@ -0,0 +1,52 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package apikey
+
+import (
+	"encoding/json"
+)
+
+// keyInfo contains the information of a created API key.
+type keyInfo struct {
+	// ID of the key.
+	ID string `json:"id"`
+
+	// Key string, only returned when the key is created.
+	Token string `json:"token"`
+
+	// Token type.
+	Type string `json:"type"`
+
+	// Key expiration time, 0 if the key never expires.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+}
+
+func (k *keyInfo) GetToken() string {
+	return k.Token
+}
+
+func (k *keyInfo) GetTokenType() string {
+	return k.Type
+}
+
+func (k *keyInfo) GetExpiresAt() int64 {
+	return k.ExpiresAt
+}
+
+// GetRefreshToken returns an empty string, API keys can not be refreshed.
+func (k *keyInfo) GetRefreshToken() string {
+	return ""
+}
+
+// GetRefreshExpiresAt returns 0, API keys can not be refreshed.
+func (k *keyInfo) GetRefreshExpiresAt() int64 {
+	return 0
+}
+
+func (k *keyInfo) EncodeToJSON() ([]byte, error) {
+	return json.Marshal(k)
+}
@@ -9,7 +9,6 @@ package authn
 import (
 	"context"
 
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,26 +20,36 @@ type IToken interface {
 	GetTokenType() string
 	// Get token expiration timestamp.
 	GetExpiresAt() int64
+	// Get refresh token string.
+	GetRefreshToken() string
+	// Get refresh token expiration timestamp.
+	GetRefreshExpiresAt() int64
 	// JSON encoding
 	EncodeToJSON() ([]byte, error)
 }
 
 // Authenticator defines methods used for token processing.
 type Authenticator interface {
-	// Sign is used to generate a token.
-	Sign(ctx context.Context, userID string) (IToken, error)
+	// Sign is used to generate an access and refresh token pair. The options
+	// are used to embed custom claims such as tenant, roles and scopes.
+	Sign(ctx context.Context, userID string, opts ...ClaimsOption) (IToken, error)
+
+	// Refresh exchanges a refresh token for a new token pair. The presented
+	// refresh token is rotated and can not be used again.
+	Refresh(ctx context.Context, refreshToken string) (IToken, error)
 
 	// Destroy is used to destroy a token.
 	Destroy(ctx context.Context, accessToken string) error
 
 	// ParseClaims parse the token and return the claims.
-	ParseClaims(ctx context.Context, accessToken string) (*jwt.RegisteredClaims, error)
+	ParseClaims(ctx context.Context, accessToken string) (*Claims, error)
 
 	// Release used to release the requested resources.
 	Release() error
 }
 
-// Encrypt encrypts the plain text with bcrypt.
+// Encrypt encrypts the plain text with bcrypt. Use a Hasher for argon2id,
+// scrypt, peppers and migrating existing hashes.
 func Encrypt(source string) (string, error) {
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(source), bcrypt.DefaultCost)
 	return string(hashedBytes), err
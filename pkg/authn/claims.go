@@ -0,0 +1,134 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package authn
+
+import (
+	"slices"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Authentication method references used in the amr claim, see RFC 8176.
+const (
+	// AMRPassword marks a password login.
+	AMRPassword = "pwd"
+	// AMROTP marks a login confirmed with a one-time password.
+	AMROTP = "otp"
+	// AMRRecoveryCode marks a login confirmed with a recovery code.
+	AMRRecoveryCode = "rc"
+	// AMRMFA marks a login which used more than one factor.
+	AMRMFA = "mfa"
+)
+
+// Claims is the set of claims carried by the tokens of an Authenticator.
+type Claims struct {
+	jwt.RegisteredClaims
+
+	// SessionID identifies the login session the token belongs to.
+	SessionID string `json:"sid,omitempty"`
+
+	// TenantID identifies the tenant the subject belongs to.
+	TenantID string `json:"tid,omitempty"`
+
+	// Roles holds the roles granted to the subject.
+	Roles []string `json:"roles,omitempty"`
+
+	// Scopes holds the scopes granted to the token.
+	Scopes []string `json:"scope,omitempty"`
+
+	// AMR holds the authentication methods used to log in, see RFC 8176.
+	AMR []string `json:"amr,omitempty"`
+
+	// Extra holds application specific claims.
+	Extra map[string]any `json:"ext,omitempty"`
+}
+
+// ClaimsOption customizes the claims of a token before it is signed.
+type ClaimsOption func(*Claims)
+
+// WithTenant set the tenant the subject belongs to.
+func WithTenant(tenantID string) ClaimsOption {
+	return func(c *Claims) {
+		c.TenantID = tenantID
+	}
+}
+
+// WithRoles set the roles granted to the subject.
+func WithRoles(roles ...string) ClaimsOption {
+	return func(c *Claims) {
+		c.Roles = roles
+	}
+}
+
+// WithScopes set the scopes granted to the token.
+func WithScopes(scopes ...string) ClaimsOption {
+	return func(c *Claims) {
+		c.Scopes = scopes
+	}
+}
+
+// WithAMR set the authentication methods used to log in.
+func WithAMR(methods ...string) ClaimsOption {
+	return func(c *Claims) {
+		c.AMR = methods
+	}
+}
+
+// WithMFA records that the login was confirmed with a second factor, e.g.
+// AMROTP or AMRRecoveryCode.
+func WithMFA(method string) ClaimsOption {
+	return func(c *Claims) {
+		for _, m := range []string{method, AMRMFA} {
+			if !slices.Contains(c.AMR, m) {
+				c.AMR = append(c.AMR, m)
+			}
+		}
+	}
+}
+
+// WithExtra set an application specific claim.
+func WithExtra(key string, value any) ClaimsOption {
+	return func(c *Claims) {
+		if c.Extra == nil {
+			c.Extra = make(map[string]any)
+		}
+		c.Extra[key] = value
+	}
+}
+
+// ApplyClaimsOptions applies the options to the claims and returns them.
+func (c *Claims) ApplyClaimsOptions(opts ...ClaimsOption) *Claims {
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// HasRole reports whether the claims grant the given role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasScope reports whether the claims grant the given scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// HasMFA reports whether the login was confirmed with a second factor.
+func (c *Claims) HasMFA() bool {
+	return slices.Contains(c.AMR, AMRMFA)
+}
+
+// Options returns the options which reproduce the custom claims of c. It is
+// used to carry the custom claims over when a token is reissued.
+func (c *Claims) Options() []ClaimsOption {
+	opts := []ClaimsOption{WithTenant(c.TenantID), WithRoles(c.Roles...), WithScopes(c.Scopes...), WithAMR(c.AMR...)}
+	for k, v := range c.Extra {
+		opts = append(opts, WithExtra(k, v))
+	}
+	return opts
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package authn
+
+import (
+	"context"
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx which carries the authenticated claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the authenticated claims stored in ctx.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// SubjectFromContext returns the authenticated subject stored in ctx, or an
+// empty string if the request is not authenticated.
+func SubjectFromContext(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.Subject
+	}
+	return ""
+}
+
+// TenantFromContext returns the tenant of the authenticated subject stored
+// in ctx. Its signature matches the value function expected by
+// where.RegisterTenant, so tenant scoped queries can be enabled with:
+//
+//	where.RegisterTenant("tenant_id", authn.TenantFromContext)
+func TenantFromContext(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.TenantID
+	}
+	return ""
+}
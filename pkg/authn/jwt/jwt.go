@@ -8,14 +8,18 @@ package jwt
 
 import (
 	"context"
+	"crypto"
+	"encoding/json"
 	"time"
 
 	"github.com/go-kratos/kratos/v2/errors"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
 
-	"github.com/onexstack/onexstack/pkg/authn"
 	"github.com/onexstack/onexstack/pkg/i18n"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
 )
 
 const (
@@ -24,6 +28,14 @@ const (
 
 	// defaultKey holds the default key used to sign a jwt token.
 	defaultKey = "onex(#)666"
+
+	// tokenUseAccess marks a token which can be used to access resources.
+	tokenUseAccess = "access"
+	// tokenUseRefresh marks a token which can only be exchanged for a new token pair.
+	tokenUseRefresh = "refresh"
+
+	// sessionKeyPrefix is the storage key prefix of a revoked session.
+	sessionKeyPrefix = "session:"
 )
 
 var (
@@ -32,6 +44,8 @@ var (
 	ErrTokenParseFail         = errors.Unauthorized(reason, "Fail to parse token")
 	ErrUnSupportSigningMethod = errors.Unauthorized(reason, "Wrong signing method")
 	ErrSignTokenFailed        = errors.Unauthorized(reason, "Failed to sign token")
+	ErrTokenReused            = errors.Unauthorized(reason, "Refresh token has already been used")
+	ErrStoreUnsupported       = errors.InternalServer("StoreUnsupported", "The token store does not support this operation")
 )
 
 // Define i18n messages.
@@ -41,29 +55,28 @@ var (
 	MessageTokenParseFail         = &goi18n.Message{ID: "jwt.token.parse.failed", Other: ErrTokenParseFail.Error()}
 	MessageUnSupportSigningMethod = &goi18n.Message{ID: "jwt.wrong.signing.method", Other: ErrUnSupportSigningMethod.Error()}
 	MessageSignTokenFailed        = &goi18n.Message{ID: "jwt.token.sign.failed", Other: ErrSignTokenFailed.Error()}
+	MessageTokenReused            = &goi18n.Message{ID: "jwt.token.reused", Other: ErrTokenReused.Error()}
 )
 
 var defaultOptions = options{
-	tokenType:     "Bearer",
-	expired:       2 * time.Hour,
-	signingMethod: jwt.SigningMethodHS256,
-	signingKey:    []byte(defaultKey),
-	keyfunc: func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrTokenInvalid
-		}
-		return []byte(defaultKey), nil
-	},
+	tokenType:      "Bearer",
+	expired:        2 * time.Hour,
+	refreshExpired: 7 * 24 * time.Hour,
+	signingMethod:  jwt.SigningMethodHS256,
+	signingKey:     []byte(defaultKey),
 }
 
 type options struct {
-	signingMethod jwt.SigningMethod
-	signingKey    any
-	keyfunc       jwt.Keyfunc
-	issuer        string
-	expired       time.Duration
-	tokenType     string
-	tokenHeader   map[string]any
+	signingMethod  jwt.SigningMethod
+	signingKey     any
+	keyfunc        jwt.Keyfunc
+	keyProvider    KeyProvider
+	issuer         string
+	expired        time.Duration
+	refreshExpired time.Duration
+	tokenType      string
+	tokenHeader    map[string]any
+	identityClaim  string
 }
 
 // Option is jwt option.
@@ -97,6 +110,16 @@ func WithKeyfunc(keyFunc jwt.Keyfunc) Option {
 	}
 }
 
+// WithKeyProvider set the provider of the keys used to sign and verify tokens,
+// e.g. a rotating *KeySet or a verification only *RemoteKeySet. Tokens are
+// signed with the provider's current key and carry its `kid` header. It
+// takes precedence over WithSigningMethod, WithSigningKey and WithKeyfunc.
+func WithKeyProvider(provider KeyProvider) Option {
+	return func(o *options) {
+		o.keyProvider = provider
+	}
+}
+
 // WithExpired set the token expiration time (in seconds, default 2h).
 func WithExpired(expired time.Duration) Option {
 	return func(o *options) {
@@ -104,6 +127,13 @@ func WithExpired(expired time.Duration) Option {
 	}
 }
 
+// WithRefreshExpired set the refresh token expiration time (default 7d).
+func WithRefreshExpired(expired time.Duration) Option {
+	return func(o *options) {
+		o.refreshExpired = expired
+	}
+}
+
 // WithTokenHeader set the customer tokenHeader for client side.
 func WithTokenHeader(header map[string]any) Option {
 	return func(o *options) {
@@ -111,6 +141,15 @@ func WithTokenHeader(header map[string]any) Option {
 	}
 }
 
+// WithIdentityClaim set a top-level claim which holds the subject too, next to
+// `sub`. It keeps the tokens readable by parsers which read the identity from
+// a custom claim, e.g. during a rolling upgrade.
+func WithIdentityClaim(key string) Option {
+	return func(o *options) {
+		o.identityClaim = key
+	}
+}
+
 // New create a authentication instance.
 func New(store Storer, opts ...Option) *JWTAuth {
 	o := defaultOptions
@@ -118,21 +157,118 @@ func New(store Storer, opts ...Option) *JWTAuth {
 		opt(&o)
 	}
 
+	switch {
+	case o.keyProvider != nil:
+		o.keyfunc = o.keyProvider.Keyfunc
+	case o.keyfunc == nil:
+		o.keyfunc = staticKeyfunc(o.signingMethod, o.signingKey)
+	}
+
 	return &JWTAuth{opts: &o, store: store}
 }
 
+// staticKeyfunc returns a keyfunc which verifies tokens with the static
+// signing key. Asymmetric signing keys are verified with their public part.
+func staticKeyfunc(method jwt.SigningMethod, key any) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		if t.Method.Alg() != method.Alg() {
+			return nil, ErrTokenInvalid
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer.Public(), nil
+		}
+		return key, nil
+	}
+}
+
 // JWTAuth implement the authn.Authenticator interface.
 type JWTAuth struct {
 	opts  *options
 	store Storer
 }
 
-// Sign is used to generate a token.
-func (a *JWTAuth) Sign(ctx context.Context, userID string) (authn.IToken, error) {
+// claims is the payload of the tokens signed by JWTAuth.
+type claims struct {
+	authn.Claims
+	// Use distinguishes access tokens from refresh tokens.
+	Use string `json:"use,omitempty"`
+	// IssuedAtMilli is the issue time in Unix milliseconds, `iat` only has a
+	// precision of one second.
+	IssuedAtMilli int64 `json:"iat_ms,omitempty"`
+
+	// identityClaim is the top-level claim which holds the subject too.
+	identityClaim string
+}
+
+// MarshalJSON writes the subject under the identity claim too, when it is set.
+func (c *claims) MarshalJSON() ([]byte, error) {
+	type plain claims
+	data, err := json.Marshal((*plain)(c))
+	if err != nil || c.identityClaim == "" {
+		return data, err
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if _, ok := m[c.identityClaim]; !ok {
+		m[c.identityClaim] = c.Subject
+	}
+	return json.Marshal(m)
+}
+
+// Sign is used to generate an access and refresh token pair. The options are
+// applied to both tokens, so custom claims survive a refresh. Every call
+// starts a new session, the client metadata of the session is read from
+// authn.SessionMetadataFromContext.
+func (a *JWTAuth) Sign(ctx context.Context, userID string, opts ...authn.ClaimsOption) (authn.IToken, error) {
+	token, err := a.signPair(ctx, userID, uuid.NewString(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.saveSession(ctx, token, nil); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// signPair signs an access and refresh token pair which belongs to the given session.
+func (a *JWTAuth) signPair(ctx context.Context, userID string, sessionID string, opts ...authn.ClaimsOption) (*tokenInfo, error) {
 	now := time.Now()
 	expiresAt := now.Add(a.opts.expired)
+	refreshExpiresAt := now.Add(a.opts.refreshExpired)
+
+	access := a.newClaims(userID, sessionID, tokenUseAccess, now, expiresAt, opts)
+	accessToken, err := a.signClaims(ctx, access)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := a.signClaims(ctx, a.newClaims(userID, sessionID, tokenUseRefresh, now, refreshExpiresAt, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenInfo := &tokenInfo{
+		ExpiresAt:        expiresAt.Unix(),
+		Type:             a.opts.tokenType,
+		Token:            accessToken,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt.Unix(),
+		claims:           &access.Claims,
+	}
+
+	return tokenInfo, nil
+}
 
-	token := jwt.NewWithClaims(a.opts.signingMethod, &jwt.RegisteredClaims{
+// newClaims builds the claims of a single token.
+func (a *JWTAuth) newClaims(userID, sessionID, use string, now, expiresAt time.Time, opts []authn.ClaimsOption) *claims {
+	c := &claims{Use: use, IssuedAtMilli: now.UnixMilli(), identityClaim: a.opts.identityClaim}
+	c.ApplyClaimsOptions(opts...)
+	c.SessionID = sessionID
+	c.RegisteredClaims = jwt.RegisteredClaims{
 		// Issuer = iss,令牌颁发者。它表示该令牌是由谁创建的
 		Issuer: a.opts.issuer,
 		// IssuedAt = iat,令牌颁发时的时间戳。它表示令牌是何时被创建的
@@ -143,30 +279,44 @@ func (a *JWTAuth) Sign(ctx context.Context, userID string) (authn.IToken, error)
 		NotBefore: jwt.NewNumericDate(now),
 		// Subject = sub,令牌的主体。它表示该令牌是关于谁的
 		Subject: userID,
-	})
+		// ID = jti,令牌的唯一标识。它保证同一时刻签发的令牌也互不相同
+		ID: uuid.NewString(),
+	}
+	return c
+}
+
+// signClaims signs the claims with the configured signing method and key.
+func (a *JWTAuth) signClaims(ctx context.Context, c *claims) (string, error) {
+	method, signingKey, kid := a.opts.signingMethod, a.opts.signingKey, ""
+	if a.opts.keyProvider != nil {
+		key, err := a.opts.keyProvider.SigningKey()
+		if err != nil {
+			return "", errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
+		}
+		method, signingKey, kid = key.Method, key.PrivateKey, key.ID
+	}
+
+	token := jwt.NewWithClaims(method, c)
 	if a.opts.tokenHeader != nil {
 		for k, v := range a.opts.tokenHeader {
 			token.Header[k] = v
 		}
 	}
-
-	refreshToken, err := token.SignedString(a.opts.signingKey)
-	if err != nil {
-		return nil, errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
+	if kid != "" {
+		token.Header["kid"] = kid
 	}
 
-	tokenInfo := &tokenInfo{
-		ExpiresAt: expiresAt.Unix(),
-		Type:      a.opts.tokenType,
-		Token:     refreshToken,
+	signed, err := token.SignedString(signingKey)
+	if err != nil {
+		return "", errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
 	}
 
-	return tokenInfo, nil
+	return signed, nil
 }
 
-// parseToken is used to parse the input refreshToken.
-func (a *JWTAuth) parseToken(ctx context.Context, refreshToken string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(refreshToken, &jwt.RegisteredClaims{}, a.opts.keyfunc)
+// parseToken is used to parse the input token.
+func (a *JWTAuth) parseToken(ctx context.Context, tokenString string) (*claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &claims{}, a.opts.keyfunc)
 	if err != nil {
 		ve, ok := err.(*jwt.ValidationError)
 		if !ok {
@@ -185,11 +335,12 @@ func (a *JWTAuth) parseToken(ctx context.Context, refreshToken string) (*jwt.Reg
 		return nil, errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
 	}
 
-	if token.Method != a.opts.signingMethod {
+	// A key provider checks the signing method against the key of each kid.
+	if a.opts.keyProvider == nil && token.Method != a.opts.signingMethod {
 		return nil, errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageUnSupportSigningMethod))
 	}
 
-	return token.Claims.(*jwt.RegisteredClaims), nil
+	return token.Claims.(*claims), nil
 }
 
 func (a *JWTAuth) callStore(fn func(Storer) error) error {
@@ -199,50 +350,266 @@ func (a *JWTAuth) callStore(fn func(Storer) error) error {
 	return nil
 }
 
-// Destroy is used to destroy a token.
-func (a *JWTAuth) Destroy(ctx context.Context, refreshToken string) error {
-	claims, err := a.parseToken(ctx, refreshToken)
+// sessionStore returns the store as a SessionStorer if it supports sessions.
+func (a *JWTAuth) sessionStore() (SessionStorer, bool) {
+	store, ok := a.store.(SessionStorer)
+	return store, ok
+}
+
+// sessionKey returns the storage key which marks a session as revoked.
+func sessionKey(sessionID string) string {
+	return sessionKeyPrefix + sessionID
+}
+
+// checkRevoked returns an error if the token itself, its session or every
+// token of its subject has been revoked.
+func (a *JWTAuth) checkRevoked(ctx context.Context, tokenString string, c *claims) error {
+	return a.callStore(func(store Storer) error {
+		exists, err := store.Check(ctx, tokenString)
+		if err != nil {
+			return err
+		}
+
+		if !exists && c.SessionID != "" {
+			if exists, err = store.Check(ctx, sessionKey(c.SessionID)); err != nil {
+				return err
+			}
+		}
+
+		if !exists {
+			if revoker, ok := store.(SubjectRevoker); ok && c.IssuedAt != nil {
+				notBefore, err := revoker.GetNotBefore(ctx, c.Subject)
+				if err != nil {
+					return err
+				}
+				issuedAt := c.IssuedAt.Time
+				if c.IssuedAtMilli > 0 {
+					issuedAt = time.UnixMilli(c.IssuedAtMilli)
+				}
+				exists = issuedAt.Before(notBefore)
+			}
+		}
+
+		if exists {
+			return errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+		}
+
+		return nil
+	})
+}
+
+// saveSession records the session of a newly signed token pair. previous
+// holds the session being refreshed, nil for a new session.
+func (a *JWTAuth) saveSession(ctx context.Context, token *tokenInfo, previous *authn.Session) error {
+	store, ok := a.sessionStore()
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	session := previous
+	if session == nil {
+		md := authn.SessionMetadataFromContext(ctx)
+		session = &authn.Session{
+			ID:        token.claims.SessionID,
+			Subject:   token.claims.Subject,
+			Device:    md.Device,
+			IP:        md.IP,
+			UserAgent: md.UserAgent,
+			CreatedAt: now,
+		}
+	}
+	session.LastUsedAt = now
+	session.ExpiresAt = time.Unix(token.RefreshExpiresAt, 0)
+
+	return store.SaveSession(ctx, session)
+}
+
+// Destroy is used to destroy a token. The whole session is revoked as well,
+// so that a logout also invalidates the paired refresh token.
+func (a *JWTAuth) Destroy(ctx context.Context, tokenString string) error {
+	c, err := a.parseToken(ctx, tokenString)
 	if err != nil {
 		return err
 	}
 
 	// If storage is set, put the unexpired token in
 	store := func(store Storer) error {
-		expired := time.Until(claims.ExpiresAt.Time)
-		return store.Set(ctx, refreshToken, expired)
+		expired := time.Until(c.ExpiresAt.Time)
+		if err := store.Set(ctx, tokenString, expired); err != nil {
+			return err
+		}
+
+		if c.SessionID == "" {
+			return nil
+		}
+		return a.revokeSession(ctx, c.Subject, c.SessionID)
 	}
 	return a.callStore(store)
 }
 
-// ParseClaims parse the token and return the claims.
-func (a *JWTAuth) ParseClaims(ctx context.Context, refreshToken string) (*jwt.RegisteredClaims, error) {
+// revokeSession revokes every token of the session and forgets the session.
+func (a *JWTAuth) revokeSession(ctx context.Context, subject string, sessionID string) error {
+	if err := a.store.Set(ctx, sessionKey(sessionID), a.opts.refreshExpired); err != nil {
+		return err
+	}
+
+	if store, ok := a.sessionStore(); ok {
+		return store.DeleteSession(ctx, subject, sessionID)
+	}
+	return nil
+}
+
+// ParseClaims parse the access token and return the claims.
+func (a *JWTAuth) ParseClaims(ctx context.Context, accessToken string) (*authn.Claims, error) {
+	if accessToken == "" {
+		return nil, errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
+
+	c, err := a.parseToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens signed before the access/refresh split carry no `use` claim and
+	// are accepted as access tokens.
+	if c.Use == tokenUseRefresh {
+		return nil, errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
+
+	if err := a.checkRevoked(ctx, accessToken, c); err != nil {
+		return nil, err
+	}
+
+	return &c.Claims, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair and rotates the
+// refresh token. Presenting an already rotated refresh token is treated as
+// token theft and revokes the whole session. Reuse detection requires a
+// Storer.
+func (a *JWTAuth) Refresh(ctx context.Context, refreshToken string) (authn.IToken, error) {
 	if refreshToken == "" {
 		return nil, errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
 	}
 
-	claims, err := a.parseToken(ctx, refreshToken)
+	c, err := a.parseToken(ctx, refreshToken)
 	if err != nil {
 		return nil, err
 	}
 
+	if c.Use != tokenUseRefresh {
+		return nil, errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
+
 	store := func(store Storer) error {
-		exists, err := store.Check(ctx, refreshToken)
+		if err := a.checkRevoked(ctx, refreshToken, c); err != nil {
+			used, cerr := store.Check(ctx, refreshToken)
+			if cerr != nil || !used {
+				return err
+			}
+
+			// The refresh token has been rotated before, someone replays it.
+			return a.tokenReused(ctx, c)
+		}
+
+		stored, err := store.SetNX(ctx, refreshToken, time.Until(c.ExpiresAt.Time))
 		if err != nil {
 			return err
 		}
-
-		if exists {
-			return errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+		if !stored {
+			// A concurrent request has rotated the refresh token first.
+			return a.tokenReused(ctx, c)
 		}
-
 		return nil
 	}
-
 	if err := a.callStore(store); err != nil {
 		return nil, err
 	}
 
-	return claims, nil
+	token, err := a.signPair(ctx, c.Subject, c.SessionID, c.Claims.Options()...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.refreshSession(ctx, token, c); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// tokenReused revokes the session of a replayed refresh token.
+func (a *JWTAuth) tokenReused(ctx context.Context, c *claims) error {
+	if err := a.revokeSession(ctx, c.Subject, c.SessionID); err != nil {
+		return err
+	}
+	return errors.Unauthorized(reason, i18n.FromContext(ctx).LocalizeT(MessageTokenReused))
+}
+
+// refreshSession extends the session of a refreshed token pair.
+func (a *JWTAuth) refreshSession(ctx context.Context, token *tokenInfo, c *claims) error {
+	store, ok := a.sessionStore()
+	if !ok {
+		return nil
+	}
+
+	sessions, err := store.ListSessions(ctx, c.Subject)
+	if err != nil {
+		return err
+	}
+
+	var previous *authn.Session
+	for _, session := range sessions {
+		if session.ID == c.SessionID {
+			previous = session
+			break
+		}
+	}
+	return a.saveSession(ctx, token, previous)
+}
+
+// ListSessions returns the active sessions of the subject. It requires a
+// Storer which implements SessionStorer.
+func (a *JWTAuth) ListSessions(ctx context.Context, subject string) ([]*authn.Session, error) {
+	store, ok := a.sessionStore()
+	if !ok {
+		return nil, ErrStoreUnsupported
+	}
+	return store.ListSessions(ctx, subject)
+}
+
+// RevokeSession revokes every token of one session of the subject, e.g. to
+// log out a single device.
+func (a *JWTAuth) RevokeSession(ctx context.Context, subject string, sessionID string) error {
+	if a.store == nil {
+		return ErrStoreUnsupported
+	}
+	return a.revokeSession(ctx, subject, sessionID)
+}
+
+// RevokeAllForSubject revokes every token issued to the subject so far, e.g.
+// after a password change. The tokens signed once it returns are accepted. It
+// requires a Storer which implements SubjectRevoker.
+func (a *JWTAuth) RevokeAllForSubject(ctx context.Context, userID string) error {
+	revoker, ok := a.store.(SubjectRevoker)
+	if !ok {
+		return ErrStoreUnsupported
+	}
+
+	// The issue time of a token has a precision of one millisecond, notBefore
+	// is the start of the next millisecond so that the tokens issued in the
+	// current one are rejected too.
+	notBefore := time.Now().Truncate(time.Millisecond).Add(time.Millisecond)
+	if err := revoker.SetNotBefore(ctx, userID, notBefore, a.opts.refreshExpired); err != nil {
+		return err
+	}
+	// Wait for notBefore, so that the tokens signed after returning are accepted.
+	time.Sleep(time.Until(notBefore))
+
+	if store, ok := a.sessionStore(); ok {
+		return store.DeleteSessions(ctx, userID)
+	}
+	return nil
 }
 
 // Release used to release the requested resources.
@@ -0,0 +1,243 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package jwt
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authn/jwt/store/memory"
+)
+
+// fakeStore is a map backed Storer used by the tests.
+type fakeStore struct {
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{keys: make(map[string]time.Time)}
+}
+
+func (s *fakeStore) Set(ctx context.Context, key string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = time.Now().Add(expiration)
+	return nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	delete(s.keys, key)
+	return ok, nil
+}
+
+func (s *fakeStore) Check(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt, ok := s.keys[key]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (s *fakeStore) SetNX(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if expiresAt, ok := s.keys[key]; ok && time.Now().Before(expiresAt) {
+		return false, nil
+	}
+	s.keys[key] = time.Now().Add(expiration)
+	return true, nil
+}
+
+func (s *fakeStore) Close() error { return nil }
+
+func TestSignReturnsTokenPair(t *testing.T) {
+	auth := New(newFakeStore(), WithExpired(time.Minute), WithRefreshExpired(time.Hour))
+	ctx := context.Background()
+
+	pair, err := auth.Sign(ctx, "user-1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, pair.GetToken())
+	assert.NotEmpty(t, pair.GetRefreshToken())
+	assert.NotEqual(t, pair.GetToken(), pair.GetRefreshToken())
+	assert.Greater(t, pair.GetRefreshExpiresAt(), pair.GetExpiresAt())
+
+	claims, err := auth.ParseClaims(ctx, pair.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	// A refresh token must not be accepted as an access token.
+	_, err = auth.ParseClaims(ctx, pair.GetRefreshToken())
+	assert.Error(t, err)
+
+	// An access token must not be accepted as a refresh token.
+	_, err = auth.Refresh(ctx, pair.GetToken())
+	assert.Error(t, err)
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	auth := New(newFakeStore())
+	ctx := context.Background()
+
+	first, err := auth.Sign(ctx, "user-1")
+	require.NoError(t, err)
+
+	second, err := auth.Refresh(ctx, first.GetRefreshToken())
+	require.NoError(t, err)
+	assert.NotEqual(t, first.GetRefreshToken(), second.GetRefreshToken())
+
+	claims, err := auth.ParseClaims(ctx, second.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	// Replaying the rotated refresh token revokes the whole family.
+	_, err = auth.Refresh(ctx, first.GetRefreshToken())
+	assert.ErrorContains(t, err, ErrTokenReused.Message)
+
+	_, err = auth.Refresh(ctx, second.GetRefreshToken())
+	assert.Error(t, err)
+	_, err = auth.ParseClaims(ctx, second.GetToken())
+	assert.Error(t, err)
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	auth := New(newFakeStore())
+	ctx := context.Background()
+
+	pair, err := auth.Sign(ctx, "user-1")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = auth.Refresh(ctx, pair.GetRefreshToken())
+		}()
+	}
+	wg.Wait()
+
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	assert.LessOrEqual(t, succeeded, 1)
+}
+
+func TestDestroyRevokesFamily(t *testing.T) {
+	auth := New(newFakeStore())
+	ctx := context.Background()
+
+	pair, err := auth.Sign(ctx, "user-1")
+	require.NoError(t, err)
+
+	require.NoError(t, auth.Destroy(ctx, pair.GetToken()))
+
+	_, err = auth.ParseClaims(ctx, pair.GetToken())
+	assert.Error(t, err)
+	_, err = auth.Refresh(ctx, pair.GetRefreshToken())
+	assert.Error(t, err)
+}
+
+func TestCustomClaimsSurviveRefresh(t *testing.T) {
+	auth := New(newFakeStore())
+	ctx := context.Background()
+
+	pair, err := auth.Sign(ctx, "user-1",
+		authn.WithTenant("tenant-a"),
+		authn.WithRoles("admin", "dev"),
+		authn.WithScopes("read"),
+		authn.WithExtra("region", "eu"),
+		authn.WithAMR(authn.AMRPassword),
+		authn.WithMFA(authn.AMROTP),
+	)
+	require.NoError(t, err)
+
+	pair, err = auth.Refresh(ctx, pair.GetRefreshToken())
+	require.NoError(t, err)
+
+	claims, err := auth.ParseClaims(ctx, pair.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "tenant-a", claims.TenantID)
+	assert.True(t, claims.HasRole("admin"))
+	assert.True(t, claims.HasScope("read"))
+	assert.Equal(t, "eu", claims.Extra["region"])
+	assert.True(t, claims.HasMFA())
+	assert.Equal(t, []string{authn.AMRPassword, authn.AMROTP, authn.AMRMFA}, claims.AMR)
+
+	ctx = authn.WithClaims(ctx, claims)
+	assert.Equal(t, "tenant-a", authn.TenantFromContext(ctx))
+	assert.Equal(t, "user-1", authn.SubjectFromContext(ctx))
+}
+
+func TestSessionsAndRevokeAllForSubject(t *testing.T) {
+	auth := New(memory.NewStore())
+	ctx := authn.WithSessionMetadata(context.Background(), authn.SessionMetadata{Device: "laptop", IP: "10.0.0.1"})
+
+	laptop, err := auth.Sign(ctx, "user-1")
+	require.NoError(t, err)
+	phone, err := auth.Sign(context.Background(), "user-1")
+	require.NoError(t, err)
+	other, err := auth.Sign(context.Background(), "user-2")
+	require.NoError(t, err)
+
+	sessions, err := auth.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	claims, err := auth.ParseClaims(ctx, laptop.GetToken())
+	require.NoError(t, err)
+	for _, session := range sessions {
+		if session.ID == claims.SessionID {
+			assert.Equal(t, "laptop", session.Device)
+			assert.Equal(t, "10.0.0.1", session.IP)
+		}
+	}
+
+	// Revoking one session logs out a single device.
+	require.NoError(t, auth.RevokeSession(ctx, "user-1", claims.SessionID))
+	_, err = auth.ParseClaims(ctx, laptop.GetToken())
+	assert.Error(t, err)
+	_, err = auth.ParseClaims(ctx, phone.GetToken())
+	assert.NoError(t, err)
+
+	// Revoking the subject logs out every remaining device of that subject
+	// only, including the tokens issued just before.
+	phone, err = auth.Refresh(ctx, phone.GetRefreshToken())
+	require.NoError(t, err)
+	require.NoError(t, auth.RevokeAllForSubject(ctx, "user-1"))
+	_, err = auth.ParseClaims(ctx, phone.GetToken())
+	assert.Error(t, err)
+	_, err = auth.Refresh(ctx, phone.GetRefreshToken())
+	assert.Error(t, err)
+	_, err = auth.ParseClaims(ctx, other.GetToken())
+	assert.NoError(t, err)
+
+	sessions, err = auth.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// A token pair signed right after the revocation is accepted.
+	pair, err := auth.Sign(ctx, "user-1")
+	require.NoError(t, err)
+	_, err = auth.ParseClaims(ctx, pair.GetToken())
+	assert.NoError(t, err)
+	_, err = auth.Refresh(ctx, pair.GetRefreshToken())
+	assert.NoError(t, err)
+}
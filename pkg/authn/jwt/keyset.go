@@ -0,0 +1,270 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNoSigningKey is returned when a key provider can only verify tokens.
+	ErrNoSigningKey = errors.New("no signing key available")
+	// ErrKeyNotFound is returned when no key matches the kid of a token.
+	ErrKeyNotFound = errors.New("no key found for the token kid")
+)
+
+// KeyProvider provides the keys used to sign and verify tokens.
+type KeyProvider interface {
+	// SigningKey returns the key currently used to sign tokens.
+	SigningKey() (*Key, error)
+
+	// Keyfunc returns the public key which verifies the given token.
+	Keyfunc(token *jwt.Token) (any, error)
+}
+
+// Key is an asymmetric signing key identified by its kid.
+type Key struct {
+	// ID is published as the `kid` header of the signed tokens.
+	ID string
+	// Method is the signing method used with the key.
+	Method jwt.SigningMethod
+	// PrivateKey signs tokens. It is nil for verification only keys.
+	PrivateKey crypto.Signer
+	// PublicKey verifies tokens.
+	PublicKey crypto.PublicKey
+	// CreatedAt is when the key was created.
+	CreatedAt time.Time
+	// ExpiresAt is when the key stops being accepted for verification. A zero
+	// value means the key has not been retired yet.
+	ExpiresAt time.Time
+}
+
+// GenerateKey generates a new key for the given asymmetric signing method.
+// Supported methods are RS256/384/512, ES256/384/512 and EdDSA.
+func GenerateKey(method jwt.SigningMethod) (*Key, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+
+	switch method.Alg() {
+	case "RS256", "RS384", "RS512":
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		signer, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing method %s", method.Alg())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:         uuid.NewString(),
+		Method:     method,
+		PrivateKey: signer,
+		PublicKey:  signer.Public(),
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// KeySet holds the signing keys of an issuer. The newest key signs tokens
+// while retired keys keep verifying tokens until their overlap window ends.
+type KeySet struct {
+	mu             sync.RWMutex
+	method         jwt.SigningMethod
+	keys           []*Key // newest first
+	rotationPeriod time.Duration
+	overlap        time.Duration
+}
+
+// Ensure KeySet implements the KeyProvider and http.Handler interfaces.
+var (
+	_ KeyProvider  = (*KeySet)(nil)
+	_ http.Handler = (*KeySet)(nil)
+)
+
+// KeySetOption is KeySet option.
+type KeySetOption func(*KeySet)
+
+// WithRotationPeriod set how often Run rotates the signing key (default 24h).
+func WithRotationPeriod(period time.Duration) KeySetOption {
+	return func(ks *KeySet) {
+		ks.rotationPeriod = period
+	}
+}
+
+// WithOverlap set how long a retired key keeps verifying tokens (default 2h).
+// It should be at least the lifetime of the tokens signed with the key.
+func WithOverlap(overlap time.Duration) KeySetOption {
+	return func(ks *KeySet) {
+		ks.overlap = overlap
+	}
+}
+
+// WithKeys seeds the key set with existing keys, e.g. loaded from a secret
+// store, instead of generating a new one. The first key signs tokens.
+func WithKeys(keys ...*Key) KeySetOption {
+	return func(ks *KeySet) {
+		ks.keys = append(ks.keys, keys...)
+	}
+}
+
+// NewKeySet creates a key set for the given asymmetric signing method and
+// generates its first key unless keys are provided with WithKeys.
+func NewKeySet(method jwt.SigningMethod, opts ...KeySetOption) (*KeySet, error) {
+	ks := &KeySet{
+		method:         method,
+		rotationPeriod: 24 * time.Hour,
+		overlap:        2 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(ks)
+	}
+
+	if len(ks.keys) == 0 {
+		if _, err := ks.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ks, nil
+}
+
+// Rotate generates a new signing key and retires the current one, which
+// keeps verifying tokens for the overlap window.
+func (ks *KeySet) Rotate() (*Key, error) {
+	key, err := GenerateKey(ks.method)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := time.Now()
+	if len(ks.keys) > 0 && ks.keys[0].ExpiresAt.IsZero() {
+		ks.keys[0].ExpiresAt = now.Add(ks.overlap)
+	}
+
+	keys := []*Key{key}
+	for _, k := range ks.keys {
+		if k.ExpiresAt.IsZero() || k.ExpiresAt.After(now) {
+			keys = append(keys, k)
+		}
+	}
+	ks.keys = keys
+
+	return key, nil
+}
+
+// Run rotates the signing key every rotation period until ctx is done.
+func (ks *KeySet) Run(ctx context.Context) {
+	ticker := time.NewTicker(ks.rotationPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A failed rotation keeps the current key, the next tick retries.
+			_, _ = ks.Rotate()
+		}
+	}
+}
+
+// SigningKey returns the key currently used to sign tokens.
+func (ks *KeySet) SigningKey() (*Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if len(ks.keys) == 0 || ks.keys[0].PrivateKey == nil {
+		return nil, ErrNoSigningKey
+	}
+	return ks.keys[0], nil
+}
+
+// Keyfunc returns the public key matching the `kid` header of the token.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range ks.keys {
+		if k.ID != kid {
+			continue
+		}
+		if !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt) {
+			break
+		}
+		if token.Method.Alg() != k.Method.Alg() {
+			return nil, ErrUnSupportSigningMethod
+		}
+		return k.PublicKey, nil
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// JWKS returns the public keys which currently verify tokens.
+func (ks *KeySet) JWKS() (*JWKS, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := &JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	now := time.Now()
+	for _, k := range ks.keys {
+		if !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt) {
+			continue
+		}
+
+		jwk, err := NewJWK(k.ID, k.Method.Alg(), k.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// ServeHTTP serves the key set as a JWKS document. Mount it on JWKSPath.
+func (ks *KeySet) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	set, err := ks.JWKS()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(rw).Encode(set)
+}
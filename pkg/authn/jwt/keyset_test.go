@@ -0,0 +1,175 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySetRotation(t *testing.T) {
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodRS256, jwt.SigningMethodES256, jwt.SigningMethodEdDSA} {
+		t.Run(method.Alg(), func(t *testing.T) {
+			ks, err := NewKeySet(method, WithOverlap(time.Hour))
+			require.NoError(t, err)
+
+			auth := New(nil, WithKeyProvider(ks))
+			ctx := context.Background()
+
+			before, err := auth.Sign(ctx, "user-1")
+			require.NoError(t, err)
+
+			_, err = ks.Rotate()
+			require.NoError(t, err)
+
+			after, err := auth.Sign(ctx, "user-1")
+			require.NoError(t, err)
+
+			// Tokens signed with the retired key verify during the overlap window.
+			for _, token := range []string{before.GetToken(), after.GetToken()} {
+				claims, err := auth.ParseClaims(ctx, token)
+				require.NoError(t, err)
+				assert.Equal(t, "user-1", claims.Subject)
+			}
+
+			set, err := ks.JWKS()
+			require.NoError(t, err)
+			assert.Len(t, set.Keys, 2)
+		})
+	}
+}
+
+func TestKeySetRetiredKeyExpires(t *testing.T) {
+	ks, err := NewKeySet(jwt.SigningMethodES256, WithOverlap(0))
+	require.NoError(t, err)
+
+	auth := New(nil, WithKeyProvider(ks))
+	ctx := context.Background()
+
+	pair, err := auth.Sign(ctx, "user-1")
+	require.NoError(t, err)
+
+	_, err = ks.Rotate()
+	require.NoError(t, err)
+
+	_, err = auth.ParseClaims(ctx, pair.GetToken())
+	assert.Error(t, err)
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	ks, err := NewKeySet(jwt.SigningMethodES256)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(ks)
+	defer server.Close()
+
+	issuer := New(nil, WithKeyProvider(ks))
+	verifier := New(nil, WithKeyProvider(NewRemoteKeySet(server.URL+JWKSPath, WithMinRefreshInterval(0))))
+	ctx := context.Background()
+
+	pair, err := issuer.Sign(ctx, "user-1")
+	require.NoError(t, err)
+
+	claims, err := verifier.ParseClaims(ctx, pair.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	// A key rotated in after the first fetch is picked up through its unknown kid.
+	_, err = ks.Rotate()
+	require.NoError(t, err)
+
+	pair, err = issuer.Sign(ctx, "user-2")
+	require.NoError(t, err)
+
+	claims, err = verifier.ParseClaims(ctx, pair.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", claims.Subject)
+
+	// A verifier can not sign tokens.
+	_, err = verifier.Sign(ctx, "user-1")
+	assert.Error(t, err)
+}
+
+func TestRemoteKeySetServesStaleKeys(t *testing.T) {
+	ks, err := NewKeySet(jwt.SigningMethodES256)
+	require.NoError(t, err)
+
+	var fetches atomic.Int64
+	gate := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every fetch but the first one waits for the gate.
+		if fetches.Add(1) > 1 {
+			<-gate
+		}
+		ks.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	issuer := New(nil, WithKeyProvider(ks))
+	verifier := New(nil, WithKeyProvider(NewRemoteKeySet(server.URL+JWKSPath, WithCacheTTL(time.Nanosecond), WithMinRefreshInterval(0))))
+	ctx := context.Background()
+
+	pair, err := issuer.Sign(ctx, "user-1")
+	require.NoError(t, err)
+	_, err = verifier.ParseClaims(ctx, pair.GetToken())
+	require.NoError(t, err)
+
+	// The expired keys are served while a single fetch is pending.
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := verifier.ParseClaims(ctx, pair.GetToken())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+	close(gate)
+}
+
+func TestRemoteKeySetRateLimitsUnknownKids(t *testing.T) {
+	ks, err := NewKeySet(jwt.SigningMethodES256)
+	require.NoError(t, err)
+
+	var fetches atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		ks.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	issuer := New(nil, WithKeyProvider(ks))
+	verifier := New(nil, WithKeyProvider(NewRemoteKeySet(server.URL+JWKSPath, WithMinRefreshInterval(time.Hour))))
+	ctx := context.Background()
+
+	pair, err := issuer.Sign(ctx, "user-1")
+	require.NoError(t, err)
+	_, err = verifier.ParseClaims(ctx, pair.GetToken())
+	require.NoError(t, err)
+
+	_, err = ks.Rotate()
+	require.NoError(t, err)
+	pair, err = issuer.Sign(ctx, "user-2")
+	require.NoError(t, err)
+
+	for range 5 {
+		_, err = verifier.ParseClaims(ctx, pair.GetToken())
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int64(1), fetches.Load())
+}
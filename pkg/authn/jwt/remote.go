@@ -0,0 +1,198 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+// remoteKey is a verification key fetched from a remote JWKS.
+type remoteKey struct {
+	alg string
+	pub crypto.PublicKey
+}
+
+// RemoteKeySet verifies tokens with the keys published by a remote JWKS
+// endpoint. Keys are cached and refetched when the cache expires or a token
+// carries an unknown kid, so other services can validate tokens without
+// sharing a secret. Expired keys keep being served while they are refetched
+// in the background.
+type RemoteKeySet struct {
+	url                string
+	client             *http.Client
+	cacheTTL           time.Duration
+	minRefreshInterval time.Duration
+
+	// group merges the concurrent fetches of the JWKS.
+	group singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]remoteKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// Ensure RemoteKeySet implements the KeyProvider interface.
+var _ KeyProvider = (*RemoteKeySet)(nil)
+
+// RemoteKeySetOption is RemoteKeySet option.
+type RemoteKeySetOption func(*RemoteKeySet)
+
+// WithHTTPClient set the http client used to fetch the JWKS.
+func WithHTTPClient(client *http.Client) RemoteKeySetOption {
+	return func(rks *RemoteKeySet) {
+		rks.client = client
+	}
+}
+
+// WithCacheTTL set how long fetched keys are cached (default 5m).
+func WithCacheTTL(ttl time.Duration) RemoteKeySetOption {
+	return func(rks *RemoteKeySet) {
+		rks.cacheTTL = ttl
+	}
+}
+
+// WithMinRefreshInterval set the minimum interval between two fetches
+// triggered by unknown kids (default 10s). It protects the issuer from
+// tokens carrying random kids.
+func WithMinRefreshInterval(interval time.Duration) RemoteKeySetOption {
+	return func(rks *RemoteKeySet) {
+		rks.minRefreshInterval = interval
+	}
+}
+
+// NewRemoteKeySet creates a RemoteKeySet which fetches keys from url.
+func NewRemoteKeySet(url string, opts ...RemoteKeySetOption) *RemoteKeySet {
+	rks := &RemoteKeySet{
+		url:                url,
+		client:             &http.Client{Timeout: 10 * time.Second},
+		cacheTTL:           5 * time.Minute,
+		minRefreshInterval: 10 * time.Second,
+		keys:               make(map[string]remoteKey),
+	}
+	for _, opt := range opts {
+		opt(rks)
+	}
+	return rks
+}
+
+// SigningKey always fails, a RemoteKeySet can only verify tokens.
+func (rks *RemoteKeySet) SigningKey() (*Key, error) {
+	return nil, ErrNoSigningKey
+}
+
+// Keyfunc returns the public key matching the `kid` header of the token.
+func (rks *RemoteKeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok, fresh := rks.lookup(kid)
+	switch {
+	case !ok:
+		// Unknown kids are fetched at most once per minimum refresh interval.
+		if err := rks.Refresh(context.Background(), true); err != nil {
+			return nil, err
+		}
+		if key, ok, _ = rks.lookup(kid); !ok {
+			return nil, ErrKeyNotFound
+		}
+	case !fresh:
+		rks.refreshInBackground()
+	}
+
+	if key.alg != "" && key.alg != token.Method.Alg() {
+		return nil, ErrUnSupportSigningMethod
+	}
+	return key.pub, nil
+}
+
+// refreshInBackground refetches the JWKS without waiting for the result. A
+// failed fetch keeps the cached keys until the next attempt.
+func (rks *RemoteKeySet) refreshInBackground() {
+	rks.group.DoChan(rks.url, func() (any, error) {
+		return nil, rks.fetch(context.Background(), true)
+	})
+}
+
+// lookup returns the cached key with the given kid and whether the cache is fresh.
+func (rks *RemoteKeySet) lookup(kid string) (remoteKey, bool, bool) {
+	rks.mu.RLock()
+	defer rks.mu.RUnlock()
+
+	key, ok := rks.keys[kid]
+	return key, ok, time.Since(rks.fetchedAt) < rks.cacheTTL
+}
+
+// Refresh fetches the remote JWKS and replaces the cached keys. When
+// rateLimited is true the fetch is skipped if the previous attempt happened
+// within the minimum refresh interval. Concurrent calls share a single fetch.
+func (rks *RemoteKeySet) Refresh(ctx context.Context, rateLimited bool) error {
+	_, err, _ := rks.group.Do(rks.url, func() (any, error) {
+		return nil, rks.fetch(ctx, rateLimited)
+	})
+	return err
+}
+
+// fetch fetches the remote JWKS and replaces the cached keys.
+func (rks *RemoteKeySet) fetch(ctx context.Context, rateLimited bool) error {
+	rks.mu.Lock()
+	if rateLimited && time.Since(rks.lastAttempt) < rks.minRefreshInterval {
+		rks.mu.Unlock()
+		return ErrKeyNotFound
+	}
+	rks.lastAttempt = time.Now()
+	rks.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rks.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := rks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks from %s: unexpected status %d", rks.url, resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]remoteKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			// Skip keys we do not understand instead of failing the whole set.
+			continue
+		}
+		keys[jwk.Kid] = remoteKey{alg: jwk.Alg, pub: pub}
+	}
+
+	rks.mu.Lock()
+	rks.keys = keys
+	rks.fetchedAt = time.Now()
+	rks.mu.Unlock()
+
+	return nil
+}
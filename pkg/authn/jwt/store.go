@@ -9,6 +9,8 @@ package jwt
 import (
 	"context"
 	"time"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
 )
 
 // Storer token storage interface.
@@ -22,6 +24,38 @@ type Storer interface {
 	// Check if token exists.
 	Check(ctx context.Context, accessToken string) (bool, error)
 
+	// Store token data unless it already exists, atomically. It reports
+	// whether the token has been stored.
+	SetNX(ctx context.Context, accessToken string, expiration time.Duration) (bool, error)
+
 	// Close the storage.
 	Close() error
 }
+
+// SubjectRevoker is implemented by the storages which can revoke every token
+// of a subject at once. JWTAuth rejects the tokens of a subject which were
+// issued before its not-before timestamp.
+type SubjectRevoker interface {
+	// SetNotBefore stores the not-before timestamp of the subject.
+	SetNotBefore(ctx context.Context, subject string, notBefore time.Time, expiration time.Duration) error
+
+	// GetNotBefore returns the not-before timestamp of the subject, or the zero
+	// time if there is none.
+	GetNotBefore(ctx context.Context, subject string) (time.Time, error)
+}
+
+// SessionStorer is implemented by the storages which track the login
+// sessions of each subject.
+type SessionStorer interface {
+	// SaveSession creates or updates a session, it expires at session.ExpiresAt.
+	SaveSession(ctx context.Context, session *authn.Session) error
+
+	// ListSessions returns the unexpired sessions of the subject.
+	ListSessions(ctx context.Context, subject string) ([]*authn.Session, error)
+
+	// DeleteSession deletes a session of the subject.
+	DeleteSession(ctx context.Context, subject string, sessionID string) error
+
+	// DeleteSessions deletes every session of the subject.
+	DeleteSessions(ctx context.Context, subject string) error
+}
@@ -0,0 +1,264 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package gorm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+// RevokedToken represents a database record for a stored token. Only the
+// SHA-256 hash of the token is stored, so the key fits in an index.
+type RevokedToken struct {
+	KeyHash   string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// TableName returns the table name of the stored tokens.
+func (RevokedToken) TableName() string {
+	return "revoked_token"
+}
+
+// SubjectNotBefore represents a database record for the not-before
+// timestamp of a subject.
+type SubjectNotBefore struct {
+	Subject   string `gorm:"primaryKey;size:255"`
+	NotBefore time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// TableName returns the table name of the not-before timestamps.
+func (SubjectNotBefore) TableName() string {
+	return "subject_not_before"
+}
+
+// Session represents a database record for a login session.
+type Session struct {
+	ID         string `gorm:"primaryKey;size:64"`
+	Subject    string `gorm:"index;size:255"`
+	Device     string `gorm:"size:255"`
+	IP         string `gorm:"size:64"`
+	UserAgent  string `gorm:"size:512"`
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time `gorm:"index"`
+}
+
+// TableName returns the table name of the login sessions.
+func (Session) TableName() string {
+	return "token_session"
+}
+
+// Store gorm storage, for deployments without Redis.
+type Store struct {
+	db            *gorm.DB
+	purgeInterval time.Duration
+	stopCh        chan struct{}
+	stopOnce      sync.Once
+}
+
+// Option is gorm store option.
+type Option func(*Store)
+
+// WithPurgeInterval set how often expired tokens are deleted from the table
+// (default 10m). A non-positive interval disables purging.
+func WithPurgeInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.purgeInterval = interval
+	}
+}
+
+// NewStore create an *Store instance to handle token storage, deletion, and
+// checking. It migrates the token table and starts purging expired tokens.
+func NewStore(db *gorm.DB, opts ...Option) (*Store, error) {
+	s := &Store{
+		db:            db,
+		purgeInterval: 10 * time.Minute,
+		stopCh:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := db.AutoMigrate(&RevokedToken{}, &SubjectNotBefore{}, &Session{}); err != nil {
+		return nil, err
+	}
+
+	if s.purgeInterval > 0 {
+		go s.purgeLoop()
+	}
+
+	return s, nil
+}
+
+// hashKey returns the hex encoded SHA-256 hash of the token.
+func hashKey(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// Set stores the token until expiration has passed.
+func (s *Store) Set(ctx context.Context, accessToken string, expiration time.Duration) error {
+	token := &RevokedToken{KeyHash: hashKey(accessToken), ExpiresAt: time.Now().Add(expiration)}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(token).Error
+}
+
+// SetNX stores the token until expiration has passed, unless it is already
+// stored and has not expired. The primary key makes the insert atomic.
+func (s *Store) SetNX(ctx context.Context, accessToken string, expiration time.Duration) (bool, error) {
+	key := hashKey(accessToken)
+	db := s.db.WithContext(ctx)
+	if err := db.Delete(&RevokedToken{}, "key_hash = ? AND expires_at <= ?", key, time.Now()).Error; err != nil {
+		return false, err
+	}
+
+	token := &RevokedToken{KeyHash: key, ExpiresAt: time.Now().Add(expiration)}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(token)
+	if err := result.Error; err != nil {
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Delete deletes the specified token.
+func (s *Store) Delete(ctx context.Context, accessToken string) (bool, error) {
+	result := s.db.WithContext(ctx).Delete(&RevokedToken{}, "key_hash = ?", hashKey(accessToken))
+	if err := result.Error; err != nil {
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Check checks if the specified token exists and has not expired.
+func (s *Store) Check(ctx context.Context, accessToken string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&RevokedToken{}).
+		Where("key_hash = ? AND expires_at > ?", hashKey(accessToken), time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SetNotBefore stores the not-before timestamp of the subject.
+func (s *Store) SetNotBefore(ctx context.Context, subject string, notBefore time.Time, expiration time.Duration) error {
+	record := &SubjectNotBefore{Subject: subject, NotBefore: notBefore, ExpiresAt: time.Now().Add(expiration)}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"not_before", "expires_at"}),
+	}).Create(record).Error
+}
+
+// GetNotBefore returns the not-before timestamp of the subject.
+func (s *Store) GetNotBefore(ctx context.Context, subject string) (time.Time, error) {
+	var records []SubjectNotBefore
+	err := s.db.WithContext(ctx).Where("subject = ? AND expires_at > ?", subject, time.Now()).Limit(1).Find(&records).Error
+	if err != nil || len(records) == 0 {
+		return time.Time{}, err
+	}
+	return records[0].NotBefore, nil
+}
+
+// SaveSession creates or updates a session.
+func (s *Store) SaveSession(ctx context.Context, session *authn.Session) error {
+	record := &Session{
+		ID:         session.ID,
+		Subject:    session.Subject,
+		Device:     session.Device,
+		IP:         session.IP,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_used_at", "expires_at"}),
+	}).Create(record).Error
+}
+
+// ListSessions returns the unexpired sessions of the subject.
+func (s *Store) ListSessions(ctx context.Context, subject string) ([]*authn.Session, error) {
+	var records []Session
+	err := s.db.WithContext(ctx).Where("subject = ? AND expires_at > ?", subject, time.Now()).Order("created_at").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*authn.Session, 0, len(records))
+	for _, r := range records {
+		sessions = append(sessions, &authn.Session{
+			ID:         r.ID,
+			Subject:    r.Subject,
+			Device:     r.Device,
+			IP:         r.IP,
+			UserAgent:  r.UserAgent,
+			CreatedAt:  r.CreatedAt,
+			LastUsedAt: r.LastUsedAt,
+			ExpiresAt:  r.ExpiresAt,
+		})
+	}
+	return sessions, nil
+}
+
+// DeleteSession deletes a session of the subject.
+func (s *Store) DeleteSession(ctx context.Context, subject string, sessionID string) error {
+	return s.db.WithContext(ctx).Delete(&Session{}, "id = ? AND subject = ?", sessionID, subject).Error
+}
+
+// DeleteSessions deletes every session of the subject.
+func (s *Store) DeleteSessions(ctx context.Context, subject string) error {
+	return s.db.WithContext(ctx).Delete(&Session{}, "subject = ?", subject).Error
+}
+
+// Purge deletes the expired tokens, not-before timestamps and sessions.
+func (s *Store) Purge(ctx context.Context) error {
+	now := time.Now()
+	db := s.db.WithContext(ctx)
+	for _, model := range []any{&RevokedToken{}, &SubjectNotBefore{}, &Session{}} {
+		if err := db.Delete(model, "expires_at <= ?", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops purging expired tokens. The database connection is owned by
+// the caller and is not closed.
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	return nil
+}
+
+// purgeLoop periodically deletes the expired tokens.
+func (s *Store) purgeLoop() {
+	ticker := time.NewTicker(s.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			// A failed purge only keeps expired rows around until the next tick.
+			_ = s.Purge(context.Background())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+func newTestStore(t *testing.T) *Store {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to ":memory:" opens a new database.
+	sqlDB.SetMaxOpenConns(1)
+
+	s, err := NewStore(db, WithPurgeInterval(0))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	require.NoError(t, s.Set(ctx, "a", time.Hour))
+	require.NoError(t, s.Set(ctx, "b", time.Millisecond))
+	// Setting a stored token again extends it.
+	require.NoError(t, s.Set(ctx, "a", 2*time.Hour))
+
+	ok, err := s.Check(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	time.Sleep(5 * time.Millisecond)
+	ok, err = s.Check(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, ok, "expired tokens are not reported")
+
+	deleted, err := s.Delete(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	ok, err = s.Check(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	deleted, err = s.Delete(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestStoreSetNX(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	stored, err := s.SetNX(ctx, "a", 100*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, stored)
+
+	stored, err = s.SetNX(ctx, "a", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, stored)
+
+	// An expired token can be stored again.
+	time.Sleep(150 * time.Millisecond)
+	stored, err = s.SetNX(ctx, "a", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, stored)
+}
+
+func TestStoreNotBefore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	at, err := s.GetNotBefore(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, at.IsZero())
+
+	notBefore := time.Now().Truncate(time.Second)
+	require.NoError(t, s.SetNotBefore(ctx, "user-1", notBefore.Add(-time.Hour), time.Hour))
+	require.NoError(t, s.SetNotBefore(ctx, "user-1", notBefore, time.Hour))
+
+	at, err = s.GetNotBefore(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, at.Equal(notBefore))
+}
+
+func TestStoreSessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	now := time.Now()
+	for _, session := range []*authn.Session{
+		{ID: "s1", Subject: "user-1", Device: "phone", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "s2", Subject: "user-1", Device: "laptop", CreatedAt: now.Add(time.Second), ExpiresAt: now.Add(time.Hour)},
+		{ID: "s3", Subject: "user-1", CreatedAt: now, ExpiresAt: now.Add(-time.Second)},
+		{ID: "s4", Subject: "user-2", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	} {
+		require.NoError(t, s.SaveSession(ctx, session))
+	}
+
+	sessions, err := s.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "phone", sessions[0].Device)
+	assert.Equal(t, "laptop", sessions[1].Device)
+
+	require.NoError(t, s.DeleteSession(ctx, "user-1", "s1"))
+	sessions, err = s.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "s2", sessions[0].ID)
+
+	require.NoError(t, s.DeleteSessions(ctx, "user-1"))
+	sessions, err = s.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	sessions, err = s.ListSessions(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestStorePurge(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	require.NoError(t, s.Set(ctx, "a", -time.Second))
+	require.NoError(t, s.Set(ctx, "b", time.Hour))
+	require.NoError(t, s.SetNotBefore(ctx, "user-1", time.Now(), -time.Second))
+	require.NoError(t, s.Purge(ctx))
+
+	var count int64
+	require.NoError(t, s.db.Model(&RevokedToken{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	require.NoError(t, s.db.Model(&SubjectNotBefore{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,351 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package memory
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+// defaultCapacity is the default maximum number of entries kept in memory.
+const defaultCapacity = 100000
+
+// ErrStoreFull is returned when the store holds as many unexpired entries as
+// its capacity. Unexpired entries are never evicted, dropping a revoked token
+// would make it valid again.
+var ErrStoreFull = errors.New("memory store is full")
+
+// entry is a stored token, not-before timestamp or session and its expiration time.
+type entry struct {
+	key       string
+	subject   string // subject of a session entry, empty otherwise
+	kind      entryKind
+	expiresAt time.Time
+	index     int // index in the expiry heap
+}
+
+type entryKind int
+
+const (
+	kindToken entryKind = iota
+	kindNotBefore
+	kindSession
+)
+
+// notBefore is the not-before timestamp of a subject.
+type notBefore struct {
+	at    time.Time
+	entry *entry
+}
+
+// session is a stored login session.
+type session struct {
+	session authn.Session
+	entry   *entry
+}
+
+// Store is an in-memory token storage with per key TTL. Tokens, not-before
+// timestamps and sessions all count against the capacity, expired entries
+// are evicted to make room and ErrStoreFull is returned once every entry is
+// unexpired. It is meant for tests and single instance deployments, the
+// tokens are lost on restart.
+type Store struct {
+	mu       sync.Mutex
+	capacity int
+	expiry   expiryHeap // the entry which expires first is at the top
+
+	tokens    map[string]*entry
+	notBefore map[string]notBefore
+	sessions  map[string]map[string]session
+}
+
+// Option is memory store option.
+type Option func(*Store)
+
+// WithCapacity set the maximum number of entries kept in memory, a
+// non-positive capacity means no limit.
+func WithCapacity(capacity int) Option {
+	return func(s *Store) {
+		s.capacity = capacity
+	}
+}
+
+// NewStore create an *Store instance to handle token storage, deletion, and checking.
+func NewStore(opts ...Option) *Store {
+	s := &Store{
+		capacity:  defaultCapacity,
+		tokens:    make(map[string]*entry),
+		notBefore: make(map[string]notBefore),
+		sessions:  make(map[string]map[string]session),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Set stores the token until expiration has passed.
+func (s *Store) Set(ctx context.Context, accessToken string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.set(accessToken, expiration)
+}
+
+// SetNX stores the token until expiration has passed, unless it is already
+// stored and has not expired.
+func (s *Store) SetNX(ctx context.Context, accessToken string, expiration time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.tokens[accessToken]; ok && time.Now().Before(e.expiresAt) {
+		return false, nil
+	}
+	if err := s.set(accessToken, expiration); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete deletes the specified token.
+func (s *Store) Delete(ctx context.Context, accessToken string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.tokens[accessToken]
+	if !ok {
+		return false, nil
+	}
+	s.remove(e)
+	return true, nil
+}
+
+// Check checks if the specified token exists and has not expired.
+func (s *Store) Check(ctx context.Context, accessToken string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.tokens[accessToken]
+	if !ok {
+		return false, nil
+	}
+
+	if time.Now().After(e.expiresAt) {
+		s.remove(e)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Len returns the number of stored tokens, including expired tokens which
+// have not been evicted yet.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens)
+}
+
+// Close releases the stored tokens.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expiry = nil
+	s.tokens = make(map[string]*entry)
+	s.notBefore = make(map[string]notBefore)
+	s.sessions = make(map[string]map[string]session)
+	return nil
+}
+
+// SetNotBefore stores the not-before timestamp of the subject.
+func (s *Store) SetNotBefore(ctx context.Context, subject string, at time.Time, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(expiration)
+	if nb, ok := s.notBefore[subject]; ok {
+		s.notBefore[subject] = notBefore{at: at, entry: nb.entry}
+		s.touch(nb.entry, expiresAt)
+		return nil
+	}
+
+	e, err := s.add(&entry{key: subject, kind: kindNotBefore, expiresAt: expiresAt})
+	if err != nil {
+		return err
+	}
+	s.notBefore[subject] = notBefore{at: at, entry: e}
+	return nil
+}
+
+// GetNotBefore returns the not-before timestamp of the subject.
+func (s *Store) GetNotBefore(ctx context.Context, subject string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nb, ok := s.notBefore[subject]
+	if !ok {
+		return time.Time{}, nil
+	}
+	if time.Now().After(nb.entry.expiresAt) {
+		s.remove(nb.entry)
+		return time.Time{}, nil
+	}
+	return nb.at, nil
+}
+
+// SaveSession creates or updates a session.
+func (s *Store) SaveSession(ctx context.Context, sess *authn.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.sessions[sess.Subject][sess.ID]; ok {
+		s.sessions[sess.Subject][sess.ID] = session{session: *sess, entry: stored.entry}
+		s.touch(stored.entry, sess.ExpiresAt)
+		return nil
+	}
+
+	e, err := s.add(&entry{key: sess.ID, subject: sess.Subject, kind: kindSession, expiresAt: sess.ExpiresAt})
+	if err != nil {
+		return err
+	}
+
+	sessions, ok := s.sessions[sess.Subject]
+	if !ok {
+		sessions = make(map[string]session)
+		s.sessions[sess.Subject] = sessions
+	}
+	sessions[sess.ID] = session{session: *sess, entry: e}
+	return nil
+}
+
+// ListSessions returns the unexpired sessions of the subject.
+func (s *Store) ListSessions(ctx context.Context, subject string) ([]*authn.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	result := make([]*authn.Session, 0, len(s.sessions[subject]))
+	for _, stored := range s.sessions[subject] {
+		if now.After(stored.session.ExpiresAt) {
+			s.remove(stored.entry)
+			continue
+		}
+		result = append(result, &stored.session)
+	}
+	return result, nil
+}
+
+// DeleteSession deletes a session of the subject.
+func (s *Store) DeleteSession(ctx context.Context, subject string, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.sessions[subject][sessionID]; ok {
+		s.remove(stored.entry)
+	}
+	return nil
+}
+
+// DeleteSessions deletes every session of the subject.
+func (s *Store) DeleteSessions(ctx context.Context, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.sessions[subject] {
+		s.remove(stored.entry)
+	}
+	return nil
+}
+
+// set stores or updates a token.
+func (s *Store) set(accessToken string, expiration time.Duration) error {
+	expiresAt := time.Now().Add(expiration)
+	if e, ok := s.tokens[accessToken]; ok {
+		s.touch(e, expiresAt)
+		return nil
+	}
+
+	e, err := s.add(&entry{key: accessToken, kind: kindToken, expiresAt: expiresAt})
+	if err != nil {
+		return err
+	}
+	s.tokens[accessToken] = e
+	return nil
+}
+
+// add adds a new entry to the expiry heap, evicting expired entries when the
+// capacity is reached.
+func (s *Store) add(e *entry) (*entry, error) {
+	if s.capacity > 0 && len(s.expiry) >= s.capacity {
+		now := time.Now()
+		for len(s.expiry) > 0 && now.After(s.expiry[0].expiresAt) {
+			s.remove(s.expiry[0])
+		}
+		if len(s.expiry) >= s.capacity {
+			return nil, ErrStoreFull
+		}
+	}
+
+	heap.Push(&s.expiry, e)
+	return e, nil
+}
+
+// touch updates the expiration time of a stored entry.
+func (s *Store) touch(e *entry, expiresAt time.Time) {
+	e.expiresAt = expiresAt
+	heap.Fix(&s.expiry, e.index)
+}
+
+// remove removes an entry from the expiry heap and from the map holding it.
+func (s *Store) remove(e *entry) {
+	heap.Remove(&s.expiry, e.index)
+
+	switch e.kind {
+	case kindToken:
+		delete(s.tokens, e.key)
+	case kindNotBefore:
+		delete(s.notBefore, e.key)
+	case kindSession:
+		delete(s.sessions[e.subject], e.key)
+		if len(s.sessions[e.subject]) == 0 {
+			delete(s.sessions, e.subject)
+		}
+	}
+}
+
+// expiryHeap is a min-heap of entries ordered by expiration time.
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(WithCapacity(2))
+
+	require.NoError(t, s.Set(ctx, "a", time.Hour))
+	require.NoError(t, s.Set(ctx, "b", time.Millisecond))
+
+	ok, _ := s.Check(ctx, "a")
+	assert.True(t, ok)
+
+	time.Sleep(5 * time.Millisecond)
+	ok, _ = s.Check(ctx, "b")
+	assert.False(t, ok, "expired tokens are not reported")
+
+	// Unexpired tokens are never evicted, the store is full.
+	require.NoError(t, s.Set(ctx, "c", time.Hour))
+	assert.ErrorIs(t, s.Set(ctx, "d", time.Hour), ErrStoreFull)
+	assert.Equal(t, 2, s.Len())
+	ok, _ = s.Check(ctx, "a")
+	assert.True(t, ok)
+
+	deleted, _ := s.Delete(ctx, "c")
+	assert.True(t, deleted)
+	ok, _ = s.Check(ctx, "c")
+	assert.False(t, ok)
+	require.NoError(t, s.Set(ctx, "d", time.Hour))
+}
+
+func TestStoreEvictsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(WithCapacity(3))
+
+	require.NoError(t, s.Set(ctx, "a", 100*time.Millisecond))
+	require.NoError(t, s.SetNotBefore(ctx, "user-1", time.Now(), 100*time.Millisecond))
+	require.NoError(t, s.SaveSession(ctx, &authn.Session{ID: "s1", Subject: "user-1", ExpiresAt: time.Now().Add(100 * time.Millisecond)}))
+	assert.ErrorIs(t, s.Set(ctx, "b", time.Hour), ErrStoreFull)
+
+	// Every kind of entry counts against the capacity and is evicted once expired.
+	time.Sleep(150 * time.Millisecond)
+	require.NoError(t, s.Set(ctx, "b", time.Hour))
+	require.NoError(t, s.SetNotBefore(ctx, "user-2", time.Now(), time.Hour))
+	require.NoError(t, s.SaveSession(ctx, &authn.Session{ID: "s2", Subject: "user-2", ExpiresAt: time.Now().Add(time.Hour)}))
+	assert.Equal(t, 1, s.Len())
+	assert.Empty(t, s.notBefore["user-1"])
+	assert.NotContains(t, s.sessions, "user-1")
+
+	// Updating an entry does not take more room.
+	require.NoError(t, s.SaveSession(ctx, &authn.Session{ID: "s2", Subject: "user-2", ExpiresAt: time.Now().Add(2 * time.Hour)}))
+	require.NoError(t, s.DeleteSessions(ctx, "user-2"))
+	sessions, err := s.ListSessions(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	require.NoError(t, s.Set(ctx, "c", time.Hour))
+}
+
+func TestStoreSetNX(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+
+	stored, err := s.SetNX(ctx, "a", 100*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, stored)
+
+	stored, _ = s.SetNX(ctx, "a", time.Hour)
+	assert.False(t, stored)
+
+	// An expired token can be stored again.
+	time.Sleep(150 * time.Millisecond)
+	stored, _ = s.SetNX(ctx, "a", time.Hour)
+	assert.True(t, stored)
+}
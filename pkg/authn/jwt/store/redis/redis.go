@@ -8,10 +8,15 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
 )
 
 // Config contains necessary redis options.
@@ -44,6 +49,11 @@ func NewStore(cfg *Config) *Store {
 	return &Store{cli: cli, prefix: cfg.KeyPrefix}
 }
 
+// NewStoreFromClient create an *Store instance on top of an existing redis client.
+func NewStoreFromClient(cli *redis.Client, keyPrefix string) *Store {
+	return &Store{cli: cli, prefix: keyPrefix}
+}
+
 // wrapperKey is used to build the key name in Redis.
 func (s *Store) wrapperKey(key string) string {
 	return fmt.Sprintf("%s%s", s.prefix, key)
@@ -56,6 +66,12 @@ func (s *Store) Set(ctx context.Context, accessToken string, expiration time.Dur
 	return cmd.Err()
 }
 
+// SetNX call the Redis client to set the key-value pair only if the key
+// does not exist yet.
+func (s *Store) SetNX(ctx context.Context, accessToken string, expiration time.Duration) (bool, error) {
+	return s.cli.SetNX(ctx, s.wrapperKey(accessToken), "1", expiration).Result()
+}
+
 // Delete delete the specified JWT Token in Redis.
 func (s *Store) Delete(ctx context.Context, accessToken string) (bool, error) {
 	cmd := s.cli.Del(ctx, s.wrapperKey(accessToken))
@@ -78,3 +94,80 @@ func (s *Store) Check(ctx context.Context, accessToken string) (bool, error) {
 func (s *Store) Close() error {
 	return s.cli.Close()
 }
+
+// SetNotBefore stores the not-before timestamp of the subject, where the key
+// name format is <prefix>nb:<subject>.
+func (s *Store) SetNotBefore(ctx context.Context, subject string, notBefore time.Time, expiration time.Duration) error {
+	return s.cli.Set(ctx, s.wrapperKey("nb:"+subject), notBefore.UnixNano(), expiration).Err()
+}
+
+// GetNotBefore returns the not-before timestamp of the subject.
+func (s *Store) GetNotBefore(ctx context.Context, subject string) (time.Time, error) {
+	val, err := s.cli.Get(ctx, s.wrapperKey("nb:"+subject)).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	nanos, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// sessionsKey returns the name of the hash holding the sessions of a subject.
+func (s *Store) sessionsKey(subject string) string {
+	return s.wrapperKey("sessions:" + subject)
+}
+
+// SaveSession stores the session in the hash <prefix>sessions:<subject>. The
+// hash expires together with the most recently saved session.
+func (s *Store) SaveSession(ctx context.Context, session *authn.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	key := s.sessionsKey(session.Subject)
+	_, err = s.cli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, session.ID, data)
+		pipe.ExpireAt(ctx, key, session.ExpiresAt)
+		return nil
+	})
+	return err
+}
+
+// ListSessions returns the unexpired sessions of the subject.
+func (s *Store) ListSessions(ctx context.Context, subject string) ([]*authn.Session, error) {
+	vals, err := s.cli.HGetAll(ctx, s.sessionsKey(subject)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]*authn.Session, 0, len(vals))
+	for _, val := range vals {
+		var session authn.Session
+		if err := json.Unmarshal([]byte(val), &session); err != nil {
+			return nil, err
+		}
+		if now.After(session.ExpiresAt) {
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+// DeleteSession deletes a session of the subject.
+func (s *Store) DeleteSession(ctx context.Context, subject string, sessionID string) error {
+	return s.cli.HDel(ctx, s.sessionsKey(subject), sessionID).Err()
+}
+
+// DeleteSessions deletes every session of the subject.
+func (s *Store) DeleteSessions(ctx context.Context, subject string) error {
+	return s.cli.Del(ctx, s.sessionsKey(subject)).Err()
+}
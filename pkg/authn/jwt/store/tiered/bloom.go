@@ -0,0 +1,77 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package tiered
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// bloomFilter is a concurrency safe bloom filter. It never reports a false
+// negative, false positives happen at the configured rate.
+type bloomFilter struct {
+	mu   sync.RWMutex
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+// newBloomFilter creates a bloom filter sized for n items with false positive rate p.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	m = (m + 63) / 64 * 64
+
+	return &bloomFilter{bits: make([]uint64, m/64), m: m, k: k}
+}
+
+// locations returns the k bit positions of key using double hashing.
+func (f *bloomFilter) locations(key string) []uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+
+	locs := make([]uint64, f.k)
+	for i := uint64(0); i < f.k; i++ {
+		locs[i] = (h1 + i*h2) % f.m
+	}
+	return locs
+}
+
+// Add adds key to the filter.
+func (f *bloomFilter) Add(key string) {
+	locs := f.locations(key)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, loc := range locs {
+		f.bits[loc/64] |= 1 << (loc % 64)
+	}
+}
+
+// MayContain reports whether key may have been added to the filter.
+func (f *bloomFilter) MayContain(key string) bool {
+	locs := f.locations(key)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, loc := range locs {
+		if f.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package tiered
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomFilter(t *testing.T) {
+	const n = 10000
+	f := newBloomFilter(n, 0.01)
+
+	for i := 0; i < n; i++ {
+		f.Add("token-" + strconv.Itoa(i))
+	}
+	for i := 0; i < n; i++ {
+		assert.True(t, f.MayContain("token-"+strconv.Itoa(i)))
+	}
+
+	falsePositives := 0
+	for i := n; i < 2*n; i++ {
+		if f.MayContain("token-" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, n/20)
+}
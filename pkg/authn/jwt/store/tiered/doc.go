@@ -0,0 +1,7 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package tiered // import "github.com/ydcloud-dy/publicPkg/pkg/authn/jwt/store/tiered"
@@ -0,0 +1,301 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package tiered
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	redisstore "github.com/ydcloud-dy/publicPkg/pkg/authn/jwt/store/redis"
+)
+
+// ErrKeyPrefixRequired is returned by NewStore when Config.KeyPrefix is empty.
+var ErrKeyPrefixRequired = errors.New("tiered store requires a key prefix")
+
+// Config contains necessary tiered store options.
+type Config struct {
+	Addr     string
+	Username string
+	Password string
+	Database int
+	// Sore key prefix, it is required. The bloom filter is rebuilt by
+	// scanning the keys with this prefix, so it should not be shared with
+	// other data.
+	KeyPrefix string
+	// Channel is the pub/sub channel revocations are broadcast on.
+	Channel string
+	// ExpectedItems is the number of revoked tokens the bloom filter is sized for.
+	ExpectedItems int
+	// FalsePositiveRate is the rate of checks which still hit Redis although
+	// the token is not revoked.
+	FalsePositiveRate float64
+	// RebuildInterval is how often the bloom filter is rebuilt from Redis, to
+	// drop expired tokens and repair missed pub/sub messages.
+	RebuildInterval time.Duration
+}
+
+// Store is a two tier token storage. A local bloom filter answers most
+// checks without a network hop, only tokens which may be revoked are checked
+// in Redis. Revocations are broadcast through Redis pub/sub so that every
+// instance adds them to its filter.
+//
+// A revocation whose pub/sub message is lost is only seen by other instances
+// after the next rebuild of their filter.
+type Store struct {
+	cli     *redis.Client
+	remote  *redisstore.Store
+	prefix  string
+	channel string
+	cfg     Config
+
+	mu         sync.RWMutex
+	filter     *bloomFilter
+	rebuilding bool
+	pending    []string
+
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewStore create an *Store instance to handle token storage, deletion, and
+// checking. It subscribes to the revocation channel and loads the revoked
+// tokens from Redis before returning.
+func NewStore(cfg *Config) (*Store, error) {
+	c := *cfg
+	if c.KeyPrefix == "" {
+		// An empty prefix would scan the whole keyspace on every rebuild.
+		return nil, ErrKeyPrefixRequired
+	}
+	if c.Channel == "" {
+		c.Channel = c.KeyPrefix + "revoked"
+	}
+	if c.ExpectedItems <= 0 {
+		c.ExpectedItems = 100000
+	}
+	if c.FalsePositiveRate <= 0 {
+		c.FalsePositiveRate = 0.001
+	}
+	if c.RebuildInterval <= 0 {
+		c.RebuildInterval = time.Minute
+	}
+
+	cli := redis.NewClient(&redis.Options{
+		Addr:     c.Addr,
+		DB:       c.Database,
+		Username: c.Username,
+		Password: c.Password,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Store{
+		cli:     cli,
+		remote:  redisstore.NewStoreFromClient(cli, c.KeyPrefix),
+		prefix:  c.KeyPrefix,
+		channel: c.Channel,
+		cfg:     c,
+		filter:  newBloomFilter(c.ExpectedItems, c.FalsePositiveRate),
+		cancel:  cancel,
+	}
+
+	// Subscribe before loading the filter, so no revocation falls in between.
+	s.pubsub = cli.Subscribe(ctx, c.Channel)
+	if _, err := s.pubsub.Receive(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	if err := s.rebuild(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	s.wg.Add(2)
+	go s.listen()
+	go s.rebuildLoop(ctx)
+
+	return s, nil
+}
+
+// Set revokes the token in Redis and broadcasts it to the other instances.
+func (s *Store) Set(ctx context.Context, accessToken string, expiration time.Duration) error {
+	if err := s.remote.Set(ctx, accessToken, expiration); err != nil {
+		return err
+	}
+
+	s.add(accessToken)
+	return s.cli.Publish(ctx, s.channel, accessToken).Err()
+}
+
+// SetNX revokes the token in Redis unless it is already revoked, and
+// broadcasts it to the other instances.
+func (s *Store) SetNX(ctx context.Context, accessToken string, expiration time.Duration) (bool, error) {
+	stored, err := s.remote.SetNX(ctx, accessToken, expiration)
+	if err != nil || !stored {
+		return false, err
+	}
+
+	s.add(accessToken)
+	return true, s.cli.Publish(ctx, s.channel, accessToken).Err()
+}
+
+// Delete deletes the token from Redis. The local filters keep reporting the
+// token as possibly revoked until they are rebuilt, Check then asks Redis.
+func (s *Store) Delete(ctx context.Context, accessToken string) (bool, error) {
+	return s.remote.Delete(ctx, accessToken)
+}
+
+// Check checks if the token is revoked. Redis is only asked when the local
+// bloom filter reports that the token may be revoked.
+func (s *Store) Check(ctx context.Context, accessToken string) (bool, error) {
+	s.mu.RLock()
+	mayContain := s.filter.MayContain(accessToken)
+	s.mu.RUnlock()
+
+	if !mayContain {
+		return false, nil
+	}
+	return s.remote.Check(ctx, accessToken)
+}
+
+// SetNotBefore stores the not-before timestamp of the subject in Redis and
+// broadcasts it to the other instances.
+func (s *Store) SetNotBefore(ctx context.Context, subject string, notBefore time.Time, expiration time.Duration) error {
+	if err := s.remote.SetNotBefore(ctx, subject, notBefore, expiration); err != nil {
+		return err
+	}
+
+	key := notBeforeKey(subject)
+	s.add(key)
+	return s.cli.Publish(ctx, s.channel, key).Err()
+}
+
+// GetNotBefore returns the not-before timestamp of the subject. Redis is only
+// asked when the local bloom filter reports that the subject may have one.
+func (s *Store) GetNotBefore(ctx context.Context, subject string) (time.Time, error) {
+	s.mu.RLock()
+	mayContain := s.filter.MayContain(notBeforeKey(subject))
+	s.mu.RUnlock()
+
+	if !mayContain {
+		return time.Time{}, nil
+	}
+	return s.remote.GetNotBefore(ctx, subject)
+}
+
+// notBeforeKey returns the filter key of the not-before timestamp of a
+// subject. It matches the Redis key name of the timestamp without prefix.
+func notBeforeKey(subject string) string {
+	return "nb:" + subject
+}
+
+// SaveSession stores the session in Redis.
+func (s *Store) SaveSession(ctx context.Context, session *authn.Session) error {
+	return s.remote.SaveSession(ctx, session)
+}
+
+// ListSessions returns the unexpired sessions of the subject from Redis.
+func (s *Store) ListSessions(ctx context.Context, subject string) ([]*authn.Session, error) {
+	return s.remote.ListSessions(ctx, subject)
+}
+
+// DeleteSession deletes a session of the subject from Redis.
+func (s *Store) DeleteSession(ctx context.Context, subject string, sessionID string) error {
+	return s.remote.DeleteSession(ctx, subject, sessionID)
+}
+
+// DeleteSessions deletes every session of the subject from Redis.
+func (s *Store) DeleteSessions(ctx context.Context, subject string) error {
+	return s.remote.DeleteSessions(ctx, subject)
+}
+
+// Close stops the background goroutines and closes the redis client.
+func (s *Store) Close() error {
+	s.cancel()
+	if s.pubsub != nil {
+		_ = s.pubsub.Close()
+	}
+	s.wg.Wait()
+	return s.cli.Close()
+}
+
+// add adds a revoked token to the local filter.
+func (s *Store) add(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filter.Add(accessToken)
+	if s.rebuilding {
+		s.pending = append(s.pending, accessToken)
+	}
+}
+
+// listen adds the tokens revoked by other instances to the local filter.
+func (s *Store) listen() {
+	defer s.wg.Done()
+
+	for msg := range s.pubsub.Channel() {
+		s.add(msg.Payload)
+	}
+}
+
+// rebuildLoop periodically rebuilds the local filter until ctx is done.
+func (s *Store) rebuildLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.RebuildInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A failed rebuild keeps the current filter, which never misses a
+			// revocation it has seen.
+			_ = s.rebuild(ctx)
+		}
+	}
+}
+
+// rebuild builds a new filter from the revoked tokens stored in Redis.
+func (s *Store) rebuild(ctx context.Context) error {
+	s.mu.Lock()
+	s.rebuilding = true
+	s.pending = nil
+	s.mu.Unlock()
+
+	filter := newBloomFilter(s.cfg.ExpectedItems, s.cfg.FalsePositiveRate)
+	iter := s.cli.Scan(ctx, 0, s.prefix+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		filter.Add(strings.TrimPrefix(iter.Val(), s.prefix))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rebuilding = false
+	if err := iter.Err(); err != nil {
+		s.pending = nil
+		return err
+	}
+
+	// Tokens revoked while scanning may be missing from the scan result.
+	for _, token := range s.pending {
+		filter.Add(token)
+	}
+	s.pending = nil
+	s.filter = filter
+
+	return nil
+}
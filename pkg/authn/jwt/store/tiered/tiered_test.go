@@ -0,0 +1,318 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package tiered
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a minimal RESP2 server which implements the commands used by
+// the tiered store.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu          sync.Mutex
+	values      map[string]string
+	expiresAt   map[string]time.Time
+	subscribers map[string][]*fakeConn
+	scans       []string
+	conns       []net.Conn
+}
+
+// fakeConn serializes the replies and the published messages of a connection.
+type fakeConn struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func (c *fakeConn) write(reply string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = c.w.WriteString(reply)
+	_ = c.w.Flush()
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	r := &fakeRedis{
+		ln:          ln,
+		values:      make(map[string]string),
+		expiresAt:   make(map[string]time.Time),
+		subscribers: make(map[string][]*fakeConn),
+	}
+	go r.serve()
+	t.Cleanup(r.close)
+	return r
+}
+
+func (r *fakeRedis) addr() string { return r.ln.Addr().String() }
+
+func (r *fakeRedis) close() {
+	_ = r.ln.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, conn := range r.conns {
+		_ = conn.Close()
+	}
+}
+
+func (r *fakeRedis) serve() {
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		r.conns = append(r.conns, conn)
+		r.mu.Unlock()
+		go r.handle(conn)
+	}
+}
+
+func (r *fakeRedis) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	c := &fakeConn{w: bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		c.write(r.exec(c, args))
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+func integer(n int) string { return fmt.Sprintf(":%d\r\n", n) }
+
+func (r *fakeRedis) exec(c *fakeConn, args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		var nx bool
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "EX":
+				i++
+				n, _ := strconv.Atoi(args[i])
+				ttl = time.Duration(n) * time.Second
+			case "PX":
+				i++
+				n, _ := strconv.Atoi(args[i])
+				ttl = time.Duration(n) * time.Millisecond
+			}
+		}
+		if _, ok := r.get(args[1]); nx && ok {
+			return "$-1\r\n"
+		}
+		r.values[args[1]] = args[2]
+		delete(r.expiresAt, args[1])
+		if ttl > 0 {
+			r.expiresAt[args[1]] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "GET":
+		if val, ok := r.get(args[1]); ok {
+			return bulk(val)
+		}
+		return "$-1\r\n"
+	case "EXISTS":
+		var n int
+		for _, key := range args[1:] {
+			if _, ok := r.get(key); ok {
+				n++
+			}
+		}
+		return integer(n)
+	case "DEL":
+		var n int
+		for _, key := range args[1:] {
+			if _, ok := r.get(key); ok {
+				n++
+			}
+			delete(r.values, key)
+		}
+		return integer(n)
+	case "SCAN":
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		r.scans = append(r.scans, pattern)
+
+		var keys []string
+		for key := range r.values {
+			if _, ok := r.get(key); ok && strings.HasPrefix(key, strings.TrimSuffix(pattern, "*")) {
+				keys = append(keys, bulk(key))
+			}
+		}
+		return "*2\r\n" + bulk("0") + fmt.Sprintf("*%d\r\n", len(keys)) + strings.Join(keys, "")
+	case "SUBSCRIBE":
+		var reply string
+		for i, channel := range args[1:] {
+			r.subscribers[channel] = append(r.subscribers[channel], c)
+			reply += "*3\r\n" + bulk("subscribe") + bulk(channel) + integer(i+1)
+		}
+		return reply
+	case "PUBLISH":
+		subscribers := r.subscribers[args[1]]
+		for _, sub := range subscribers {
+			go sub.write("*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2]))
+		}
+		return integer(len(subscribers))
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+// get returns the unexpired value of the key, r.mu must be held.
+func (r *fakeRedis) get(key string) (string, bool) {
+	val, ok := r.values[key]
+	if expiresAt, expires := r.expiresAt[key]; ok && expires && time.Now().After(expiresAt) {
+		delete(r.values, key)
+		delete(r.expiresAt, key)
+		return "", false
+	}
+	return val, ok
+}
+
+func newTestStore(t *testing.T, addr string) *Store {
+	s, err := NewStore(&Config{Addr: addr, KeyPrefix: "jwt:", RebuildInterval: time.Hour})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestNewStoreRequiresKeyPrefix(t *testing.T) {
+	_, err := NewStore(&Config{Addr: newFakeRedis(t).addr()})
+	assert.ErrorIs(t, err, ErrKeyPrefixRequired)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t)
+	a := newTestStore(t, server.addr())
+	b := newTestStore(t, server.addr())
+
+	require.NoError(t, a.Set(ctx, "token-1", time.Hour))
+	ok, err := a.Check(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// The revocation is broadcast to the other instance.
+	assert.Eventually(t, func() bool {
+		ok, _ := b.Check(ctx, "token-1")
+		return ok
+	}, time.Second, time.Millisecond)
+
+	ok, err = b.Check(ctx, "token-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	stored, err := b.SetNX(ctx, "token-1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, stored)
+	stored, err = b.SetNX(ctx, "token-2", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, stored)
+
+	notBefore := time.Now()
+	require.NoError(t, a.SetNotBefore(ctx, "user-1", notBefore, time.Hour))
+	assert.Eventually(t, func() bool {
+		at, _ := b.GetNotBefore(ctx, "user-1")
+		return at.Equal(notBefore)
+	}, time.Second, time.Millisecond)
+
+	at, err := b.GetNotBefore(ctx, "user-2")
+	require.NoError(t, err)
+	assert.True(t, at.IsZero())
+
+	deleted, err := a.Delete(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	ok, err = b.Check(ctx, "token-1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestStoreRebuild(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t)
+	s := newTestStore(t, server.addr())
+
+	// A revocation whose message is missed is seen after the next rebuild.
+	cli := redis.NewClient(&redis.Options{Addr: server.addr()})
+	defer cli.Close()
+	require.NoError(t, cli.Set(ctx, "jwt:token-1", "1", time.Hour).Err())
+
+	ok, err := s.Check(ctx, "token-1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.rebuild(ctx))
+	ok, err = s.Check(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Only the keys of the store are scanned.
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, []string{"jwt:*", "jwt:*"}, server.scans)
+}
@@ -8,6 +8,8 @@ package jwt
 
 import (
 	"encoding/json"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
 )
 
 // tokenInfo contains token information.
@@ -20,6 +22,15 @@ type tokenInfo struct {
 
 	// Token expiration time
 	ExpiresAt int64 `json:"expiresAt"`
+
+	// Refresh token string.
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	// Refresh token expiration time.
+	RefreshExpiresAt int64 `json:"refreshExpiresAt,omitempty"`
+
+	// claims of the access token, used to record its session.
+	claims *authn.Claims
 }
 
 func (t *tokenInfo) GetToken() string {
@@ -34,6 +45,14 @@ func (t *tokenInfo) GetExpiresAt() int64 {
 	return t.ExpiresAt
 }
 
+func (t *tokenInfo) GetRefreshToken() string {
+	return t.RefreshToken
+}
+
+func (t *tokenInfo) GetRefreshExpiresAt() int64 {
+	return t.RefreshExpiresAt
+}
+
 func (t *tokenInfo) EncodeToJSON() ([]byte, error) {
 	return json.Marshal(t)
 }
@@ -0,0 +1,10 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+// Package middleware wires an authn.Authenticator into gin, gRPC and kratos
+// request pipelines. Authenticated claims are stored in the request context
+// and can be read back with authn.ClaimsFromContext.
+package middleware // import "github.com/ydcloud-dy/publicPkg/pkg/authn/middleware"
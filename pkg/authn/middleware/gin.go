@@ -0,0 +1,39 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/onexstack/onexstack/pkg/core"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+// Gin returns a gin middleware which authenticates requests with a.
+func Gin(a authn.Authenticator, opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts...)
+
+	return func(c *gin.Context) {
+		if o.skip(c.Request.Context(), c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		ctx, claims, err := authenticate(c.Request.Context(), a, c.GetHeader("Authorization"))
+		if err != nil {
+			core.WriteResponse(c, nil, err)
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(ClaimsKey, claims)
+		c.Set(UserIDKey, claims.Subject)
+		c.Next()
+	}
+}
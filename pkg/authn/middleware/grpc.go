@@ -0,0 +1,68 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+// UnaryServerInterceptor returns a gRPC unary interceptor which authenticates requests with a.
+func UnaryServerInterceptor(a authn.Authenticator, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts...)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if o.skip(ctx, info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, _, err := authenticate(ctx, a, authorizationFromMD(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC stream interceptor which authenticates streams with a.
+func StreamServerInterceptor(a authn.Authenticator, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts...)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if o.skip(ss.Context(), info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		ctx, _, err := authenticate(ss.Context(), a, authorizationFromMD(ss.Context()))
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorizationFromMD returns the authorization header of the incoming metadata.
+func authorizationFromMD(ctx context.Context) string {
+	if vals := metadata.ValueFromIncomingContext(ctx, "authorization"); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package middleware
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+// Kratos returns a kratos middleware which authenticates requests with a.
+func Kratos(a authn.Authenticator, opts ...Option) middleware.Middleware {
+	o := newOptions(opts...)
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req any) (any, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return nil, unauthenticated("Missing transport information.")
+			}
+
+			if o.skip(ctx, tr.Operation()) {
+				return handler(ctx, req)
+			}
+
+			ctx, _, err := authenticate(ctx, a, tr.RequestHeader().Get("Authorization"))
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/onexstack/onexstack/pkg/errorsx"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+const (
+	// ClaimsKey is the gin context key the authenticated claims are stored under.
+	ClaimsKey = "X-Claims"
+	// UserIDKey is the gin context key the authenticated subject is stored under.
+	UserIDKey = "X-User-ID"
+
+	// bearerScheme is the authorization scheme carrying the token.
+	bearerScheme = "Bearer"
+)
+
+// Option is middleware option.
+type Option func(*options)
+
+type options struct {
+	operations map[string]struct{}
+	prefixes   []string
+	skipper    func(ctx context.Context, operation string) bool
+}
+
+// WithSkipOperations skips authentication for the given operations. An
+// operation is the route template for gin (e.g. `/v1/users/:id`) and the
+// full method name for gRPC and kratos (e.g. `/api.v1.Auth/Login`). An
+// operation ending with `*` matches every operation with that prefix.
+func WithSkipOperations(operations ...string) Option {
+	return func(o *options) {
+		for _, op := range operations {
+			if prefix, ok := strings.CutSuffix(op, "*"); ok {
+				o.prefixes = append(o.prefixes, prefix)
+				continue
+			}
+			o.operations[op] = struct{}{}
+		}
+	}
+}
+
+// WithSkipper set a function which decides whether a request skips authentication.
+func WithSkipper(skipper func(ctx context.Context, operation string) bool) Option {
+	return func(o *options) {
+		o.skipper = skipper
+	}
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{operations: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// skip reports whether the operation skips authentication.
+func (o *options) skip(ctx context.Context, operation string) bool {
+	if _, ok := o.operations[operation]; ok {
+		return true
+	}
+	for _, prefix := range o.prefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return o.skipper != nil && o.skipper(ctx, operation)
+}
+
+// authenticate parses the bearer token of the authorization header and
+// returns a context carrying the claims.
+func authenticate(ctx context.Context, a authn.Authenticator, header string) (context.Context, *authn.Claims, error) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, bearerScheme) || token == "" {
+		return nil, nil, unauthenticated("Missing or malformed bearer token.")
+	}
+
+	claims, err := a.ParseClaims(ctx, token)
+	if err != nil {
+		return nil, nil, unauthenticated(errorsx.FromError(err).Message)
+	}
+
+	return authn.WithClaims(ctx, claims), claims, nil
+}
+
+// unauthenticated returns a copy of errorsx.ErrUnauthenticated with the
+// given message, the shared error must not be modified.
+func unauthenticated(message string) *errorsx.ErrorX {
+	return errorsx.New(errorsx.ErrUnauthenticated.Code, errorsx.ErrUnauthenticated.Reason, "%s", message)
+}
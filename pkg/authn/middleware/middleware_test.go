@@ -0,0 +1,93 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authn/jwt"
+)
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	auth := jwt.New(nil)
+	pair, err := auth.Sign(context.Background(), "user-1")
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(Gin(auth, WithSkipOperations("/healthz", "/public/*")))
+	handler := func(c *gin.Context) {
+		c.String(http.StatusOK, authn.SubjectFromContext(c.Request.Context()))
+	}
+	r.GET("/healthz", handler)
+	r.GET("/public/info", handler)
+	r.GET("/me", handler)
+
+	tests := []struct {
+		path   string
+		header string
+		code   int
+		body   string
+	}{
+		{path: "/healthz", code: http.StatusOK},
+		{path: "/public/info", code: http.StatusOK},
+		{path: "/me", code: http.StatusUnauthorized},
+		{path: "/me", header: "Bearer invalid", code: http.StatusUnauthorized},
+		{path: "/me", header: "Bearer " + pair.GetToken(), code: http.StatusOK, body: "user-1"},
+		{path: "/me", header: "Bearer " + pair.GetRefreshToken(), code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.code, w.Code, tt.path)
+		if tt.body != "" {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	auth := jwt.New(nil)
+	pair, err := auth.Sign(context.Background(), "user-1")
+	require.NoError(t, err)
+
+	interceptor := UnaryServerInterceptor(auth, WithSkipOperations("/api.v1.Auth/Login"))
+	handler := func(ctx context.Context, req any) (any, error) {
+		return authn.SubjectFromContext(ctx), nil
+	}
+
+	ctx := context.Background()
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.Auth/Login"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "", resp)
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/Get"}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+pair.GetToken()))
+	resp, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/Get"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", resp)
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package authn
+
+import (
+	"context"
+	"errors"
+)
+
+// errNoAuthenticator is returned by Multi when no authenticator accepts a token.
+var errNoAuthenticator = errors.New("no authenticator accepts the token")
+
+// TokenMatcher is implemented by authenticators which recognize their
+// tokens by format, such as prefixed API keys.
+type TokenMatcher interface {
+	// MatchToken reports whether the token was issued by the authenticator.
+	MatchToken(token string) bool
+}
+
+// Multi is an Authenticator accepting the tokens of several authenticators,
+// e.g. JWTs and API keys. A token is handled by the first authenticator
+// implementing TokenMatcher which matches it, otherwise by the first
+// authenticator which does not implement TokenMatcher.
+type Multi struct {
+	authenticators []Authenticator
+}
+
+// Ensure Multi implements the Authenticator interface.
+var _ Authenticator = (*Multi)(nil)
+
+// NewMulti creates a Multi. Sign and Refresh use the first authenticator.
+func NewMulti(authenticators ...Authenticator) *Multi {
+	return &Multi{authenticators: authenticators}
+}
+
+// Sign signs a token with the first authenticator.
+func (m *Multi) Sign(ctx context.Context, userID string, opts ...ClaimsOption) (IToken, error) {
+	if len(m.authenticators) == 0 {
+		return nil, errNoAuthenticator
+	}
+	return m.authenticators[0].Sign(ctx, userID, opts...)
+}
+
+// Refresh refreshes a token with the first authenticator.
+func (m *Multi) Refresh(ctx context.Context, refreshToken string) (IToken, error) {
+	if len(m.authenticators) == 0 {
+		return nil, errNoAuthenticator
+	}
+	return m.authenticators[0].Refresh(ctx, refreshToken)
+}
+
+// Destroy destroys the token with the authenticator it belongs to.
+func (m *Multi) Destroy(ctx context.Context, accessToken string) error {
+	a, err := m.match(accessToken)
+	if err != nil {
+		return err
+	}
+	return a.Destroy(ctx, accessToken)
+}
+
+// ParseClaims parses the token with the authenticator it belongs to.
+func (m *Multi) ParseClaims(ctx context.Context, accessToken string) (*Claims, error) {
+	a, err := m.match(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return a.ParseClaims(ctx, accessToken)
+}
+
+// Release releases every authenticator.
+func (m *Multi) Release() error {
+	var errs []error
+	for _, a := range m.authenticators {
+		errs = append(errs, a.Release())
+	}
+	return errors.Join(errs...)
+}
+
+// match returns the authenticator the token belongs to.
+func (m *Multi) match(token string) (Authenticator, error) {
+	var fallback Authenticator
+	for _, a := range m.authenticators {
+		matcher, ok := a.(TokenMatcher)
+		if !ok {
+			if fallback == nil {
+				fallback = a
+			}
+			continue
+		}
+		if matcher.MatchToken(token) {
+			return a, nil
+		}
+	}
+
+	if fallback == nil {
+		return nil, errNoAuthenticator
+	}
+	return fallback, nil
+}
@@ -0,0 +1,11 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+// Package oidc implements an OpenID Connect relying party. It logs users in
+// through an external identity provider with the authorization code flow and
+// PKCE, verifies the ID token, maps the identity to a local subject and
+// issues local tokens through an authn.Authenticator.
+package oidc // import "github.com/ydcloud-dy/publicPkg/pkg/authn/oidc"
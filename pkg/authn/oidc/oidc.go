@@ -0,0 +1,457 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	jwtauth "github.com/ydcloud-dy/publicPkg/pkg/authn/jwt"
+)
+
+const (
+	// reason holds the error reason.
+	reason string = "Unauthorized"
+
+	// discoveryPath is the path of the provider metadata, relative to the issuer.
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// AMRExternal marks a login delegated to an external identity provider.
+	AMRExternal = "ext"
+)
+
+var (
+	ErrStateInvalid   = errors.Unauthorized(reason, "Authorization state is invalid or has expired")
+	ErrIDTokenInvalid = errors.Unauthorized(reason, "ID token is invalid")
+	ErrNonceMismatch  = errors.Unauthorized(reason, "ID token nonce does not match")
+	ErrExchangeFailed = errors.Unauthorized(reason, "Failed to exchange the authorization code")
+	ErrUserInfoFailed = errors.Unauthorized(reason, "Failed to fetch the user info")
+	ErrDiscoveryFail  = errors.InternalServer("DiscoveryFailed", "Failed to discover the identity provider")
+)
+
+// Config contains the client registration at the identity provider.
+type Config struct {
+	// Issuer is the issuer URL of the identity provider.
+	Issuer string
+	// ClientID is the client id issued by the identity provider.
+	ClientID string
+	// ClientSecret is the client secret, empty for public clients.
+	ClientSecret string
+	// RedirectURL is the callback URL registered at the identity provider.
+	RedirectURL string
+	// Scopes are requested in addition to `openid`.
+	Scopes []string
+}
+
+// Metadata is the provider metadata returned by discovery.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity is the identity asserted by the identity provider.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Claims holds the ID token claims merged with the user info claims.
+	Claims map[string]any
+	// AccessToken is the access token issued by the identity provider.
+	AccessToken string
+}
+
+// Mapper maps an external identity to the local subject, and returns the
+// claims options of the local token, e.g. roles and tenant.
+type Mapper func(ctx context.Context, identity *Identity) (string, []authn.ClaimsOption, error)
+
+// defaultMapper uses the issuer scoped subject of the identity as the local subject.
+func defaultMapper(ctx context.Context, identity *Identity) (string, []authn.ClaimsOption, error) {
+	return identity.Issuer + "#" + identity.Subject, nil, nil
+}
+
+type options struct {
+	client       *http.Client
+	stateStore   StateStorer
+	stateTTL     time.Duration
+	mapper       Mapper
+	skipUserInfo bool
+}
+
+// Option is oidc option.
+type Option func(*options)
+
+// WithHTTPClient set the http client used to talk to the identity provider.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithStateStore set the store of pending authorization requests (default in memory).
+func WithStateStore(store StateStorer) Option {
+	return func(o *options) {
+		o.stateStore = store
+	}
+}
+
+// WithStateTTL set how long a user has to complete the login (default 10m).
+func WithStateTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.stateTTL = ttl
+	}
+}
+
+// WithMapper set the function mapping identities to local subjects. The
+// default uses `<issuer>#<sub>`.
+func WithMapper(mapper Mapper) Option {
+	return func(o *options) {
+		o.mapper = mapper
+	}
+}
+
+// WithoutUserInfo skips fetching the user info, the identity only carries
+// the ID token claims.
+func WithoutUserInfo() Option {
+	return func(o *options) {
+		o.skipUserInfo = true
+	}
+}
+
+// RelyingParty logs users in through an OpenID Connect identity provider.
+type RelyingParty struct {
+	cfg      Config
+	metadata *Metadata
+	keys     *jwtauth.RemoteKeySet
+	auth     authn.Authenticator
+	opts     *options
+}
+
+// New discovers the identity provider and creates a RelyingParty issuing
+// local tokens through auth.
+func New(ctx context.Context, cfg Config, auth authn.Authenticator, opts ...Option) (*RelyingParty, error) {
+	o := &options{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		stateTTL: 10 * time.Minute,
+		mapper:   defaultMapper,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.stateStore == nil {
+		o.stateStore = newMemoryStateStore(defaultStateCapacity)
+	}
+
+	rp := &RelyingParty{cfg: cfg, auth: auth, opts: o}
+	metadata, err := rp.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rp.metadata = metadata
+	rp.keys = jwtauth.NewRemoteKeySet(metadata.JWKSURI, jwtauth.WithHTTPClient(o.client))
+
+	return rp, nil
+}
+
+// Metadata returns the discovered provider metadata.
+func (rp *RelyingParty) Metadata() *Metadata {
+	return rp.metadata
+}
+
+// AuthCodeURL starts a login. It saves a new authorization request and
+// returns the URL to redirect the user to and the state of the request. The
+// caller must bind the state to the user agent, e.g. in a short lived
+// HttpOnly cookie, and pass it back to Exchange. Otherwise an attacker can
+// log a victim in to the attacker's account (login CSRF).
+func (rp *RelyingParty) AuthCodeURL(ctx context.Context) (string, string, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	req := &AuthRequest{State: state, Nonce: nonce, CodeVerifier: verifier, ExpiresAt: time.Now().Add(rp.opts.stateTTL)}
+	if err := rp.opts.stateStore.Save(ctx, req); err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", rp.cfg.ClientID)
+	v.Set("redirect_uri", rp.cfg.RedirectURL)
+	v.Set("scope", strings.Join(append([]string{"openid"}, rp.cfg.Scopes...), " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(rp.metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return rp.metadata.AuthorizationEndpoint + sep + v.Encode(), state, nil
+}
+
+// Exchange completes a login with the state and code of the callback and
+// returns the verified identity. boundState is the state AuthCodeURL returned
+// for the user agent, e.g. read back from its cookie, the login fails when it
+// does not match the state of the callback.
+func (rp *RelyingParty) Exchange(ctx context.Context, boundState string, state string, code string) (*Identity, error) {
+	if boundState == "" || subtle.ConstantTimeCompare([]byte(boundState), []byte(state)) != 1 {
+		return nil, ErrStateInvalid
+	}
+
+	req, err := rp.opts.stateStore.Take(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || code == "" {
+		return nil, ErrStateInvalid
+	}
+
+	tokens, err := rp.exchangeCode(ctx, code, req.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := rp.verifyIDToken(tokens.IDToken, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	identity.AccessToken = tokens.AccessToken
+
+	if !rp.opts.skipUserInfo && rp.metadata.UserInfoEndpoint != "" && tokens.AccessToken != "" {
+		if err := rp.fetchUserInfo(ctx, identity); err != nil {
+			return nil, err
+		}
+	}
+
+	return identity, nil
+}
+
+// Login completes a login and issues a local token for the mapped subject.
+// The arguments are the same as for Exchange.
+func (rp *RelyingParty) Login(ctx context.Context, boundState string, state string, code string) (authn.IToken, *Identity, error) {
+	identity, err := rp.Exchange(ctx, boundState, state, code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject, opts, err := rp.opts.mapper(ctx, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts = append([]authn.ClaimsOption{authn.WithAMR(AMRExternal)}, opts...)
+	token, err := rp.auth.Sign(ctx, subject, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, identity, nil
+}
+
+// discover fetches the provider metadata and checks the issuer.
+func (rp *RelyingParty) discover(ctx context.Context) (*Metadata, error) {
+	var metadata Metadata
+	if err := rp.getJSON(ctx, strings.TrimSuffix(rp.cfg.Issuer, "/")+discoveryPath, "", &metadata); err != nil {
+		return nil, ErrDiscoveryFail.WithCause(err)
+	}
+
+	if metadata.Issuer != rp.cfg.Issuer {
+		return nil, ErrDiscoveryFail.WithCause(fmt.Errorf("issuer %q does not match %q", metadata.Issuer, rp.cfg.Issuer))
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, ErrDiscoveryFail.WithCause(fmt.Errorf("incomplete provider metadata"))
+	}
+	return &metadata, nil
+}
+
+// tokenResponse is the token endpoint response.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// exchangeCode redeems the authorization code at the token endpoint.
+func (rp *RelyingParty) exchangeCode(ctx context.Context, code string, verifier string) (*tokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", rp.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	if rp.cfg.ClientSecret == "" {
+		form.Set("client_id", rp.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rp.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if rp.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(rp.cfg.ClientID), url.QueryEscape(rp.cfg.ClientSecret))
+	}
+
+	resp, err := rp.opts.client.Do(req)
+	if err != nil {
+		return nil, ErrExchangeFailed.WithCause(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, ErrExchangeFailed.WithCause(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrExchangeFailed.WithCause(fmt.Errorf("token endpoint returned %s: %s", resp.Status, body))
+	}
+
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, ErrExchangeFailed.WithCause(err)
+	}
+	if tokens.IDToken == "" {
+		return nil, ErrExchangeFailed.WithCause(fmt.Errorf("token response has no id_token"))
+	}
+	return &tokens, nil
+}
+
+// idTokenClaims holds the claims of an ID token checked by the relying party.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce"`
+	AZP   string `json:"azp,omitempty"`
+}
+
+// verifyIDToken verifies the signature and claims of the ID token.
+func (rp *RelyingParty) verifyIDToken(idToken string, nonce string) (*Identity, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}))
+
+	var claims idTokenClaims
+	if _, err := parser.ParseWithClaims(idToken, &claims, rp.keys.Keyfunc); err != nil {
+		return nil, ErrIDTokenInvalid.WithCause(err)
+	}
+
+	switch {
+	case !claims.VerifyIssuer(rp.metadata.Issuer, true):
+		return nil, ErrIDTokenInvalid.WithCause(fmt.Errorf("unexpected issuer %q", claims.Issuer))
+	case !claims.VerifyAudience(rp.cfg.ClientID, true):
+		return nil, ErrIDTokenInvalid.WithCause(fmt.Errorf("unexpected audience %v", claims.Audience))
+	case len(claims.Audience) > 1 && claims.AZP != rp.cfg.ClientID:
+		return nil, ErrIDTokenInvalid.WithCause(fmt.Errorf("unexpected authorized party %q", claims.AZP))
+	case claims.ExpiresAt == nil || claims.Subject == "":
+		return nil, ErrIDTokenInvalid.WithCause(fmt.Errorf("missing exp or sub claim"))
+	case claims.Nonce != nonce:
+		return nil, ErrNonceMismatch
+	}
+
+	// Decode the verified token again without a schema, to expose all claims.
+	all := jwt.MapClaims{}
+	if _, _, err := parser.ParseUnverified(idToken, all); err != nil {
+		return nil, ErrIDTokenInvalid.WithCause(err)
+	}
+
+	identity := &Identity{Issuer: claims.Issuer, Subject: claims.Subject, Claims: all}
+	identity.merge(all)
+	return identity, nil
+}
+
+// fetchUserInfo merges the user info claims into the identity.
+func (rp *RelyingParty) fetchUserInfo(ctx context.Context, identity *Identity) error {
+	var info map[string]any
+	if err := rp.getJSON(ctx, rp.metadata.UserInfoEndpoint, identity.AccessToken, &info); err != nil {
+		return ErrUserInfoFailed.WithCause(err)
+	}
+
+	// The user info must describe the subject of the ID token, see OpenID
+	// Connect Core 5.3.2.
+	if sub, _ := info["sub"].(string); sub != identity.Subject {
+		return ErrUserInfoFailed.WithCause(fmt.Errorf("user info subject %q does not match %q", sub, identity.Subject))
+	}
+
+	if identity.Claims == nil {
+		identity.Claims = make(map[string]any)
+	}
+	for k, v := range info {
+		identity.Claims[k] = v
+	}
+	identity.merge(info)
+	return nil
+}
+
+// merge copies the standard profile claims into the identity.
+func (i *Identity) merge(claims map[string]any) {
+	if v, ok := claims["email"].(string); ok {
+		i.Email = v
+	}
+	if v, ok := claims["email_verified"].(bool); ok {
+		i.EmailVerified = v
+	}
+	if v, ok := claims["name"].(string); ok {
+		i.Name = v
+	}
+}
+
+// getJSON fetches a JSON document, with a bearer token if given.
+func (rp *RelyingParty) getJSON(ctx context.Context, url string, bearer string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := rp.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch %s: unexpected status %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// randomString returns 32 random bytes encoded with base64url.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	jwtauth "github.com/ydcloud-dy/publicPkg/pkg/authn/jwt"
+)
+
+// mockIdP is a minimal OpenID Connect provider which issues a code for
+// every authorization request it is given.
+type mockIdP struct {
+	*httptest.Server
+	t    *testing.T
+	keys *jwtauth.KeySet
+
+	mu    sync.Mutex
+	codes map[string]url.Values // code -> authorization request
+	nonce string                // overrides the nonce of the ID token
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	keys, err := jwtauth.NewKeySet(jwt.SigningMethodRS256)
+	require.NoError(t, err)
+
+	idp := &mockIdP{t: t, keys: keys, codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Metadata{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			UserInfoEndpoint:      idp.URL + "/userinfo",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.Handle("/jwks", keys)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer idp-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"sub": "alice", "email": "alice@example.com", "email_verified": true})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+// authorize simulates the user approving the authorization request.
+func (idp *mockIdP) authorize(authURL string) (state string, code string) {
+	u, err := url.Parse(authURL)
+	require.NoError(idp.t, err)
+	q := u.Query()
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code = "code-" + q.Get("state")[:8]
+	idp.codes[code] = q
+	return q.Get("state"), code
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	req, ok := idp.codes[r.FormValue("code")]
+	delete(idp.codes, r.FormValue("code"))
+	nonce := idp.nonce
+	idp.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	id, secret, _ := r.BasicAuth()
+	if !ok || id != "client" || secret != "secret" ||
+		req.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+	if nonce == "" {
+		nonce = req.Get("nonce")
+	}
+
+	key, err := idp.keys.SigningKey()
+	require.NoError(idp.t, err)
+	token := jwt.NewWithClaims(key.Method, jwt.MapClaims{
+		"iss":   idp.URL,
+		"sub":   "alice",
+		"aud":   "client",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": nonce,
+		"name":  "Alice",
+	})
+	token.Header["kid"] = key.ID
+	idToken, err := token.SignedString(key.PrivateKey)
+	require.NoError(idp.t, err)
+
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "idp-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func newRelyingParty(t *testing.T, idp *mockIdP, auth authn.Authenticator, opts ...Option) *RelyingParty {
+	rp, err := New(context.Background(), Config{
+		Issuer:       idp.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.example.com/callback",
+		Scopes:       []string{"email"},
+	}, auth, opts...)
+	require.NoError(t, err)
+	return rp
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	idp := newMockIdP(t)
+	auth := jwtauth.New(nil)
+	rp := newRelyingParty(t, idp, auth, WithMapper(func(ctx context.Context, identity *Identity) (string, []authn.ClaimsOption, error) {
+		return "user-" + identity.Subject, []authn.ClaimsOption{authn.WithExtra("email", identity.Email)}, nil
+	}))
+
+	authURL, boundState, err := rp.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, authURL, "code_challenge_method=S256")
+
+	state, code := idp.authorize(authURL)
+	assert.Equal(t, boundState, state)
+	token, identity, err := rp.Login(ctx, boundState, state, code)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, "Alice", identity.Name)
+
+	claims, err := auth.ParseClaims(ctx, token.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, "user-alice", claims.Subject)
+	assert.Equal(t, "alice@example.com", claims.Extra["email"])
+	assert.Contains(t, claims.AMR, AMRExternal)
+
+	// The state can only be used once.
+	_, _, err = rp.Login(ctx, boundState, state, code)
+	assert.ErrorContains(t, err, ErrStateInvalid.Message)
+}
+
+func TestExchangeRejectsLoginCSRF(t *testing.T) {
+	ctx := context.Background()
+	idp := newMockIdP(t)
+	rp := newRelyingParty(t, idp, jwtauth.New(nil))
+
+	// The attacker starts a login and sends the callback URL to the victim,
+	// whose user agent is bound to another state.
+	authURL, _, err := rp.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	state, code := idp.authorize(authURL)
+
+	_, victimState, err := rp.AuthCodeURL(ctx)
+	require.NoError(t, err)
+
+	_, err = rp.Exchange(ctx, victimState, state, code)
+	assert.ErrorContains(t, err, ErrStateInvalid.Message)
+	_, err = rp.Exchange(ctx, "", state, code)
+	assert.ErrorContains(t, err, ErrStateInvalid.Message)
+}
+
+func TestMemoryStateStore(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStateStore(2)
+
+	require.NoError(t, s.Save(ctx, &AuthRequest{State: "expired", ExpiresAt: time.Now().Add(-time.Second)}))
+	require.NoError(t, s.Save(ctx, &AuthRequest{State: "a", ExpiresAt: time.Now().Add(time.Hour)}))
+	require.NoError(t, s.Save(ctx, &AuthRequest{State: "b", ExpiresAt: time.Now().Add(time.Hour)}))
+	assert.NotContains(t, s.requests, "expired")
+
+	// The oldest pending request is dropped once the capacity is reached.
+	require.NoError(t, s.Save(ctx, &AuthRequest{State: "c", ExpiresAt: time.Now().Add(time.Hour)}))
+	assert.Len(t, s.requests, 2)
+
+	req, err := s.Take(ctx, "a")
+	require.NoError(t, err)
+	assert.Nil(t, req)
+
+	req, err = s.Take(ctx, "c")
+	require.NoError(t, err)
+	require.NotNil(t, req)
+	assert.Equal(t, "c", req.State)
+	assert.Equal(t, 1, s.order.Len())
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	ctx := context.Background()
+	idp := newMockIdP(t)
+	idp.nonce = "replayed"
+	rp := newRelyingParty(t, idp, jwtauth.New(nil))
+
+	authURL, boundState, err := rp.AuthCodeURL(ctx)
+	require.NoError(t, err)
+
+	state, code := idp.authorize(authURL)
+	_, err = rp.Exchange(ctx, boundState, state, code)
+	assert.ErrorContains(t, err, ErrNonceMismatch.Message)
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	_, err := New(context.Background(), Config{Issuer: idp.URL + "/other"}, jwtauth.New(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package oidc
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// AuthRequest holds the secrets of a pending authorization request. It is
+// saved when the user is redirected to the identity provider and taken back
+// in the callback.
+type AuthRequest struct {
+	State        string    `json:"state"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"codeVerifier"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// StateStorer stores pending authorization requests. Take must remove the
+// request so a state can only be used once. Use a shared implementation,
+// e.g. backed by Redis, when the callback may reach another instance.
+type StateStorer interface {
+	// Save stores the request under its state.
+	Save(ctx context.Context, req *AuthRequest) error
+	// Take returns and removes the request with the given state, nil if it
+	// does not exist or has expired.
+	Take(ctx context.Context, state string) (*AuthRequest, error)
+}
+
+// defaultStateCapacity is the maximum number of pending requests kept by the
+// default in memory StateStorer.
+const defaultStateCapacity = 10000
+
+// memoryStateStore is the default in memory StateStorer. It keeps at most
+// capacity pending requests, the oldest request is dropped to make room when
+// none has expired.
+type memoryStateStore struct {
+	mu       sync.Mutex
+	capacity int
+	requests map[string]*list.Element
+	order    *list.List // front is the oldest request
+}
+
+func newMemoryStateStore(capacity int) *memoryStateStore {
+	return &memoryStateStore{capacity: capacity, requests: make(map[string]*list.Element), order: list.New()}
+}
+
+// Save stores the request and drops the expired ones.
+func (s *memoryStateStore) Save(ctx context.Context, req *AuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Requests share the same TTL, so they expire in the order they were saved.
+	now := time.Now()
+	for front := s.order.Front(); front != nil && now.After(front.Value.(*AuthRequest).ExpiresAt); front = s.order.Front() {
+		s.remove(front)
+	}
+	for s.order.Len() >= s.capacity {
+		s.remove(s.order.Front())
+	}
+
+	if elem, ok := s.requests[req.State]; ok {
+		s.remove(elem)
+	}
+	s.requests[req.State] = s.order.PushBack(req)
+	return nil
+}
+
+// Take returns and removes the request with the given state.
+func (s *memoryStateStore) Take(ctx context.Context, state string) (*AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.requests[state]
+	if !ok {
+		return nil, nil
+	}
+	s.remove(elem)
+
+	req := elem.Value.(*AuthRequest)
+	if time.Now().After(req.ExpiresAt) {
+		return nil, nil
+	}
+	return req, nil
+}
+
+func (s *memoryStateStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.requests, elem.Value.(*AuthRequest).State)
+}
@@ -0,0 +1,334 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package authn
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Supported password hashing algorithms.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmScrypt   = "scrypt"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	// ErrUnknownHashFormat is returned when an encoded hash is not produced by a known algorithm.
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+	// ErrUnknownPepper is returned when an encoded hash references a pepper which is not configured.
+	ErrUnknownPepper = errors.New("unknown password pepper")
+)
+
+// Argon2Params holds the argon2id parameters.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// ScryptParams holds the scrypt parameters. N is 1<<LogN.
+type ScryptParams struct {
+	LogN    uint8
+	R       int
+	P       int
+	KeyLen  int
+	SaltLen int
+}
+
+// PasswordHasher hashes and verifies passwords.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password. The encoded hash carries
+	// the algorithm and its parameters.
+	Hash(password string) (string, error)
+
+	// Verify reports whether the password matches the encoded hash, and
+	// whether the hash should be replaced by a new Hash of the password
+	// because it uses an outdated algorithm, parameters or pepper.
+	Verify(encoded, password string) (ok bool, needsRehash bool, err error)
+}
+
+// Hasher is the default PasswordHasher. It hashes new passwords with one
+// algorithm and verifies argon2id, scrypt and bcrypt hashes, so existing
+// hashes can be migrated transparently on login.
+//
+// Argon2id and scrypt hashes use the PHC string format, e.g.
+// `$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>`. When a pepper is
+// configured its id is recorded as the `pk` parameter. Bcrypt hashes can not
+// record a pepper and are never peppered.
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	scrypt     ScryptParams
+	bcryptCost int
+	pepperID   string
+	peppers    map[string][]byte
+}
+
+// Ensure Hasher implements the PasswordHasher interface.
+var _ PasswordHasher = (*Hasher)(nil)
+
+// HasherOption is Hasher option.
+type HasherOption func(*Hasher)
+
+// WithArgon2id hashes new passwords with argon2id and the given parameters.
+func WithArgon2id(params Argon2Params) HasherOption {
+	return func(h *Hasher) {
+		h.algorithm = AlgorithmArgon2id
+		h.argon2 = params
+	}
+}
+
+// WithScrypt hashes new passwords with scrypt and the given parameters.
+func WithScrypt(params ScryptParams) HasherOption {
+	return func(h *Hasher) {
+		h.algorithm = AlgorithmScrypt
+		h.scrypt = params
+	}
+}
+
+// WithBcrypt hashes new passwords with bcrypt and the given cost.
+func WithBcrypt(cost int) HasherOption {
+	return func(h *Hasher) {
+		h.algorithm = AlgorithmBcrypt
+		h.bcryptCost = cost
+	}
+}
+
+// WithPepper set the server side secret mixed into new argon2id and scrypt
+// hashes. The id is recorded in the hash, so the pepper can be rotated.
+func WithPepper(id string, secret []byte) HasherOption {
+	return func(h *Hasher) {
+		h.pepperID = id
+		h.peppers[id] = secret
+	}
+}
+
+// WithRetiredPepper set a pepper which is still accepted when verifying
+// hashes, but causes them to be rehashed with the current pepper.
+func WithRetiredPepper(id string, secret []byte) HasherOption {
+	return func(h *Hasher) {
+		h.peppers[id] = secret
+	}
+}
+
+// NewHasher creates a Hasher, by default new passwords are hashed with
+// argon2id using the RFC 9106 recommended parameters.
+func NewHasher(opts ...HasherOption) *Hasher {
+	h := &Hasher{
+		algorithm:  AlgorithmArgon2id,
+		argon2:     Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 4, KeyLen: 32, SaltLen: 16},
+		scrypt:     ScryptParams{LogN: 15, R: 8, P: 1, KeyLen: 32, SaltLen: 16},
+		bcryptCost: bcrypt.DefaultCost,
+		peppers:    make(map[string][]byte),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Hash returns the encoded hash of the password.
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.algorithm {
+	case AlgorithmBcrypt:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(hashed), err
+	case AlgorithmScrypt:
+		p := h.scrypt
+		salt, err := randomBytes(p.SaltLen)
+		if err != nil {
+			return "", err
+		}
+		key, err := scrypt.Key(h.pepper(password, h.pepperID), salt, 1<<p.LogN, p.R, p.P, p.KeyLen)
+		if err != nil {
+			return "", err
+		}
+		params := fmt.Sprintf("ln=%d,r=%d,p=%d", p.LogN, p.R, p.P)
+		return encodePHC(AlgorithmScrypt, "", h.withPepperID(params), salt, key), nil
+	default:
+		p := h.argon2
+		salt, err := randomBytes(int(p.SaltLen))
+		if err != nil {
+			return "", err
+		}
+		key := argon2.IDKey(h.pepper(password, h.pepperID), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+		params := fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Time, p.Threads)
+		return encodePHC(AlgorithmArgon2id, fmt.Sprintf("v=%d", argon2.Version), h.withPepperID(params), salt, key), nil
+	}
+}
+
+// Verify reports whether the password matches the encoded hash and whether
+// the hash should be recomputed.
+func (h *Hasher) Verify(encoded, password string) (bool, bool, error) {
+	if strings.HasPrefix(encoded, "$2") {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, err
+		}
+		return true, h.algorithm != AlgorithmBcrypt || cost != h.bcryptCost, nil
+	}
+
+	phc, err := decodePHC(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	pepperID := phc.params["pk"]
+	if _, ok := h.peppers[pepperID]; pepperID != "" && !ok {
+		return false, false, ErrUnknownPepper
+	}
+	peppered := h.pepper(password, pepperID)
+
+	var (
+		key     []byte
+		current bool
+	)
+	switch phc.algorithm {
+	case AlgorithmArgon2id:
+		m, t, p := phc.uint("m"), phc.uint("t"), phc.uint("p")
+		if m == 0 || t == 0 || p == 0 || p > 255 {
+			return false, false, ErrUnknownHashFormat
+		}
+		key = argon2.IDKey(peppered, phc.salt, uint32(t), uint32(m), uint8(p), uint32(len(phc.hash)))
+		a := h.argon2
+		current = h.algorithm == AlgorithmArgon2id && uint32(m) == a.Memory && uint32(t) == a.Time &&
+			uint8(p) == a.Threads && uint32(len(phc.hash)) == a.KeyLen
+	case AlgorithmScrypt:
+		ln, r, p := phc.uint("ln"), phc.uint("r"), phc.uint("p")
+		if ln == 0 || ln > 62 || r == 0 || p == 0 {
+			return false, false, ErrUnknownHashFormat
+		}
+		key, err = scrypt.Key(peppered, phc.salt, 1<<ln, int(r), int(p), len(phc.hash))
+		if err != nil {
+			return false, false, err
+		}
+		s := h.scrypt
+		current = h.algorithm == AlgorithmScrypt && uint8(ln) == s.LogN && int(r) == s.R &&
+			int(p) == s.P && len(phc.hash) == s.KeyLen
+	default:
+		return false, false, ErrUnknownHashFormat
+	}
+
+	if subtle.ConstantTimeCompare(key, phc.hash) != 1 {
+		return false, false, nil
+	}
+	return true, !current || pepperID != h.pepperID, nil
+}
+
+// pepper mixes the pepper with the given id into the password.
+func (h *Hasher) pepper(password string, id string) []byte {
+	secret, ok := h.peppers[id]
+	if id == "" || !ok {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// withPepperID appends the current pepper id to the PHC parameters.
+func (h *Hasher) withPepperID(params string) string {
+	if h.pepperID == "" {
+		return params
+	}
+	return params + ",pk=" + h.pepperID
+}
+
+// phcHash is a decoded PHC string.
+type phcHash struct {
+	algorithm string
+	params    map[string]string
+	salt      []byte
+	hash      []byte
+}
+
+// uint returns the numeric parameter with the given name, 0 if it is missing or invalid.
+func (p *phcHash) uint(name string) uint64 {
+	v, err := strconv.ParseUint(p.params[name], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// encodePHC encodes a hash in the PHC string format.
+func encodePHC(algorithm, version, params string, salt, hash []byte) string {
+	parts := []string{"", algorithm}
+	if version != "" {
+		parts = append(parts, version)
+	}
+	parts = append(parts, params, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
+	return strings.Join(parts, "$")
+}
+
+// decodePHC decodes a hash in the PHC string format.
+func decodePHC(encoded string) (*phcHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 5 || parts[0] != "" {
+		return nil, ErrUnknownHashFormat
+	}
+
+	phc := &phcHash{algorithm: parts[1], params: make(map[string]string)}
+	// The version field is optional.
+	fields := parts[2:]
+	if strings.HasPrefix(fields[0], "v=") {
+		if fields[0] != fmt.Sprintf("v=%d", argon2.Version) {
+			return nil, ErrUnknownHashFormat
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return nil, ErrUnknownHashFormat
+	}
+
+	for _, kv := range strings.Split(fields[0], ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		phc.params[k] = v
+	}
+
+	var err error
+	if phc.salt, err = base64.RawStdEncoding.DecodeString(fields[1]); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+	if phc.hash, err = base64.RawStdEncoding.DecodeString(fields[2]); err != nil || len(phc.hash) == 0 {
+		return nil, ErrUnknownHashFormat
+	}
+
+	return phc, nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package authn
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Small parameters to keep the tests fast.
+var (
+	testArgon2 = Argon2Params{Time: 1, Memory: 1024, Threads: 1, KeyLen: 32, SaltLen: 16}
+	testScrypt = ScryptParams{LogN: 10, R: 8, P: 1, KeyLen: 32, SaltLen: 16}
+)
+
+func TestHasherRoundTrip(t *testing.T) {
+	for _, opt := range []HasherOption{WithArgon2id(testArgon2), WithScrypt(testScrypt), WithBcrypt(bcrypt.MinCost)} {
+		h := NewHasher(opt, WithPepper("1", []byte("pepper")))
+
+		encoded, err := h.Hash("secret")
+		require.NoError(t, err)
+
+		ok, rehash, err := h.Verify(encoded, "secret")
+		require.NoError(t, err)
+		assert.True(t, ok, encoded)
+		assert.False(t, rehash, encoded)
+
+		ok, _, err = h.Verify(encoded, "wrong")
+		require.NoError(t, err)
+		assert.False(t, ok, encoded)
+	}
+}
+
+func TestHasherNeedsRehash(t *testing.T) {
+	legacy, err := Encrypt("secret")
+	require.NoError(t, err)
+
+	h := NewHasher(WithArgon2id(testArgon2), WithPepper("2", []byte("new")), WithRetiredPepper("1", []byte("old")))
+	ok, rehash, err := h.Verify(legacy, "secret")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	old, err := NewHasher(WithArgon2id(testArgon2), WithPepper("1", []byte("old"))).Hash("secret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(old, "$argon2id$v=19$m=1024,t=1,p=1,pk=1$"), old)
+	ok, rehash, err = h.Verify(old, "secret")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	_, _, err = NewHasher().Verify(old, "secret")
+	assert.ErrorIs(t, err, ErrUnknownPepper)
+
+	_, _, err = h.Verify("plain", "secret")
+	assert.ErrorIs(t, err, ErrUnknownHashFormat)
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package authn
+
+import (
+	"context"
+	"time"
+)
+
+// Session describes a login session, i.e. the tokens issued by one Sign call
+// and every token pair refreshed from them.
+type Session struct {
+	// ID identifies the session, it is carried by the `sid` claim.
+	ID string `json:"id"`
+	// Subject is the user the session belongs to.
+	Subject string `json:"subject"`
+	// Device, IP and UserAgent describe the client the session was created from.
+	Device    string `json:"device,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	// CreatedAt is when the session was created.
+	CreatedAt time.Time `json:"createdAt"`
+	// LastUsedAt is when the session was last refreshed.
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	// ExpiresAt is when the session ends unless it is refreshed.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SessionMetadata describes the client a session is created from.
+type SessionMetadata struct {
+	Device    string
+	IP        string
+	UserAgent string
+}
+
+type sessionMetadataKey struct{}
+
+// WithSessionMetadata returns a copy of ctx which carries the client
+// metadata recorded when a session is created or refreshed.
+func WithSessionMetadata(ctx context.Context, md SessionMetadata) context.Context {
+	return context.WithValue(ctx, sessionMetadataKey{}, md)
+}
+
+// SessionMetadataFromContext returns the client metadata stored in ctx.
+func SessionMetadataFromContext(ctx context.Context) SessionMetadata {
+	md, _ := ctx.Value(sessionMetadataKey{}).(SessionMetadata)
+	return md
+}
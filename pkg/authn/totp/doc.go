@@ -0,0 +1,12 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+// Package totp implements RFC 6238 time-based one-time passwords and
+// one-time recovery codes for two-factor authentication.
+//
+// After the second factor has been verified, record it in the signed token
+// with authn.WithMFA(authn.AMROTP) or authn.WithMFA(authn.AMRRecoveryCode).
+package totp // import "github.com/ydcloud-dy/publicPkg/pkg/authn/totp"
@@ -0,0 +1,62 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// recoveryCodeSize is the number of random bytes of a recovery code.
+const recoveryCodeSize = 10
+
+// GenerateRecoveryCodes returns n recovery codes to show to the user once,
+// and their hashes to store. Recovery codes are random enough to be hashed
+// with SHA-256 instead of a password hash.
+func GenerateRecoveryCodes(n int) (codes []string, hashes []string, err error) {
+	codes = make([]string, 0, n)
+	hashes = make([]string, 0, n)
+	for range n {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		code = code[:8] + "-" + code[8:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code. Dashes, spaces and
+// case are ignored.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// UseRecoveryCode checks the code against the stored hashes. On success it
+// returns the hashes without the used one, which the caller must store so
+// the code can not be used again.
+func UseRecoveryCode(hashes []string, code string) ([]string, bool) {
+	hashed := []byte(HashRecoveryCode(code))
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), hashed) == 1 {
+			remaining := make([]string, 0, len(hashes)-1)
+			remaining = append(remaining, hashes[:i]...)
+			return append(remaining, hashes[i+1:]...), true
+		}
+	}
+	return hashes, false
+}
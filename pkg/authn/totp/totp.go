@@ -0,0 +1,262 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package totp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint: gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+)
+
+const (
+	// replayKeyPrefix is the storage key prefix of a used code.
+	replayKeyPrefix = "totp:"
+	// defaultPeriod is how long a code is valid by default.
+	defaultPeriod = 30 * time.Second
+)
+
+var (
+	ErrCodeInvalid   = errors.Unauthorized("CodeInvalid", "One-time password is invalid")
+	ErrCodeReused    = errors.Unauthorized("CodeReused", "One-time password has already been used")
+	ErrSecretInvalid = errors.BadRequest("SecretInvalid", "TOTP secret is invalid")
+)
+
+// Algorithm is the HMAC hash function used to generate codes.
+type Algorithm string
+
+// Supported algorithms. Most authenticator apps only support SHA1.
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case AlgorithmSHA256:
+		return sha256.New
+	case AlgorithmSHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+// Storer records used codes to reject replays. The stores of
+// pkg/authn/jwt/store implement it.
+type Storer interface {
+	// SetNX stores the key until expiration has passed unless it already
+	// exists, atomically. It reports whether the key has been stored.
+	SetNX(ctx context.Context, key string, expiration time.Duration) (bool, error)
+}
+
+type options struct {
+	issuer     string
+	digits     int
+	period     time.Duration
+	skew       uint
+	algorithm  Algorithm
+	secretSize int
+	now        func() time.Time
+}
+
+// Option is totp option.
+type Option func(*options)
+
+// WithIssuer set the issuer shown by authenticator apps.
+func WithIssuer(issuer string) Option {
+	return func(o *options) {
+		o.issuer = issuer
+	}
+}
+
+// WithDigits set the number of digits of a code (default 6).
+func WithDigits(digits int) Option {
+	return func(o *options) {
+		o.digits = digits
+	}
+}
+
+// WithPeriod set how long a code is valid (default 30s). The period is a whole
+// number of seconds, it is truncated to seconds and a period shorter than one
+// second is replaced by the default.
+func WithPeriod(period time.Duration) Option {
+	return func(o *options) {
+		o.period = period
+	}
+}
+
+// WithSkew set how many periods before and after the current one are
+// accepted, to tolerate clock drift (default 1).
+func WithSkew(skew uint) Option {
+	return func(o *options) {
+		o.skew = skew
+	}
+}
+
+// WithAlgorithm set the HMAC hash function (default SHA1).
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(o *options) {
+		o.algorithm = algorithm
+	}
+}
+
+// TOTP generates and validates time-based one-time passwords.
+type TOTP struct {
+	store Storer
+	opts  *options
+}
+
+// New creates a TOTP. When store is nil, codes can be replayed within their
+// validity window.
+func New(store Storer, opts ...Option) *TOTP {
+	o := &options{
+		digits:     6,
+		period:     defaultPeriod,
+		skew:       1,
+		algorithm:  AlgorithmSHA1,
+		secretSize: 20,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	// Codes are counted in whole seconds, a shorter period would divide by zero.
+	if o.period = o.period.Truncate(time.Second); o.period < time.Second {
+		o.period = defaultPeriod
+	}
+
+	return &TOTP{store: store, opts: o}
+}
+
+// GenerateSecret returns a new random base32 encoded secret.
+func (t *TOTP) GenerateSecret() (string, error) {
+	b := make([]byte, t.opts.secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// URI returns the otpauth URI used to enroll the secret in an authenticator
+// app, usually rendered as a QR code.
+func (t *TOTP) URI(secret string, account string) string {
+	label := account
+	if t.opts.issuer != "" {
+		label = t.opts.issuer + ":" + account
+	}
+
+	v := url.Values{}
+	v.Set("secret", secret)
+	if t.opts.issuer != "" {
+		v.Set("issuer", t.opts.issuer)
+	}
+	v.Set("algorithm", string(t.opts.algorithm))
+	v.Set("digits", strconv.Itoa(t.opts.digits))
+	v.Set("period", strconv.Itoa(int(t.opts.period/time.Second)))
+
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: v.Encode()}
+	return u.String()
+}
+
+// Generate returns the code of the secret at the given time.
+func (t *TOTP) Generate(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.code(key, t.counter(at)), nil
+}
+
+// Validate checks the code of the subject against the secret. A code is
+// accepted once, later attempts fail with ErrCodeReused.
+func (t *TOTP) Validate(ctx context.Context, subject string, secret string, code string) error {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return err
+	}
+	if len(code) != t.opts.digits {
+		return ErrCodeInvalid
+	}
+
+	now := t.counter(t.opts.now())
+	skew := uint64(t.opts.skew)
+	for counter := now - min(skew, now); counter <= now+skew; counter++ {
+		if subtle.ConstantTimeCompare([]byte(t.code(key, counter)), []byte(code)) != 1 {
+			continue
+		}
+		return t.markUsed(ctx, subject, counter)
+	}
+
+	return ErrCodeInvalid
+}
+
+// markUsed records the counter of an accepted code. Codes of the subject
+// are tracked per counter, so one code can not be used twice.
+func (t *TOTP) markUsed(ctx context.Context, subject string, counter uint64) error {
+	if t.store == nil {
+		return nil
+	}
+
+	// Keep the key until the code falls out of the skew window. Concurrent
+	// attempts with the same code can not both store it.
+	key := fmt.Sprintf("%s%s:%d", replayKeyPrefix, subject, counter)
+	stored, err := t.store.SetNX(ctx, key, time.Duration(2*t.opts.skew+1)*t.opts.period)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return ErrCodeReused
+	}
+	return nil
+}
+
+func (t *TOTP) counter(at time.Time) uint64 {
+	return uint64(at.Unix()) / uint64(t.opts.period/time.Second)
+}
+
+// code implements the HOTP algorithm of RFC 4226.
+func (t *TOTP) code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(t.opts.algorithm.hash(), key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for range t.opts.digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.opts.digits, value%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrSecretInvalid
+	}
+	return key, nil
+}
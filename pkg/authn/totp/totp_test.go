@@ -0,0 +1,133 @@
+// Copyright 2022 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/onexstack/onex.
+//
+
+package totp
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn/jwt/store/memory"
+)
+
+// rfcSecret is the base32 encoding of the RFC 6238 test secret "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "94287082"},
+		{unix: 1111111109, code: "07081804"},
+		{unix: 1234567890, code: "89005924"},
+		{unix: 20000000000, code: "65353130"},
+	}
+
+	totp := New(nil, WithDigits(8))
+	for _, tt := range tests {
+		code, err := totp.Generate(rfcSecret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code, tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	totp := New(memory.NewStore())
+	totp.opts.now = func() time.Time { return now }
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	previous, err := totp.Generate(secret, now.Add(-30*time.Second))
+	require.NoError(t, err)
+	require.NoError(t, totp.Validate(ctx, "user-1", secret, previous))
+	assert.ErrorIs(t, totp.Validate(ctx, "user-1", secret, previous), ErrCodeReused)
+
+	stale, err := totp.Generate(secret, now.Add(-90*time.Second))
+	require.NoError(t, err)
+	assert.ErrorIs(t, totp.Validate(ctx, "user-1", secret, stale), ErrCodeInvalid)
+	assert.NotErrorIs(t, totp.Validate(ctx, "user-1", secret, stale), ErrCodeReused)
+	assert.ErrorIs(t, totp.Validate(ctx, "user-1", "!", "123456"), ErrSecretInvalid)
+}
+
+func TestValidateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	totp := New(memory.NewStore())
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	code, err := totp.Generate(secret, time.Now())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int64
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if totp.Validate(ctx, "user-1", secret, code) == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), accepted.Load())
+}
+
+func TestURI(t *testing.T) {
+	uri := New(nil, WithIssuer("onex")).URI(rfcSecret, "colin@example.com")
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/onex:colin@example.com", u.Path)
+	assert.Equal(t, rfcSecret, u.Query().Get("secret"))
+	assert.Equal(t, "onex", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
+
+func TestPeriod(t *testing.T) {
+	for period, expected := range map[time.Duration]string{
+		0:                        "30",
+		-time.Second:             "30",
+		500 * time.Millisecond:   "30",
+		90500 * time.Millisecond: "90",
+	} {
+		totp := New(nil, WithPeriod(period))
+
+		u, err := url.Parse(totp.URI(rfcSecret, "colin@example.com"))
+		require.NoError(t, err)
+		assert.Equal(t, expected, u.Query().Get("period"))
+
+		code, err := totp.Generate(rfcSecret, time.Now())
+		require.NoError(t, err)
+		assert.NoError(t, totp.Validate(context.Background(), "user-1", rfcSecret, code))
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	remaining, ok := UseRecoveryCode(hashes, codes[3])
+	require.True(t, ok)
+	assert.Len(t, remaining, 9)
+
+	_, ok = UseRecoveryCode(remaining, codes[3])
+	assert.False(t, ok)
+}
@@ -1,13 +1,20 @@
 package authz
 
 import (
+	"context"
+	"slices"
 	"time"
 
 	casbin "github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
 	adapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/google/wire"
 	"gorm.io/gorm"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authz/watcher"
+	"github.com/ydcloud-dy/publicPkg/pkg/store/where"
 )
 
 const (
@@ -26,11 +33,45 @@ e = !some(where (p.eft == deny))
 
 [matchers]
 m = g(r.sub, p.sub) && keyMatch(r.obj, p.obj) && r.act == p.act`
+
+	// DomainModel 是支持多租户的 RBAC 模型，角色在域（租户）内分配，例如 `g, alice, admin, tenant-a`.
+	// 域为 * 的策略在所有域内生效. 与默认模型不同，该模型只允许被显式授权的请求，以免租户之间越权.
+	DomainModel = `[request_definition]
+r = sub, dom, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act, eft
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && (p.dom == r.dom || p.dom == "*") && keyMatch(r.obj, p.obj) && r.act == p.act`
+
+	// ABACModel 是基于属性的访问控制模型，请求主体和对象可以是结构体，策略是使用其导出字段的表达式，
+	// 例如 `p, r.obj.Owner == r.sub.Name, write, allow`，动作为 * 的策略匹配所有动作.
+	// 与 DomainModel 相同，该模型只允许被显式授权的请求.
+	ABACModel = `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = rule, act, eft
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = eval(p.rule) && (p.act == r.act || p.act == "*")`
 )
 
 // Authz 定义了一个授权器，提供授权功能.
 type Authz struct {
 	*casbin.SyncedEnforcer // 使用 Casbin 的同步授权器
+
+	tenantFunc func(ctx context.Context) string // 从请求上下文中获取租户
 }
 
 // Option 定义了一个函数选项类型，用于自定义 NewAuthz 的行为.
@@ -40,6 +81,8 @@ type Option func(*authzConfig)
 type authzConfig struct {
 	aclModel           string        // Casbin 的模型字符串
 	autoLoadPolicyTime time.Duration // 自动加载策略的时间间隔
+	tenantFunc         func(ctx context.Context) string
+	watcher            persist.Watcher // 策略变更通知
 }
 
 // ProviderSet 是一个 Wire 的 Provider 集合，用于声明依赖注入的规则。
@@ -53,6 +96,8 @@ func defaultAuthzConfig() *authzConfig {
 		aclModel: defaultAclModel,
 		// 默认的自动加载策略时间间隔
 		autoLoadPolicyTime: 5 * time.Second,
+		// 默认从 where 包注册的租户或令牌的 tid 声明中获取租户
+		tenantFunc: tenantFromContext,
 	}
 }
 
@@ -73,13 +118,47 @@ func WithAclModel(model string) Option {
 	}
 }
 
-// WithAutoLoadPolicyTime 允许通过选项自定义自动加载策略的时间间隔.
+// WithAutoLoadPolicyTime 允许通过选项自定义自动加载策略的时间间隔，小于等于 0 时不自动加载.
 func WithAutoLoadPolicyTime(interval time.Duration) Option {
 	return func(cfg *authzConfig) {
 		cfg.autoLoadPolicyTime = interval
 	}
 }
 
+// WithWatcher 设置策略变更的 Watcher，策略变更会推送到其它副本.
+// 使用 watcher.Watcher 时其它副本增量更新策略，自动加载策略仍作为兜底，可以适当调大时间间隔.
+func WithWatcher(w persist.Watcher) Option {
+	return func(cfg *authzConfig) {
+		cfg.watcher = w
+	}
+}
+
+// WithDomainModel 使用内置的多租户 RBAC 模型 DomainModel.
+func WithDomainModel() Option {
+	return WithAclModel(DomainModel)
+}
+
+// WithABACModel 使用内置的基于属性的访问控制模型 ABACModel.
+func WithABACModel() Option {
+	return WithAclModel(ABACModel)
+}
+
+// WithTenantFunc 允许通过选项自定义从请求上下文中获取租户的函数.
+func WithTenantFunc(fn func(ctx context.Context) string) Option {
+	return func(cfg *authzConfig) {
+		cfg.tenantFunc = fn
+	}
+}
+
+// tenantFromContext 优先使用 where.RegisterTenant 注册的租户，其次使用令牌中的租户.
+// 与令牌中的租户不一致时，AuthorizeContext 拒绝授权.
+func tenantFromContext(ctx context.Context) string {
+	if tenant := where.TenantValue(ctx); tenant != "" {
+		return tenant
+	}
+	return authn.TenantFromContext(ctx)
+}
+
 // NewAuthz 创建一个使用 Casbin 完成授权的授权器，通过函数选项模式支持自定义配置.
 func NewAuthz(db *gorm.DB, opts ...Option) (*Authz, error) {
 	// 初始化默认配置
@@ -110,11 +189,23 @@ func NewAuthz(db *gorm.DB, opts ...Option) (*Authz, error) {
 		return nil, err // 返回错误
 	}
 
+	// 设置 Watcher，watcher.Watcher 的通知可以增量更新
+	if cfg.watcher != nil {
+		if err := enforcer.SetWatcher(cfg.watcher); err != nil {
+			return nil, err
+		}
+		if w, ok := cfg.watcher.(*watcher.Watcher); ok {
+			_ = w.SetUpdateCallback(watcher.Incremental(enforcer))
+		}
+	}
+
 	// 启动自动加载策略，使用配置的时间间隔
-	enforcer.StartAutoLoadPolicy(cfg.autoLoadPolicyTime)
+	if cfg.autoLoadPolicyTime > 0 {
+		enforcer.StartAutoLoadPolicy(cfg.autoLoadPolicyTime)
+	}
 
 	// 返回新的授权器实例
-	return &Authz{enforcer}, nil
+	return &Authz{SyncedEnforcer: enforcer, tenantFunc: cfg.tenantFunc}, nil
 }
 
 // Authorize 用于进行授权.
@@ -122,3 +213,86 @@ func (a *Authz) Authorize(sub, obj, act string) (bool, error) {
 	// 调用 Enforce 方法进行授权检查
 	return a.Enforce(sub, obj, act)
 }
+
+// AuthorizeAttributes 使用请求主体和对象的属性进行授权，主体和对象可以是结构体，需要使用 ABACModel 等
+// 在匹配器中访问属性的模型.
+func (a *Authz) AuthorizeAttributes(sub, obj any, act string) (bool, error) {
+	return a.Enforce(sub, obj, act)
+}
+
+// AuthorizeInDomain 用于在域（租户）内进行授权，需要使用 DomainModel 等带域的模型.
+func (a *Authz) AuthorizeInDomain(sub, dom, obj, act string) (bool, error) {
+	return a.Enforce(sub, dom, obj, act)
+}
+
+// AuthorizeClaims 使用令牌中携带的主体及角色进行授权，主体或任一角色被授权即视为授权成功.
+// 使用带域的模型时，在令牌的租户内授权.
+func (a *Authz) AuthorizeClaims(claims *authn.Claims, obj, act string) (bool, error) {
+	if claims == nil {
+		return false, nil
+	}
+	return a.authorizeSubjects(claims.TenantID, append([]string{claims.Subject}, claims.Roles...), obj, act)
+}
+
+// AuthorizeContext 使用请求上下文中的令牌和租户进行授权，租户通过 WithTenantFunc 设置的函数获取.
+// 使用带域的模型时，令牌带有租户而与获取的租户不一致时拒绝授权，令牌中的角色只在令牌的租户内生效.
+// 不使用带域的模型时忽略租户.
+func (a *Authz) AuthorizeContext(ctx context.Context, obj, act string) (bool, error) {
+	claims, ok := authn.ClaimsFromContext(ctx)
+	if !ok {
+		return false, nil
+	}
+
+	tenant := claims.TenantID
+	if a.tenantFunc != nil {
+		tenant = a.tenantFunc(ctx)
+	}
+
+	subs := []string{claims.Subject}
+	if a.HasDomain() {
+		// 令牌只能访问其所属的租户
+		if claims.TenantID != "" && tenant != claims.TenantID {
+			return false, nil
+		}
+		// 角色是在令牌的租户内授予的，没有租户的令牌只使用主体在该租户内的角色
+		if claims.TenantID == "" {
+			return a.authorizeSubjects(tenant, subs, obj, act)
+		}
+	}
+	return a.authorizeSubjects(tenant, append(subs, claims.Roles...), obj, act)
+}
+
+// HasDomain 返回授权模型的请求是否带有域.
+func (a *Authz) HasDomain() bool {
+	r, ok := a.GetModel()["r"]["r"]
+	return ok && slices.Contains(r.Tokens, "r_dom")
+}
+
+// authorizeSubjects 依次对主体进行授权，任一主体被授权即视为授权成功.
+func (a *Authz) authorizeSubjects(dom string, subs []string, obj, act string) (bool, error) {
+	hasDomain := a.HasDomain()
+	if hasDomain && dom == "" {
+		return false, nil
+	}
+
+	for _, sub := range subs {
+		if sub == "" {
+			continue
+		}
+
+		var (
+			allowed bool
+			err     error
+		)
+		if hasDomain {
+			allowed, err = a.AuthorizeInDomain(sub, dom, obj, act)
+		} else {
+			allowed, err = a.Authorize(sub, obj, act)
+		}
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,181 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+)
+
+func TestAuthorizeInDomain(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	a, err := NewAuthz(db, WithDomainModel())
+	require.NoError(t, err)
+	t.Cleanup(a.StopAutoLoadPolicy)
+	assert.True(t, a.HasDomain())
+
+	_, err = a.AddPolicy("admin", "tenant-a", "/v1/users/*", "GET", "allow")
+	require.NoError(t, err)
+	_, err = a.AddPolicy("auditor", "*", "/v1/logs", "GET", "allow")
+	require.NoError(t, err)
+	_, err = a.AddGroupingPolicy("alice", "admin", "tenant-a")
+	require.NoError(t, err)
+	_, err = a.AddGroupingPolicy("alice", "auditor", "tenant-b")
+	require.NoError(t, err)
+
+	tests := []struct {
+		dom, obj string
+		allowed  bool
+	}{
+		{dom: "tenant-a", obj: "/v1/users/1", allowed: true},
+		{dom: "tenant-b", obj: "/v1/users/1", allowed: false},
+		{dom: "tenant-b", obj: "/v1/logs", allowed: true},
+		{dom: "tenant-a", obj: "/v1/logs", allowed: false},
+	}
+	for _, tt := range tests {
+		allowed, err := a.AuthorizeInDomain("alice", tt.dom, tt.obj, "GET")
+		require.NoError(t, err)
+		assert.Equal(t, tt.allowed, allowed, tt)
+	}
+
+	claims := &authn.Claims{TenantID: "tenant-a"}
+	claims.Subject = "alice"
+	ctx := authn.WithClaims(context.Background(), claims)
+	allowed, err := a.AuthorizeContext(ctx, "/v1/users/1", "GET")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	claims.TenantID = ""
+	allowed, err = a.AuthorizeContext(ctx, "/v1/users/1", "GET")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestAuthorizeContextAcrossTenants(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	// The tenant of the request, e.g. registered with where.RegisterTenant.
+	var tenant string
+	a, err := NewAuthz(db, WithDomainModel(), WithTenantFunc(func(ctx context.Context) string { return tenant }))
+	require.NoError(t, err)
+	t.Cleanup(a.StopAutoLoadPolicy)
+
+	_, err = a.AddPolicy("admin", "tenant-a", "/v1/users/*", "GET", "allow")
+	require.NoError(t, err)
+	_, err = a.AddPolicy("admin", "tenant-b", "/v1/users/*", "GET", "allow")
+	require.NoError(t, err)
+
+	claims := &authn.Claims{TenantID: "tenant-b", Roles: []string{"admin"}}
+	claims.Subject = "mallory"
+	ctx := authn.WithClaims(context.Background(), claims)
+
+	tenant = "tenant-b"
+	allowed, err := a.AuthorizeContext(ctx, "/v1/users/1", "GET")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// An admin of tenant-b can not act in tenant-a.
+	tenant = "tenant-a"
+	allowed, err = a.AuthorizeContext(ctx, "/v1/users/1", "GET")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// The roles of a token without tenant are not granted in any tenant.
+	claims.TenantID = ""
+	allowed, err = a.AuthorizeContext(ctx, "/v1/users/1", "GET")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestExplain(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	a, err := NewAuthz(db, WithAutoLoadPolicyTime(0))
+	require.NoError(t, err)
+
+	_, err = a.AddPolicies([][]string{
+		{"admin", "/v1/users/*", "GET", "allow"},
+		{"editor", "/v1/users/1", "GET", "allow"},
+		{"bob", "/v1/users/*", "DELETE", "deny"},
+	})
+	require.NoError(t, err)
+	_, err = a.AddGroupingPolicies([][]string{{"alice", "editor"}, {"editor", "admin"}, {"bob", "admin"}})
+	require.NoError(t, err)
+
+	exp, err := a.Explain("alice", "/v1/users/1", "GET")
+	require.NoError(t, err)
+	assert.True(t, exp.Allowed)
+	assert.Equal(t, [][]string{
+		{"admin", "/v1/users/*", "GET", "allow"},
+		{"editor", "/v1/users/1", "GET", "allow"},
+	}, exp.Matched)
+	assert.Equal(t, []string{"alice", "editor", "admin"}, exp.RoleChain)
+
+	exp, err = a.Explain("bob", "/v1/users/1", "DELETE")
+	require.NoError(t, err)
+	assert.False(t, exp.Allowed)
+	assert.Equal(t, []string{"bob", "/v1/users/*", "DELETE", "deny"}, exp.Decisive)
+	assert.Equal(t, []string{"bob"}, exp.RoleChain)
+
+	exp, err = a.Explain("carol", "/v1/users/1", "GET")
+	require.NoError(t, err)
+	assert.Empty(t, exp.Matched)
+	assert.Empty(t, exp.RoleChain)
+}
+
+func TestAuthorizeAttributes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	a, err := NewAuthz(db, WithABACModel(), WithAutoLoadPolicyTime(0))
+	require.NoError(t, err)
+
+	_, err = a.AddPolicies([][]string{
+		{"r.obj.Owner == r.sub.Name", "*", "allow"},
+		{"r.obj.Public", "read", "allow"},
+		{"r.sub.Suspended", "*", "deny"},
+	})
+	require.NoError(t, err)
+
+	type user struct {
+		Name      string
+		Suspended bool
+	}
+	type document struct {
+		Owner  string
+		Public bool
+	}
+
+	tests := []struct {
+		sub     user
+		obj     document
+		act     string
+		allowed bool
+	}{
+		{sub: user{Name: "alice"}, obj: document{Owner: "alice"}, act: "write", allowed: true},
+		{sub: user{Name: "bob"}, obj: document{Owner: "alice"}, act: "write", allowed: false},
+		{sub: user{Name: "bob"}, obj: document{Owner: "alice", Public: true}, act: "read", allowed: true},
+		{sub: user{Name: "alice", Suspended: true}, obj: document{Owner: "alice"}, act: "read", allowed: false},
+	}
+	for _, tt := range tests {
+		allowed, err := a.AuthorizeAttributes(tt.sub, tt.obj, tt.act)
+		require.NoError(t, err)
+		assert.Equal(t, tt.allowed, allowed, tt)
+	}
+
+	exp, err := a.Explain(user{Name: "alice", Suspended: true}, document{Owner: "alice"}, "read")
+	require.NoError(t, err)
+	assert.False(t, exp.Allowed)
+	assert.Len(t, exp.Matched, 2)
+	assert.Equal(t, []string{"r.sub.Suspended", "*", "deny"}, exp.Decisive)
+}
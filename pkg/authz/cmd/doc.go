@@ -0,0 +1,3 @@
+// Package cmd 提供授权相关的命令行子命令，可以通过 app.WithCommands 挂载到服务的命令行中，
+// 例如使用 `authz eval` 在上线前验证策略文件.
+package cmd // import "github.com/ydcloud-dy/publicPkg/pkg/authz/cmd"
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	casbin "github.com/casbin/casbin/v2"
+	"github.com/onexstack/onexstack/pkg/cli/genericclioptions"
+	"github.com/spf13/cobra"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+const evalExample = `  # 使用策略文件验证请求，每行一个请求，最后一列可以是期望的结果 allow 或 deny
+  authz eval --model model.conf --policy policy.csv --requests requests.csv
+
+  # requests.csv
+  alice, /api/v1/users, GET, allow
+  bob, /api/v1/users, DELETE, deny
+
+  # ABAC 模型的请求主体和对象可以是 JSON 对象，需要使用双引号包裹并转义其中的双引号
+  "{""Name"": ""alice""}", "{""Owner"": ""alice""}", write, allow
+
+  # 从标准输入读取请求，并输出命中的策略和角色链
+  echo "alice, /api/v1/users, GET" | authz eval --model model.conf --policy policy.csv --requests - --explain`
+
+// EvalOptions 是 eval 子命令的选项.
+type EvalOptions struct {
+	ModelFile   string // Casbin 模型文件
+	PolicyFile  string // CSV 格式的策略文件
+	RequestFile string // 请求文件，- 表示标准输入
+	Explain     bool   // 是否输出命中的策略和角色链
+
+	genericclioptions.IOStreams
+}
+
+// evalRequest 是请求文件中的一个请求.
+type evalRequest struct {
+	line   int
+	fields []string
+	rvals  []any
+	expect string // allow、deny 或空
+}
+
+// NewCmdAuthz 创建 authz 命令，包含授权相关的子命令.
+func NewCmdAuthz(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "authz",
+		Short: "Authorization policy tools",
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(NewCmdEval(ioStreams))
+	return cmd
+}
+
+// NewCmdEval 创建 eval 子命令，使用策略文件批量验证请求的授权结果.
+// 存在与期望结果不一致的请求时返回错误，可以在 CI 中用于验证策略变更.
+func NewCmdEval(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &EvalOptions{IOStreams: ioStreams}
+
+	cmd := &cobra.Command{
+		Use:                   "eval --model FILE --policy FILE --requests FILE",
+		DisableFlagsInUseLine: true,
+		Short:                 "Evaluate a batch of requests against a policy file",
+		Example:               evalExample,
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.ModelFile, "model", "m", o.ModelFile, "Path to the casbin model file.")
+	cmd.Flags().StringVarP(&o.PolicyFile, "policy", "p", o.PolicyFile, "Path to the CSV policy file.")
+	cmd.Flags().StringVarP(&o.RequestFile, "requests", "r", o.RequestFile, "Path to the CSV request file, - to read from stdin.")
+	cmd.Flags().BoolVar(&o.Explain, "explain", o.Explain, "Print the matched policies and the role chain of every request.")
+
+	return cmd
+}
+
+// Validate 校验选项是否合法.
+func (o *EvalOptions) Validate() error {
+	if o.ModelFile == "" {
+		return errors.New("--model is required")
+	}
+	if o.PolicyFile == "" {
+		return errors.New("--policy is required")
+	}
+	if o.RequestFile == "" {
+		return errors.New("--requests is required")
+	}
+	return nil
+}
+
+// Run 加载模型和策略，逐个验证请求并输出结果.
+func (o *EvalOptions) Run() error {
+	enforcer, err := casbin.NewSyncedEnforcer(o.ModelFile, o.PolicyFile)
+	if err != nil {
+		return err
+	}
+	a := &authz.Authz{SyncedEnforcer: enforcer}
+
+	in := o.In
+	if o.RequestFile != "-" {
+		f, err := os.Open(o.RequestFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	requests, err := readRequests(in, len(enforcer.GetModel()["r"]["r"].Tokens))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RESULT\tEXPECTED\tSTATUS\tREQUEST")
+
+	failed := 0
+	for _, req := range requests {
+		exp, err := a.ExplainRequest(req.rvals...)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", req.line, err)
+		}
+
+		result, status := "deny", "-"
+		if exp.Allowed {
+			result = "allow"
+		}
+		if req.expect != "" {
+			status = "PASS"
+			if req.expect != result {
+				status = "FAIL"
+				failed++
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result, valueOrDash(req.expect), status, strings.Join(req.fields, ", "))
+
+		if o.Explain {
+			for _, policy := range exp.Matched {
+				fmt.Fprintf(w, "\t\t\t  matched: %s\n", strings.Join(policy, ", "))
+			}
+			if len(exp.Decisive) > 0 {
+				fmt.Fprintf(w, "\t\t\t  decisive: %s\n", strings.Join(exp.Decisive, ", "))
+			}
+			if len(exp.RoleChain) > 1 {
+				fmt.Fprintf(w, "\t\t\t  role chain: %s\n", strings.Join(exp.RoleChain, " -> "))
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.Out, "%d requests, %d failed\n", len(requests), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests did not match the expected result", failed, len(requests))
+	}
+	return nil
+}
+
+// readRequests 读取 CSV 格式的请求，size 是模型中请求参数的个数，多出的一列是期望的结果.
+// 以 { 开头的参数按 JSON 对象解析，供 ABAC 模型的匹配器访问其属性.
+func readRequests(in io.Reader, size int) ([]evalRequest, error) {
+	r := csv.NewReader(in)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	var requests []evalRequest
+	for {
+		fields, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		req := evalRequest{line: line, fields: fields}
+		switch len(fields) {
+		case size:
+		case size + 1:
+			req.expect = strings.ToLower(fields[size])
+			if req.expect != "allow" && req.expect != "deny" {
+				return nil, fmt.Errorf("line %d: expected result must be allow or deny, got %q", line, fields[size])
+			}
+			req.fields = fields[:size]
+		default:
+			return nil, fmt.Errorf("line %d: expected %d request values, got %d", line, size, len(fields))
+		}
+
+		for _, field := range req.fields {
+			if !strings.HasPrefix(field, "{") {
+				req.rvals = append(req.rvals, field)
+				continue
+			}
+
+			var attrs map[string]any
+			if err := json.Unmarshal([]byte(field), &attrs); err != nil {
+				return nil, fmt.Errorf("line %d: invalid JSON object %s: %w", line, field, err)
+			}
+			req.rvals = append(req.rvals, attrs)
+		}
+
+		requests = append(requests, req)
+	}
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
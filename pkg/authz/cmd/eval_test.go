@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onexstack/onexstack/pkg/cli/genericclioptions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestEval(t *testing.T) {
+	model := writeFile(t, "model.conf", authz.DomainModel)
+	policy := writeFile(t, "policy.csv", `
+p, admin, tenant-a, /v1/users/*, GET, allow
+g, alice, admin, tenant-a
+`)
+
+	ioStreams, in, out, _ := genericclioptions.NewTestIOStreams()
+	in.WriteString("# sub, dom, obj, act, expected\n" +
+		"alice, tenant-a, /v1/users/1, GET, allow\n" +
+		"alice, tenant-b, /v1/users/1, GET\n")
+
+	cmd := NewCmdEval(ioStreams)
+	cmd.SetArgs([]string{"--model", model, "--policy", policy, "--requests", "-", "--explain"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "role chain: alice -> admin")
+	assert.Contains(t, out.String(), "2 requests, 0 failed")
+
+	ioStreams, in, out, _ = genericclioptions.NewTestIOStreams()
+	in.WriteString("alice, tenant-b, /v1/users/1, GET, allow\n")
+
+	cmd = NewCmdEval(ioStreams)
+	cmd.SetArgs([]string{"--model", model, "--policy", policy, "--requests", "-"})
+	require.Error(t, cmd.Execute())
+	assert.Contains(t, out.String(), "FAIL")
+}
+
+func TestEvalAttributes(t *testing.T) {
+	model := writeFile(t, "model.conf", authz.ABACModel)
+	policy := writeFile(t, "policy.csv", "p, r.obj.Owner == r.sub.Name, *, allow\n")
+	requests := writeFile(t, "requests.csv", `"{""Name"": ""alice""}", "{""Owner"": ""alice""}", write, allow
+"{""Name"": ""bob""}", "{""Owner"": ""alice""}", write, deny
+`)
+
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	cmd := NewCmdEval(ioStreams)
+	cmd.SetArgs([]string{"--model", model, "--policy", policy, "--requests", requests})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "2 requests, 0 failed")
+}
@@ -0,0 +1,161 @@
+package authz
+
+import (
+	"slices"
+
+	casbin "github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/effector"
+	"github.com/casbin/casbin/v2/model"
+)
+
+// Explanation 描述了一次授权的判定过程，用于排查授权失败的原因.
+type Explanation struct {
+	Allowed bool  // 授权结果
+	Request []any // 授权请求
+
+	// Matched 是匹配器命中的所有策略，按策略的存储顺序排列.
+	Matched [][]string
+	// Decisive 是决定授权结果的策略，例如被拒绝时命中的 deny 策略，没有策略决定结果时为空.
+	Decisive []string
+	// RoleChain 是请求主体继承到 Decisive 主体的角色链，例如 [alice editor admin]，没有 Decisive 时
+	// 使用命中的第一条策略. 请求主体不是字符串或没有命中策略时为空.
+	RoleChain []string
+}
+
+// Explain 对请求进行授权，并返回命中的策略和角色链. 请求主体和对象可以是结构体，参见 ABACModel.
+func (a *Authz) Explain(sub, obj any, act string) (*Explanation, error) {
+	return a.ExplainRequest(sub, obj, act)
+}
+
+// ExplainInDomain 在域（租户）内对请求进行授权，并返回命中的策略和角色链.
+func (a *Authz) ExplainInDomain(sub, dom string, obj any, act string) (*Explanation, error) {
+	return a.ExplainRequest(sub, dom, obj, act)
+}
+
+// ExplainRequest 使用任意请求参数进行授权，并返回命中的策略和角色链，参数需要与模型的 request_definition 一致.
+// 为了不影响正在使用的授权器，判定在策略的副本上进行，只适合排查问题，不要在请求链路上调用.
+func (a *Authz) ExplainRequest(rvals ...any) (*Explanation, error) {
+	lock := a.GetLock()
+	lock.RLock()
+	m := a.GetModel().Copy()
+	lock.RUnlock()
+
+	e, err := casbin.NewEnforcer(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.BuildRoleLinks(); err != nil {
+		return nil, err
+	}
+
+	recorder := &recordingEffector{Effector: effector.NewDefaultEffector()}
+	e.SetEffector(recorder)
+
+	allowed, decisive, err := e.EnforceEx(rvals...)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := m["p"]["p"].Policy
+	exp := &Explanation{Allowed: allowed, Request: rvals}
+	for _, index := range recorder.matched {
+		// 没有策略时 casbin 仍会调用一次 Effector
+		if index >= len(policies) {
+			continue
+		}
+		exp.Matched = append(exp.Matched, policies[index])
+	}
+	if len(decisive) > 0 {
+		exp.Decisive = decisive
+		exp.RoleChain = roleChain(e, m, rvals, decisive)
+	} else if len(exp.Matched) > 0 {
+		exp.RoleChain = roleChain(e, m, rvals, exp.Matched[0])
+	}
+
+	return exp, nil
+}
+
+// roleChain 查找请求主体继承到策略主体的最短角色链.
+func roleChain(e *casbin.Enforcer, m model.Model, rvals []any, policy []string) []string {
+	rTokens, pTokens := m["r"]["r"].Tokens, m["p"]["p"].Tokens
+	subIndex, pSubIndex := slices.Index(rTokens, "r_sub"), slices.Index(pTokens, "p_sub")
+	if subIndex < 0 || pSubIndex < 0 || subIndex >= len(rvals) || pSubIndex >= len(policy) {
+		return nil
+	}
+	sub, ok := rvals[subIndex].(string)
+	if !ok {
+		return nil
+	}
+
+	var domain []string
+	if domIndex := slices.Index(rTokens, "r_dom"); domIndex >= 0 && domIndex < len(rvals) {
+		if dom, ok := rvals[domIndex].(string); ok {
+			domain = append(domain, dom)
+		}
+	}
+
+	target := policy[pSubIndex]
+	if sub == target {
+		return []string{sub}
+	}
+
+	// 广度优先搜索，parent 记录每个角色是从哪个主体继承来的
+	rm := e.GetRoleManager()
+	parent := map[string]string{sub: ""}
+	queue := []string{sub}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		roles, err := rm.GetRoles(name, domain...)
+		if err != nil {
+			return nil
+		}
+		for _, role := range roles {
+			if _, ok := parent[role]; ok {
+				continue
+			}
+			parent[role] = name
+			if role != target {
+				queue = append(queue, role)
+				continue
+			}
+
+			chain := []string{role}
+			for p := name; p != ""; p = parent[p] {
+				chain = append(chain, p)
+			}
+			slices.Reverse(chain)
+			return chain
+		}
+	}
+
+	return nil
+}
+
+// recordingEffector 记录匹配器命中的所有策略. casbin 在结果确定后会停止匹配后续策略，
+// 所以在最后一条策略之前始终返回 Indeterminate，再按 casbin 的顺序重放内部 Effector 得到相同的结果.
+type recordingEffector struct {
+	effector.Effector
+
+	matched []int
+}
+
+// MergeEffects 实现 effector.Effector 接口.
+func (r *recordingEffector) MergeEffects(expr string, effects []effector.Effect, matches []float64, policyIndex int, policyLength int) (effector.Effect, int, error) {
+	if matches[policyIndex] != 0 {
+		r.matched = append(r.matched, policyIndex)
+	}
+	if policyIndex < policyLength-1 {
+		return effector.Indeterminate, -1, nil
+	}
+
+	for i := 0; i < policyLength; i++ {
+		effect, index, err := r.Effector.MergeEffects(expr, effects, matches, i, policyLength)
+		if err != nil || effect != effector.Indeterminate || i == policyLength-1 {
+			return effect, index, err
+		}
+	}
+
+	return effector.Indeterminate, -1, nil
+}
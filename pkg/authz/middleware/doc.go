@@ -0,0 +1,4 @@
+// Package middleware 为 Gin、gRPC 和 Kratos 提供基于 authz.Authz 的授权中间件.
+// 中间件需要注册在 authn 认证中间件之后，从请求上下文中获取主体，
+// 并根据规则将路由和方法映射为授权的资源和操作.
+package middleware // import "github.com/ydcloud-dy/publicPkg/pkg/authz/middleware"
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/onexstack/onexstack/pkg/core"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+// Gin 返回 Gin 授权中间件，请求的 Operation 为路由模板.
+func Gin(a *authz.Authz, opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts...)
+
+	return func(c *gin.Context) {
+		if err := authorize(c.Request.Context(), a, o, c.FullPath(), c.Request.Method); err != nil {
+			core.WriteResponse(c, nil, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
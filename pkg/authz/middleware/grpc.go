@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+// UnaryServerInterceptor 返回 gRPC 一元授权拦截器，请求的 Operation 为完整方法名.
+func UnaryServerInterceptor(a *authz.Authz, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts...)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := authorize(ctx, a, o, info.FullMethod, ""); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 返回 gRPC 流式授权拦截器.
+func StreamServerInterceptor(a *authz.Authz, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts...)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), a, o, info.FullMethod, ""); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+
+	"github.com/onexstack/onexstack/pkg/errorsx"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+// Kratos 返回 Kratos 授权中间件，请求的 Operation 为 transport 的 Operation，
+// HTTP 请求的操作默认为 HTTP 方法.
+func Kratos(a *authz.Authz, opts ...Option) middleware.Middleware {
+	o := newOptions(opts...)
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req any) (any, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return nil, errorsx.New(errorsx.ErrPermissionDenied.Code, errorsx.ErrPermissionDenied.Reason, "Missing transport information.")
+			}
+
+			var method string
+			if ht, ok := tr.(http.Transporter); ok {
+				method = ht.Request().Method
+			}
+			if err := authorize(ctx, a, o, tr.Operation(), method); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/onexstack/onexstack/pkg/errorsx"
+	"github.com/onexstack/onexstack/pkg/log"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+// defaultRPCAction 是 gRPC 请求未配置规则时使用的操作.
+const defaultRPCAction = "call"
+
+// Rule 将请求映射为授权的资源和操作.
+type Rule struct {
+	// Operation 匹配 Gin 的路由模板（例如 `/v1/users/:id`）或 gRPC、Kratos 的完整方法名
+	// （例如 `/api.v1.User/Get`），以 * 结尾时按前缀匹配.
+	Operation string
+	// Method 匹配 HTTP 方法，为空时匹配所有方法.
+	Method string
+	// Object 是授权的资源，为空时使用请求的 Operation.
+	Object string
+	// Action 是授权的操作，为空时使用请求的 HTTP 方法，gRPC 请求使用 call.
+	Action string
+}
+
+// match 返回规则是否匹配请求.
+func (r *Rule) match(operation, method string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Operation, "*"); ok {
+		return strings.HasPrefix(operation, prefix)
+	}
+	return r.Operation == operation
+}
+
+// Decision 是一次授权的结果，用于记录授权日志.
+type Decision struct {
+	Subject   string
+	Tenant    string
+	Operation string
+	Method    string
+	Object    string
+	Action    string
+	Allowed   bool
+	Err       error
+}
+
+// Option 定义了中间件的函数选项.
+type Option func(*options)
+
+type options struct {
+	rules      []Rule
+	operations map[string]struct{}
+	prefixes   []string
+	logger     func(ctx context.Context, d *Decision)
+}
+
+// WithRules 设置映射规则，按顺序使用第一个匹配的规则. 没有匹配的规则时，
+// 资源为请求的 Operation，操作为 HTTP 方法或 call.
+func WithRules(rules ...Rule) Option {
+	return func(o *options) {
+		o.rules = append(o.rules, rules...)
+	}
+}
+
+// WithSkipOperations 跳过指定请求的授权，以 * 结尾时按前缀匹配.
+func WithSkipOperations(operations ...string) Option {
+	return func(o *options) {
+		for _, op := range operations {
+			if prefix, ok := strings.CutSuffix(op, "*"); ok {
+				o.prefixes = append(o.prefixes, prefix)
+				continue
+			}
+			o.operations[op] = struct{}{}
+		}
+	}
+}
+
+// WithDecisionLogger 设置授权日志的记录函数.
+func WithDecisionLogger(logger func(ctx context.Context, d *Decision)) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithLogDecisions 使用 log 包记录每一次授权的结果.
+func WithLogDecisions() Option {
+	return WithDecisionLogger(func(ctx context.Context, d *Decision) {
+		log.W(ctx).Infow("Authorization decision",
+			"subject", d.Subject, "tenant", d.Tenant, "operation", d.Operation, "method", d.Method,
+			"object", d.Object, "action", d.Action, "allowed", d.Allowed, "err", d.Err)
+	})
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{operations: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// skip 返回请求是否跳过授权.
+func (o *options) skip(operation string) bool {
+	if _, ok := o.operations[operation]; ok {
+		return true
+	}
+	for _, prefix := range o.prefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 返回请求对应的资源和操作.
+func (o *options) resolve(operation, method string) (string, string) {
+	obj, act := operation, method
+	for i := range o.rules {
+		if !o.rules[i].match(operation, method) {
+			continue
+		}
+		if o.rules[i].Object != "" {
+			obj = o.rules[i].Object
+		}
+		if o.rules[i].Action != "" {
+			act = o.rules[i].Action
+		}
+		break
+	}
+	return obj, act
+}
+
+// authorize 对请求进行授权，method 为空表示 gRPC 请求.
+func authorize(ctx context.Context, a *authz.Authz, o *options, operation, method string) error {
+	if o.skip(operation) {
+		return nil
+	}
+
+	if method == "" {
+		method = defaultRPCAction
+	}
+	obj, act := o.resolve(operation, method)
+
+	claims, ok := authn.ClaimsFromContext(ctx)
+	d := &Decision{Operation: operation, Method: method, Object: obj, Action: act}
+	if ok {
+		d.Subject, d.Tenant = claims.Subject, claims.TenantID
+		d.Allowed, d.Err = a.AuthorizeContext(ctx, obj, act)
+	}
+	if o.logger != nil {
+		o.logger(ctx, d)
+	}
+
+	switch {
+	case !ok:
+		return errorsx.New(errorsx.ErrUnauthenticated.Code, errorsx.ErrUnauthenticated.Reason, "%s", errorsx.ErrUnauthenticated.Message)
+	case d.Err != nil:
+		return errorsx.New(errorsx.ErrInternal.Code, errorsx.ErrInternal.Reason, "%s", errorsx.ErrInternal.Message)
+	case !d.Allowed:
+		return errorsx.New(errorsx.ErrPermissionDenied.Code, errorsx.ErrPermissionDenied.Reason, "%s", errorsx.ErrPermissionDenied.Message)
+	}
+	return nil
+}
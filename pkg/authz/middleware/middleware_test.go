@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+func newAuthz(t *testing.T) *authz.Authz {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	a, err := authz.NewAuthz(db)
+	require.NoError(t, err)
+	t.Cleanup(a.StopAutoLoadPolicy)
+
+	// 默认模型允许所有未被拒绝的请求.
+	_, err = a.AddPolicy("user-2", "users", "GET", "deny")
+	require.NoError(t, err)
+	_, err = a.AddPolicy("user-2", "/api.v1.User/Get", "call", "deny")
+	require.NoError(t, err)
+	return a
+}
+
+func withSubject(ctx context.Context, subject string) context.Context {
+	claims := &authn.Claims{}
+	claims.Subject = subject
+	return authn.WithClaims(ctx, claims)
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var decisions []*Decision
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if sub := c.GetHeader("X-Subject"); sub != "" {
+			c.Request = c.Request.WithContext(withSubject(c.Request.Context(), sub))
+		}
+	})
+	r.Use(Gin(newAuthz(t),
+		WithRules(Rule{Operation: "/v1/users/*", Object: "users"}),
+		WithSkipOperations("/healthz"),
+		WithDecisionLogger(func(ctx context.Context, d *Decision) { decisions = append(decisions, d) }),
+	))
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/v1/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		subject string
+		path    string
+		code    int
+	}{
+		{path: "/healthz", code: http.StatusOK},
+		{path: "/v1/users/1", code: http.StatusUnauthorized},
+		{subject: "user-1", path: "/v1/users/1", code: http.StatusOK},
+		{subject: "user-2", path: "/v1/users/1", code: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.subject != "" {
+			req.Header.Set("X-Subject", tt.subject)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, tt)
+	}
+
+	require.Len(t, decisions, 3)
+	assert.Equal(t, Decision{Subject: "user-2", Operation: "/v1/users/:id", Method: "GET", Object: "users", Action: "GET"}, *decisions[2])
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(newAuthz(t))
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/Get"}
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	resp, err := interceptor(withSubject(context.Background(), "user-1"), nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = interceptor(withSubject(context.Background(), "user-2"), nil, info, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
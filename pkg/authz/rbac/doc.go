@@ -0,0 +1,3 @@
+// Package rbac 在 authz.Authz 之上提供类型化的角色和权限管理服务，
+// 并提供可选的 Gin 和 gRPC 管理接口.
+package rbac // import "github.com/ydcloud-dy/publicPkg/pkg/authz/rbac"
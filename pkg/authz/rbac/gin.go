@@ -0,0 +1,43 @@
+package rbac
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/onexstack/onexstack/pkg/core"
+	"github.com/onexstack/onexstack/pkg/errorsx"
+)
+
+// RegisterGin 将管理接口注册到 Gin 路由，例如:
+//
+//	POST   /roles                            创建角色
+//	POST   /roles/:name/permissions          授予角色权限
+//	POST   /subjects/:subject/roles          为主体分配角色
+//	GET    /subjects/:subject/permissions    查询主体的有效权限
+func (h *Handler) RegisterGin(r gin.IRouter) {
+	for _, op := range h.operations() {
+		r.Handle(op.method, op.path, func(c *gin.Context) {
+			var rq request
+			if err := bind(c, &rq); err != nil {
+				core.WriteResponse(c, nil, err)
+				return
+			}
+
+			resp, err := h.handle(c.Request.Context(), op, &rq)
+			core.WriteResponse(c, resp, err)
+		})
+	}
+}
+
+// bind 绑定路由参数和请求体.
+func bind(c *gin.Context, rq *request) error {
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(rq); err != nil {
+			return errorsx.New(errorsx.ErrBind.Code, errorsx.ErrBind.Reason, "%s", err.Error())
+		}
+	}
+	// 路由参数优先于请求体.
+	if err := c.ShouldBindUri(rq); err != nil {
+		return errorsx.New(errorsx.ErrBind.Code, errorsx.ErrBind.Reason, "%s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ServiceName 是 gRPC 管理服务的名称，方法的请求和响应均为 google.protobuf.Struct，
+// 字段与 Gin 接口的 JSON 字段相同，例如 /rbac.v1.RBAC/CreateRole.
+const ServiceName = "rbac.v1.RBAC"
+
+// RegisterGRPC 将管理接口注册到 gRPC 服务.
+func (h *Handler) RegisterGRPC(s grpc.ServiceRegistrar) {
+	desc := grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*any)(nil),
+		Metadata:    "rbac.proto",
+	}
+	for _, op := range h.operations() {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: op.name,
+			Handler:    h.grpcHandler(op),
+		})
+	}
+	s.RegisterService(&desc, h)
+}
+
+// grpcHandler 将管理接口适配为 gRPC 方法.
+func (h *Handler) grpcHandler(op operation) grpc.MethodHandler {
+	return func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+
+		handler := func(ctx context.Context, req any) (any, error) {
+			var rq request
+			if err := fromStruct(req.(*structpb.Struct), &rq); err != nil {
+				return nil, err
+			}
+
+			resp, err := h.handle(ctx, op, &rq)
+			if err != nil {
+				return nil, err
+			}
+			return toStruct(resp)
+		}
+		if interceptor == nil {
+			return handler(ctx, in)
+		}
+		return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: h, FullMethod: "/" + ServiceName + "/" + op.name}, handler)
+	}
+}
+
+func fromStruct(in *structpb.Struct, rq *request) error {
+	data, err := protojson.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, rq)
+}
+
+func toStruct(v any) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(structpb.Struct)
+	if err := protojson.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/onexstack/onexstack/pkg/errorsx"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+const (
+	// ActionRead 是查询角色和权限需要的操作.
+	ActionRead = "read"
+	// ActionWrite 是修改角色和权限需要的操作.
+	ActionWrite = "write"
+
+	// defaultObject 是管理接口默认保护的资源.
+	defaultObject = "rbac"
+)
+
+// Handler 通过 Gin 和 gRPC 暴露 RBAC 管理接口. 接口本身也由 authz 保护，
+// 调用者需要拥有 object 资源上的 read 或 write 权限，调用者的身份从
+// authn.ClaimsFromContext 获取，因此需要在认证中间件之后注册.
+type Handler struct {
+	svc    *RBAC
+	authz  *authz.Authz
+	object string
+}
+
+// HandlerOption 定义了 Handler 的函数选项.
+type HandlerOption func(*Handler)
+
+// WithObject 设置管理接口受保护的资源，默认为 rbac.
+func WithObject(object string) HandlerOption {
+	return func(h *Handler) {
+		h.object = object
+	}
+}
+
+// NewHandler 创建 RBAC 管理接口.
+func NewHandler(svc *RBAC, a *authz.Authz, opts ...HandlerOption) *Handler {
+	h := &Handler{svc: svc, authz: a, object: defaultObject}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// request 是所有管理接口共用的请求参数.
+type request struct {
+	// Name 是角色名.
+	Name        string `json:"name" uri:"name"`
+	Description string `json:"description"`
+	Subject     string `json:"subject" uri:"subject"`
+	// Role 是分配给主体的角色名.
+	Role string `json:"role" uri:"role"`
+	Permission
+}
+
+// operation 描述一个管理接口，Gin 和 gRPC 共用同一份定义.
+type operation struct {
+	name   string // gRPC 方法名
+	action string // 调用者需要的操作
+	method string // HTTP 方法
+	path   string // HTTP 路由
+	call   func(ctx context.Context, rq *request) (any, error)
+}
+
+// operations 返回所有管理接口.
+func (h *Handler) operations() []operation {
+	return []operation{
+		{"CreateRole", ActionWrite, "POST", "/roles", func(ctx context.Context, rq *request) (any, error) {
+			return h.svc.CreateRole(ctx, rq.Name, rq.Description)
+		}},
+		{"ListRoles", ActionRead, "GET", "/roles", func(ctx context.Context, rq *request) (any, error) {
+			roles, err := h.svc.ListRoles(ctx)
+			return map[string]any{"roles": roles}, err
+		}},
+		{"DeleteRole", ActionWrite, "DELETE", "/roles/:name", func(ctx context.Context, rq *request) (any, error) {
+			return struct{}{}, h.svc.DeleteRole(ctx, rq.Name)
+		}},
+		{"GrantPermission", ActionWrite, "POST", "/roles/:name/permissions", func(ctx context.Context, rq *request) (any, error) {
+			return struct{}{}, h.svc.GrantPermission(ctx, rq.Name, rq.Permission)
+		}},
+		{"RevokePermission", ActionWrite, "DELETE", "/roles/:name/permissions", func(ctx context.Context, rq *request) (any, error) {
+			return struct{}{}, h.svc.RevokePermission(ctx, rq.Name, rq.Permission)
+		}},
+		{"ListPermissions", ActionRead, "GET", "/roles/:name/permissions", func(ctx context.Context, rq *request) (any, error) {
+			perms, err := h.svc.ListPermissions(ctx, rq.Name)
+			return map[string]any{"permissions": perms}, err
+		}},
+		{"AssignRole", ActionWrite, "POST", "/subjects/:subject/roles", func(ctx context.Context, rq *request) (any, error) {
+			return struct{}{}, h.svc.AssignRole(ctx, rq.Subject, rq.Role)
+		}},
+		{"UnassignRole", ActionWrite, "DELETE", "/subjects/:subject/roles/:role", func(ctx context.Context, rq *request) (any, error) {
+			return struct{}{}, h.svc.UnassignRole(ctx, rq.Subject, rq.Role)
+		}},
+		{"ListSubjectRoles", ActionRead, "GET", "/subjects/:subject/roles", func(ctx context.Context, rq *request) (any, error) {
+			roles, err := h.svc.ListSubjectRoles(ctx, rq.Subject)
+			return map[string]any{"roles": roles}, err
+		}},
+		{"ListEffectivePermissions", ActionRead, "GET", "/subjects/:subject/permissions", func(ctx context.Context, rq *request) (any, error) {
+			perms, err := h.svc.ListEffectivePermissions(ctx, rq.Subject)
+			return map[string]any{"permissions": perms}, err
+		}},
+	}
+}
+
+// authorize 校验调用者是否拥有执行 action 的权限.
+func (h *Handler) authorize(ctx context.Context, action string) error {
+	claims, ok := authn.ClaimsFromContext(ctx)
+	if !ok {
+		return errorsx.New(errorsx.ErrUnauthenticated.Code, errorsx.ErrUnauthenticated.Reason, "%s", errorsx.ErrUnauthenticated.Message)
+	}
+
+	allowed, err := h.authz.AuthorizeClaims(claims, h.object, action)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errorsx.New(errorsx.ErrPermissionDenied.Code, errorsx.ErrPermissionDenied.Reason, "%s", errorsx.ErrPermissionDenied.Message)
+	}
+	return nil
+}
+
+// handle 校验调用者权限后执行管理接口.
+func (h *Handler) handle(ctx context.Context, op operation, rq *request) (any, error) {
+	if err := h.authorize(ctx, op.action); err != nil {
+		return nil, err
+	}
+	return op.call(ctx, rq)
+}
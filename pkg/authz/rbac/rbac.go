@@ -0,0 +1,267 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/onexstack/onexstack/pkg/errorsx"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+const (
+	// EffectAllow 表示允许访问.
+	EffectAllow = "allow"
+	// EffectDeny 表示拒绝访问，优先于允许.
+	EffectDeny = "deny"
+)
+
+var (
+	// ErrRoleNotFound 表示角色不存在.
+	ErrRoleNotFound = &errorsx.ErrorX{Code: http.StatusNotFound, Reason: "NotFound.Role", Message: "Role not found."}
+	// ErrRoleAlreadyExists 表示角色已存在.
+	ErrRoleAlreadyExists = &errorsx.ErrorX{Code: http.StatusConflict, Reason: "AlreadyExists.Role", Message: "Role already exists."}
+)
+
+// nameRegexp 限制角色和主体的名称，避免与 Casbin 的匹配语法冲突.
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:@-]{0,63}$`)
+
+// Role 是角色的数据库记录. Casbin 本身不区分角色和用户，由该表登记哪些主体是角色.
+type Role struct {
+	Name        string    `gorm:"primaryKey;size:64" json:"name"`
+	Description string    `gorm:"size:255" json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// TableName 返回角色表名.
+func (Role) TableName() string {
+	return "authz_role"
+}
+
+// Permission 表示对资源执行某个操作的权限.
+type Permission struct {
+	// Object 是资源，支持 keyMatch 通配，例如 `/v1/users/*`.
+	Object string `json:"object"`
+	// Action 是操作，例如 `GET` 或 `read`.
+	Action string `json:"action"`
+	// Effect 是 allow 或 deny，为空时为 allow.
+	Effect string `json:"effect,omitempty"`
+}
+
+// RBAC 是基于 authz.Authz 的角色权限管理服务.
+type RBAC struct {
+	authz *authz.Authz
+	db    *gorm.DB
+}
+
+// New 创建 RBAC 服务，并迁移角色表.
+func New(a *authz.Authz, db *gorm.DB) (*RBAC, error) {
+	if err := db.AutoMigrate(&Role{}); err != nil {
+		return nil, err
+	}
+	return &RBAC{authz: a, db: db}, nil
+}
+
+// CreateRole 创建角色.
+func (r *RBAC) CreateRole(ctx context.Context, name string, description string) (*Role, error) {
+	if err := validateName("role", name); err != nil {
+		return nil, err
+	}
+
+	role := &Role{Name: name, Description: description, CreatedAt: time.Now()}
+	result := r.db.WithContext(ctx).Where(Role{Name: name}).FirstOrCreate(role)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRoleAlreadyExists
+	}
+	return role, nil
+}
+
+// GetRole 返回指定的角色.
+func (r *RBAC) GetRole(ctx context.Context, name string) (*Role, error) {
+	var roles []*Role
+	if err := r.db.WithContext(ctx).Where("name = ?", name).Limit(1).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, ErrRoleNotFound
+	}
+	return roles[0], nil
+}
+
+// ListRoles 返回所有角色.
+func (r *RBAC) ListRoles(ctx context.Context) ([]*Role, error) {
+	var roles []*Role
+	if err := r.db.WithContext(ctx).Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// DeleteRole 删除角色，以及角色的权限和所有主体的该角色.
+func (r *RBAC) DeleteRole(ctx context.Context, name string) error {
+	if _, err := r.GetRole(ctx, name); err != nil {
+		return err
+	}
+
+	if _, err := r.authz.DeleteRole(name); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Delete(&Role{}, "name = ?", name).Error
+}
+
+// GrantPermission 授予角色权限.
+func (r *RBAC) GrantPermission(ctx context.Context, role string, perm Permission) error {
+	perm, err := r.checkPermission(ctx, role, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.authz.AddPolicy(role, perm.Object, perm.Action, perm.Effect)
+	return err
+}
+
+// RevokePermission 收回角色的权限.
+func (r *RBAC) RevokePermission(ctx context.Context, role string, perm Permission) error {
+	perm, err := r.checkPermission(ctx, role, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.authz.RemovePolicy(role, perm.Object, perm.Action, perm.Effect)
+	return err
+}
+
+// ListPermissions 返回直接授予角色的权限.
+func (r *RBAC) ListPermissions(ctx context.Context, role string) ([]Permission, error) {
+	if _, err := r.GetRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	policies, err := r.authz.GetPermissionsForUser(role)
+	if err != nil {
+		return nil, err
+	}
+	return toPermissions(policies), nil
+}
+
+// AssignRole 为主体分配角色，主体可以是用户，也可以是另一个角色.
+func (r *RBAC) AssignRole(ctx context.Context, subject string, role string) error {
+	if err := validateName("subject", subject); err != nil {
+		return err
+	}
+	if _, err := r.GetRole(ctx, role); err != nil {
+		return err
+	}
+	if subject == role {
+		return invalidArgument("a role can not be assigned to itself")
+	}
+
+	_, err := r.authz.AddRoleForUser(subject, role)
+	return err
+}
+
+// UnassignRole 取消主体的角色.
+func (r *RBAC) UnassignRole(ctx context.Context, subject string, role string) error {
+	if err := validateName("subject", subject); err != nil {
+		return err
+	}
+	if err := validateName("role", role); err != nil {
+		return err
+	}
+
+	_, err := r.authz.DeleteRoleForUser(subject, role)
+	return err
+}
+
+// ListSubjectRoles 返回主体直接或间接拥有的角色.
+func (r *RBAC) ListSubjectRoles(ctx context.Context, subject string) ([]string, error) {
+	if err := validateName("subject", subject); err != nil {
+		return nil, err
+	}
+
+	roles, err := r.authz.GetImplicitRolesForUser(subject)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(roles)
+	return roles, nil
+}
+
+// ListEffectivePermissions 返回主体直接拥有和通过角色继承的全部权限.
+func (r *RBAC) ListEffectivePermissions(ctx context.Context, subject string) ([]Permission, error) {
+	if err := validateName("subject", subject); err != nil {
+		return nil, err
+	}
+
+	policies, err := r.authz.GetImplicitPermissionsForUser(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := toPermissions(policies)
+	slices.SortFunc(perms, func(a, b Permission) int {
+		return strings.Compare(a.Object+" "+a.Action+" "+a.Effect, b.Object+" "+b.Action+" "+b.Effect)
+	})
+	return slices.Compact(perms), nil
+}
+
+// checkPermission 校验角色和权限，并补全默认的 Effect.
+func (r *RBAC) checkPermission(ctx context.Context, role string, perm Permission) (Permission, error) {
+	if _, err := r.GetRole(ctx, role); err != nil {
+		return perm, err
+	}
+
+	perm.Object = strings.TrimSpace(perm.Object)
+	perm.Action = strings.TrimSpace(perm.Action)
+	if perm.Effect == "" {
+		perm.Effect = EffectAllow
+	}
+
+	switch {
+	case perm.Object == "" || strings.ContainsAny(perm.Object, " ,"):
+		return perm, invalidArgument("invalid permission object %q", perm.Object)
+	case perm.Action == "" || strings.ContainsAny(perm.Action, " ,"):
+		return perm, invalidArgument("invalid permission action %q", perm.Action)
+	case perm.Effect != EffectAllow && perm.Effect != EffectDeny:
+		return perm, invalidArgument("permission effect must be %q or %q", EffectAllow, EffectDeny)
+	}
+	return perm, nil
+}
+
+// toPermissions 将 Casbin 策略 (sub, obj, act, eft) 转换为权限.
+func toPermissions(policies [][]string) []Permission {
+	perms := make([]Permission, 0, len(policies))
+	for _, p := range policies {
+		if len(p) < 3 {
+			continue
+		}
+
+		perm := Permission{Object: p[1], Action: p[2], Effect: EffectAllow}
+		if len(p) > 3 && p[3] != "" {
+			perm.Effect = p[3]
+		}
+		perms = append(perms, perm)
+	}
+	return perms
+}
+
+func validateName(kind string, name string) error {
+	if !nameRegexp.MatchString(name) {
+		return invalidArgument("invalid %s name %q", kind, name)
+	}
+	return nil
+}
+
+// invalidArgument 返回一个新的 errorsx.ErrInvalidArgument 错误，共享的错误实例不能被修改.
+func invalidArgument(format string, args ...any) *errorsx.ErrorX {
+	return errorsx.New(errorsx.ErrInvalidArgument.Code, errorsx.ErrInvalidArgument.Reason, format, args...)
+}
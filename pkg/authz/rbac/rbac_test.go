@@ -0,0 +1,179 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/onexstack/onexstack/pkg/errorsx"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authn"
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+)
+
+// allowModel 只允许显式授权的请求，默认模型允许所有未被拒绝的请求.
+const allowModel = `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = g(r.sub, p.sub) && keyMatch(r.obj, p.obj) && r.act == p.act`
+
+func newRBAC(t *testing.T) (*RBAC, *authz.Authz) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	a, err := authz.NewAuthz(db, authz.WithAclModel(allowModel))
+	require.NoError(t, err)
+	t.Cleanup(a.StopAutoLoadPolicy)
+
+	svc, err := New(a, db)
+	require.NoError(t, err)
+	return svc, a
+}
+
+func TestRBAC(t *testing.T) {
+	ctx := context.Background()
+	svc, a := newRBAC(t)
+
+	_, err := svc.CreateRole(ctx, "viewer", "read only")
+	require.NoError(t, err)
+	_, err = svc.CreateRole(ctx, "editor", "")
+	require.NoError(t, err)
+	_, err = svc.CreateRole(ctx, "viewer", "")
+	assert.True(t, errorsx.Is(err, ErrRoleAlreadyExists))
+	_, err = svc.CreateRole(ctx, "bad name", "")
+	assert.True(t, errorsx.Is(err, errorsx.ErrInvalidArgument))
+
+	require.NoError(t, svc.GrantPermission(ctx, "viewer", Permission{Object: "/v1/posts/*", Action: "GET"}))
+	require.NoError(t, svc.GrantPermission(ctx, "editor", Permission{Object: "/v1/posts/*", Action: "PUT"}))
+	assert.True(t, errorsx.Is(svc.GrantPermission(ctx, "admin", Permission{Object: "/", Action: "GET"}), ErrRoleNotFound))
+	assert.True(t, errorsx.Is(svc.GrantPermission(ctx, "viewer", Permission{Object: "/", Action: "GET", Effect: "maybe"}), errorsx.ErrInvalidArgument))
+
+	// editor 继承 viewer 的权限.
+	require.NoError(t, svc.AssignRole(ctx, "editor", "viewer"))
+	require.NoError(t, svc.AssignRole(ctx, "user-1", "editor"))
+
+	roles, err := svc.ListSubjectRoles(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"editor", "viewer"}, roles)
+
+	perms, err := svc.ListEffectivePermissions(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []Permission{
+		{Object: "/v1/posts/*", Action: "GET", Effect: EffectAllow},
+		{Object: "/v1/posts/*", Action: "PUT", Effect: EffectAllow},
+	}, perms)
+
+	allowed, err := a.Authorize("user-1", "/v1/posts/1", "GET")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	require.NoError(t, svc.DeleteRole(ctx, "viewer"))
+	allowed, err = a.Authorize("user-1", "/v1/posts/1", "GET")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestHandlerGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	svc, a := newRBAC(t)
+
+	_, err := svc.CreateRole(ctx, "rbac-admin", "")
+	require.NoError(t, err)
+	require.NoError(t, svc.GrantPermission(ctx, "rbac-admin", Permission{Object: defaultObject, Action: ActionWrite}))
+	require.NoError(t, svc.GrantPermission(ctx, "rbac-admin", Permission{Object: defaultObject, Action: ActionRead}))
+	require.NoError(t, svc.AssignRole(ctx, "admin-1", "rbac-admin"))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if sub := c.GetHeader("X-Subject"); sub != "" {
+			claims := &authn.Claims{}
+			claims.Subject = sub
+			c.Request = c.Request.WithContext(authn.WithClaims(c.Request.Context(), claims))
+		}
+	})
+	NewHandler(svc, a).RegisterGin(r.Group("/v1/rbac"))
+
+	do := func(subject, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if subject != "" {
+			req.Header.Set("X-Subject", subject)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do("", "POST", "/v1/rbac/roles", `{"name":"viewer"}`).Code)
+	assert.Equal(t, http.StatusForbidden, do("user-1", "POST", "/v1/rbac/roles", `{"name":"viewer"}`).Code)
+	assert.Equal(t, http.StatusOK, do("admin-1", "POST", "/v1/rbac/roles", `{"name":"viewer"}`).Code)
+	assert.Equal(t, http.StatusOK, do("admin-1", "POST", "/v1/rbac/roles/viewer/permissions", `{"object":"/v1/posts/*","action":"GET"}`).Code)
+	assert.Equal(t, http.StatusOK, do("admin-1", "POST", "/v1/rbac/subjects/user-1/roles", `{"role":"viewer"}`).Code)
+
+	w := do("admin-1", "GET", "/v1/rbac/subjects/user-1/permissions", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"permissions":[{"object":"/v1/posts/*","action":"GET","effect":"allow"}]}`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, do("admin-1", "DELETE", "/v1/rbac/roles/missing", "").Code)
+}
+
+func TestHandlerGRPC(t *testing.T) {
+	svc, a := newRBAC(t)
+	h := NewHandler(svc, a)
+
+	var createRole operation
+	for _, op := range h.operations() {
+		if op.name == "CreateRole" {
+			createRole = op
+		}
+	}
+
+	in, err := structpb.NewStruct(map[string]any{"name": "viewer"})
+	require.NoError(t, err)
+	dec := func(v any) error {
+		proto.Merge(v.(*structpb.Struct), in)
+		return nil
+	}
+
+	_, err = h.grpcHandler(createRole)(nil, context.Background(), dec, nil)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	require.NoError(t, svc.AssignRole(context.Background(), "admin-1", mustCreateAdmin(t, svc)))
+	claims := &authn.Claims{}
+	claims.Subject = "admin-1"
+	out, err := h.grpcHandler(createRole)(nil, authn.WithClaims(context.Background(), claims), dec, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "viewer", out.(*structpb.Struct).Fields["name"].GetStringValue())
+}
+
+func mustCreateAdmin(t *testing.T, svc *RBAC) string {
+	ctx := context.Background()
+	_, err := svc.CreateRole(ctx, "rbac-admin", "")
+	require.NoError(t, err)
+	require.NoError(t, svc.GrantPermission(ctx, "rbac-admin", Permission{Object: defaultObject, Action: ActionWrite}))
+	return "rbac-admin"
+}
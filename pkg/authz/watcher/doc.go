@@ -0,0 +1,3 @@
+// Package watcher 实现了 Casbin 的 Watcher，通过 Redis 发布订阅、etcd 或 Kafka
+// 将策略变更推送到其它副本，副本按变更增量更新内存中的策略，无需重新加载整张策略表.
+package watcher // import "github.com/ydcloud-dy/publicPkg/pkg/authz/watcher"
@@ -0,0 +1,49 @@
+package watcher
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdTransport 通过写入和监听 etcd 的键广播通知.
+type etcdTransport struct {
+	cli *clientv3.Client
+	key string
+}
+
+// NewEtcdTransport 创建基于 etcd 的 Transport，每条通知写入 key 的一个新版本，cli 由调用者关闭.
+func NewEtcdTransport(cli *clientv3.Client, key string) Transport {
+	return &etcdTransport{cli: cli, key: key}
+}
+
+// Publish 将通知写入 key.
+func (t *etcdTransport) Publish(ctx context.Context, data []byte) error {
+	_, err := t.cli.Put(ctx, t.key, string(data))
+	return err
+}
+
+// Subscribe 从当前版本之后开始监听 key.
+func (t *etcdTransport) Subscribe(ctx context.Context, handler func(data []byte)) error {
+	resp, err := t.cli.Get(ctx, t.key)
+	if err != nil {
+		return err
+	}
+
+	wch := t.cli.Watch(ctx, t.key, clientv3.WithRev(resp.Header.Revision+1))
+	go func() {
+		for wresp := range wch {
+			for _, ev := range wresp.Events {
+				if ev.Type == clientv3.EventTypePut {
+					handler(ev.Kv.Value)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Close 不做任何事，监听随 Subscribe 的 ctx 结束.
+func (t *etcdTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package watcher
+
+import (
+	"encoding/json"
+
+	"github.com/onexstack/onexstack/pkg/log"
+)
+
+// Enforcer 是增量更新策略需要的方法，由 casbin.SyncedEnforcer 实现.
+type Enforcer interface {
+	LoadPolicy() error
+	SelfAddPolicies(sec string, ptype string, rules [][]string) (bool, error)
+	SelfRemovePolicies(sec string, ptype string, rules [][]string) (bool, error)
+	SelfRemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) (bool, error)
+	SelfUpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) (bool, error)
+}
+
+// Incremental 返回按通知增量更新 e 中策略的回调函数，用于 Watcher.SetUpdateCallback.
+// 无法增量更新的通知会重新加载全部策略.
+func Incremental(e Enforcer) func(string) {
+	return func(data string) {
+		if err := apply(e, data); err != nil {
+			log.Warnw("Failed to apply policy notification, reload all policies", "err", err)
+			if err := e.LoadPolicy(); err != nil {
+				log.Errorw(err, "Failed to reload policies")
+			}
+		}
+	}
+}
+
+// apply 将通知应用到 e.
+func apply(e Enforcer, data string) error {
+	var msg Message
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return err
+	}
+
+	var err error
+	switch msg.Method {
+	case MethodAddPolicy, MethodAddPolicies:
+		_, err = e.SelfAddPolicies(msg.Sec, msg.Ptype, msg.Rules)
+	case MethodRemovePolicy, MethodRemovePolicies:
+		_, err = e.SelfRemovePolicies(msg.Sec, msg.Ptype, msg.Rules)
+	case MethodRemoveFilteredPolicy:
+		_, err = e.SelfRemoveFilteredPolicy(msg.Sec, msg.Ptype, msg.FieldIndex, msg.FieldValues...)
+	case MethodUpdatePolicy, MethodUpdatePolicies:
+		_, err = e.SelfUpdatePolicies(msg.Sec, msg.Ptype, msg.Rules, msg.NewRules)
+	default:
+		err = e.LoadPolicy()
+	}
+	return err
+}
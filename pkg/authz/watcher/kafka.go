@@ -0,0 +1,52 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/onexstack/onexstack/pkg/log"
+)
+
+// kafkaTransport 通过 Kafka 主题广播通知.
+type kafkaTransport struct {
+	writer *kafka.Writer
+	reader *kafka.Reader
+}
+
+// NewKafkaTransport 创建基于 Kafka 的 Transport. 每个副本都需要收到全部通知，
+// 因此 reader 不能与其它副本共用消费者组，可以使用各副本独立的 GroupID 或直接指定分区.
+// writer 和 reader 在 Close 时关闭.
+func NewKafkaTransport(writer *kafka.Writer, reader *kafka.Reader) Transport {
+	return &kafkaTransport{writer: writer, reader: reader}
+}
+
+// Publish 写入一条通知.
+func (t *kafkaTransport) Publish(ctx context.Context, data []byte) error {
+	return t.writer.WriteMessages(ctx, kafka.Message{Value: data})
+}
+
+// Subscribe 在后台读取通知，直到 ctx 结束.
+func (t *kafkaTransport) Subscribe(ctx context.Context, handler func(data []byte)) error {
+	go func() {
+		for {
+			msg, err := t.reader.ReadMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil || errors.Is(err, io.EOF) {
+					return
+				}
+				log.Warnw("Failed to read policy notification", "err", err)
+				continue
+			}
+			handler(msg.Value)
+		}
+	}()
+	return nil
+}
+
+// Close 关闭 writer 和 reader.
+func (t *kafkaTransport) Close() error {
+	return errors.Join(t.writer.Close(), t.reader.Close())
+}
@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTransport 通过 Redis 发布订阅广播通知.
+type redisTransport struct {
+	cli     redis.UniversalClient
+	channel string
+	pubsub  *redis.PubSub
+}
+
+// NewRedisTransport 创建基于 Redis 发布订阅的 Transport，cli 由调用者关闭.
+func NewRedisTransport(cli redis.UniversalClient, channel string) Transport {
+	return &redisTransport{cli: cli, channel: channel}
+}
+
+// Publish 发布一条通知.
+func (t *redisTransport) Publish(ctx context.Context, data []byte) error {
+	return t.cli.Publish(ctx, t.channel, data).Err()
+}
+
+// Subscribe 订阅频道.
+func (t *redisTransport) Subscribe(ctx context.Context, handler func(data []byte)) error {
+	t.pubsub = t.cli.Subscribe(ctx, t.channel)
+	if _, err := t.pubsub.Receive(ctx); err != nil {
+		_ = t.pubsub.Close()
+		return err
+	}
+
+	go func() {
+		for msg := range t.pubsub.Channel() {
+			handler([]byte(msg.Payload))
+		}
+	}()
+	return nil
+}
+
+// Close 取消订阅.
+func (t *redisTransport) Close() error {
+	if t.pubsub == nil {
+		return nil
+	}
+	return t.pubsub.Close()
+}
@@ -0,0 +1,201 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/google/uuid"
+
+	"github.com/onexstack/onexstack/pkg/log"
+)
+
+// 变更通知的类型，与 persist.WatcherEx 的方法对应.
+const (
+	MethodUpdate               = "Update"
+	MethodAddPolicy            = "UpdateForAddPolicy"
+	MethodRemovePolicy         = "UpdateForRemovePolicy"
+	MethodRemoveFilteredPolicy = "UpdateForRemoveFilteredPolicy"
+	MethodSavePolicy           = "UpdateForSavePolicy"
+	MethodAddPolicies          = "UpdateForAddPolicies"
+	MethodRemovePolicies       = "UpdateForRemovePolicies"
+	MethodUpdatePolicy         = "UpdateForUpdatePolicy"
+	MethodUpdatePolicies       = "UpdateForUpdatePolicies"
+)
+
+// Message 是在副本之间传递的策略变更通知.
+type Message struct {
+	// ID 是发送通知的 Watcher 实例，Watcher 忽略自己发送的通知.
+	ID          string     `json:"id"`
+	Method      string     `json:"method"`
+	Sec         string     `json:"sec,omitempty"`
+	Ptype       string     `json:"ptype,omitempty"`
+	Rules       [][]string `json:"rules,omitempty"`
+	NewRules    [][]string `json:"newRules,omitempty"`
+	FieldIndex  int        `json:"fieldIndex,omitempty"`
+	FieldValues []string   `json:"fieldValues,omitempty"`
+}
+
+// Transport 负责在副本之间广播通知.
+type Transport interface {
+	// Publish 广播一条通知.
+	Publish(ctx context.Context, data []byte) error
+	// Subscribe 在订阅建立后返回，之后收到的通知交给 handler 处理，直到 ctx 结束.
+	Subscribe(ctx context.Context, handler func(data []byte)) error
+	// Close 释放 Transport 的资源.
+	Close() error
+}
+
+// Watcher 实现了 persist.WatcherEx 和 persist.UpdatableWatcher.
+type Watcher struct {
+	transport Transport
+	id        string
+	timeout   time.Duration
+
+	mu       sync.RWMutex
+	callback func(string)
+
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// 确保 Watcher 实现了 Casbin 的 Watcher 接口.
+var (
+	_ persist.WatcherEx        = (*Watcher)(nil)
+	_ persist.UpdatableWatcher = (*Watcher)(nil)
+)
+
+// Option 定义了 Watcher 的函数选项.
+type Option func(*Watcher)
+
+// WithID 设置 Watcher 实例的 ID，默认为随机的 UUID.
+func WithID(id string) Option {
+	return func(w *Watcher) {
+		w.id = id
+	}
+}
+
+// WithTimeout 设置发送通知的超时时间，默认为 5 秒.
+func WithTimeout(timeout time.Duration) Option {
+	return func(w *Watcher) {
+		w.timeout = timeout
+	}
+}
+
+// New 创建 Watcher 并订阅通知.
+func New(transport Transport, opts ...Option) (*Watcher, error) {
+	w := &Watcher{transport: transport, id: uuid.New().String(), timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	if err := transport.Subscribe(ctx, w.receive); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// ID 返回 Watcher 实例的 ID.
+func (w *Watcher) ID() string {
+	return w.id
+}
+
+// SetUpdateCallback 设置收到其它副本的通知后调用的函数，参数为 JSON 编码的 Message.
+func (w *Watcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update 通知其它副本重新加载全部策略.
+func (w *Watcher) Update() error {
+	return w.publish(&Message{Method: MethodUpdate})
+}
+
+// Close 取消订阅并关闭 Transport.
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() {
+		w.cancel()
+		_ = w.transport.Close()
+	})
+}
+
+// UpdateForAddPolicy 通知其它副本添加了一条策略.
+func (w *Watcher) UpdateForAddPolicy(sec, ptype string, params ...string) error {
+	return w.publish(&Message{Method: MethodAddPolicy, Sec: sec, Ptype: ptype, Rules: [][]string{params}})
+}
+
+// UpdateForRemovePolicy 通知其它副本删除了一条策略.
+func (w *Watcher) UpdateForRemovePolicy(sec, ptype string, params ...string) error {
+	return w.publish(&Message{Method: MethodRemovePolicy, Sec: sec, Ptype: ptype, Rules: [][]string{params}})
+}
+
+// UpdateForRemoveFilteredPolicy 通知其它副本按条件删除了策略.
+func (w *Watcher) UpdateForRemoveFilteredPolicy(sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	return w.publish(&Message{Method: MethodRemoveFilteredPolicy, Sec: sec, Ptype: ptype, FieldIndex: fieldIndex, FieldValues: fieldValues})
+}
+
+// UpdateForSavePolicy 通知其它副本重新加载全部策略.
+func (w *Watcher) UpdateForSavePolicy(model model.Model) error {
+	return w.publish(&Message{Method: MethodSavePolicy})
+}
+
+// UpdateForAddPolicies 通知其它副本添加了多条策略.
+func (w *Watcher) UpdateForAddPolicies(sec string, ptype string, rules ...[]string) error {
+	return w.publish(&Message{Method: MethodAddPolicies, Sec: sec, Ptype: ptype, Rules: rules})
+}
+
+// UpdateForRemovePolicies 通知其它副本删除了多条策略.
+func (w *Watcher) UpdateForRemovePolicies(sec string, ptype string, rules ...[]string) error {
+	return w.publish(&Message{Method: MethodRemovePolicies, Sec: sec, Ptype: ptype, Rules: rules})
+}
+
+// UpdateForUpdatePolicy 通知其它副本修改了一条策略.
+func (w *Watcher) UpdateForUpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return w.publish(&Message{Method: MethodUpdatePolicy, Sec: sec, Ptype: ptype, Rules: [][]string{oldRule}, NewRules: [][]string{newRule}})
+}
+
+// UpdateForUpdatePolicies 通知其它副本修改了多条策略.
+func (w *Watcher) UpdateForUpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return w.publish(&Message{Method: MethodUpdatePolicies, Sec: sec, Ptype: ptype, Rules: oldRules, NewRules: newRules})
+}
+
+// publish 发送通知.
+func (w *Watcher) publish(msg *Message) error {
+	msg.ID = w.id
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+	return w.transport.Publish(ctx, data)
+}
+
+// receive 处理收到的通知，忽略自己发送的通知.
+func (w *Watcher) receive(data []byte) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Warnw("Ignore malformed policy notification", "err", err)
+		return
+	}
+	if msg.ID == w.id {
+		return
+	}
+
+	w.mu.RLock()
+	callback := w.callback
+	w.mu.RUnlock()
+	if callback != nil {
+		callback(string(data))
+	}
+}
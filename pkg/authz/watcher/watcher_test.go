@@ -0,0 +1,77 @@
+package watcher_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/authz"
+	"github.com/ydcloud-dy/publicPkg/pkg/authz/watcher"
+)
+
+// bus 是内存中的 Transport，模拟 Redis 发布订阅.
+type bus struct {
+	mu       sync.Mutex
+	handlers []func([]byte)
+}
+
+func (b *bus) Publish(ctx context.Context, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, h := range b.handlers {
+		h(data)
+	}
+	return nil
+}
+
+func (b *bus) Subscribe(ctx context.Context, handler func([]byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *bus) Close() error { return nil }
+
+// newReplica 创建一个使用独立数据库的副本，只能通过通知得知其它副本的策略变更.
+func newReplica(t *testing.T, b *bus) *authz.Authz {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	w, err := watcher.New(b)
+	require.NoError(t, err)
+	t.Cleanup(w.Close)
+
+	a, err := authz.NewAuthz(db, authz.WithWatcher(w), authz.WithAutoLoadPolicyTime(0))
+	require.NoError(t, err)
+	return a
+}
+
+func TestIncrementalSync(t *testing.T) {
+	b := &bus{}
+	a1, a2 := newReplica(t, b), newReplica(t, b)
+
+	deny := func(a *authz.Authz) bool {
+		allowed, err := a.Authorize("alice", "/v1/users", "GET")
+		require.NoError(t, err)
+		return !allowed
+	}
+
+	_, err := a1.AddPolicy("admin", "/v1/users", "GET", "deny")
+	require.NoError(t, err)
+	_, err = a1.AddGroupingPolicy("alice", "admin")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return deny(a2) }, time.Second, 10*time.Millisecond)
+
+	_, err = a1.DeleteRoleForUser("alice", "admin")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return !deny(a2) }, time.Second, 10*time.Millisecond)
+	assert.False(t, deny(a1))
+}
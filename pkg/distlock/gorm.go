@@ -2,7 +2,9 @@ package distlock
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -81,7 +83,7 @@ func (l *GORMLocker) Lock(ctx context.Context) error {
 				return err
 			}
 
-			if !lock.ExpiredAt.Before(now) {
+			if !lock.ExpiredAt.Before(now) && lock.OwnerID != l.ownerID {
 				l.logger.Warn("lock is already held by another owner", "ownerID", lock.OwnerID)
 				return fmt.Errorf("lock is already held by %s", lock.OwnerID)
 			}
@@ -95,8 +97,11 @@ func (l *GORMLocker) Lock(ctx context.Context) error {
 			l.logger.Info("Lock expired, updated owner", "lockName", l.lockName, "newOwnerID", l.ownerID)
 		}
 
-		l.renewTicker = time.NewTicker(l.lockTimeout / 2)
-		go l.renewLock(ctx)
+		// The lock may be acquired again by its owner, only one renewal goroutine is needed.
+		if l.renewTicker == nil {
+			l.renewTicker = time.NewTicker(l.lockTimeout / 2)
+			go l.renewLock(ctx, l.renewTicker, l.stopChan)
+		}
 
 		l.logger.Info("Lock acquired", "lockName", l.lockName, "ownerID", l.ownerID)
 		return nil
@@ -113,10 +118,13 @@ func (l *GORMLocker) Unlock(ctx context.Context) error {
 	if l.renewTicker != nil {
 		l.renewTicker.Stop()
 		l.renewTicker = nil
+		close(l.stopChan)
+		l.stopChan = make(chan struct{})
 		l.logger.Info("Stopped renewing lock", "lockName", l.lockName)
 	}
 
-	err := l.db.Delete(&Lock{}, "name = ?", l.lockName).Error
+	// Only release the lock held by us, it may have expired and been taken over.
+	err := l.db.Delete(&Lock{}, "name = ? AND owner_id = ?", l.lockName, l.ownerID).Error
 	if err != nil {
 		l.logger.Error("failed to delete lock", "error", err)
 		return err
@@ -134,23 +142,27 @@ func (l *GORMLocker) Renew(ctx context.Context) error {
 	now := time.Now()
 	expiredAt := now.Add(l.lockTimeout)
 
-	err := l.db.Model(&Lock{}).Where("name = ?", l.lockName).Update("expired_at", expiredAt).Error
-	if err != nil {
+	result := l.db.Model(&Lock{}).Where("name = ? AND owner_id = ?", l.lockName, l.ownerID).Update("expired_at", expiredAt)
+	if err := result.Error; err != nil {
 		l.logger.Error("failed to renew lock", "error", err)
 		return err
 	}
+	if result.RowsAffected == 0 {
+		// The lock expired and has been taken over by another owner.
+		return fmt.Errorf("lock %s is not held by %s", l.lockName, l.ownerID)
+	}
 
 	l.logger.Info("Lock renewed", "lockName", l.lockName, "newExpiration", expiredAt)
 	return nil
 }
 
 // renewLock periodically renews the lock lease.
-func (l *GORMLocker) renewLock(ctx context.Context) {
+func (l *GORMLocker) renewLock(ctx context.Context, ticker *time.Ticker, stopChan <-chan struct{}) {
 	for {
 		select {
-		case <-l.stopChan:
+		case <-stopChan:
 			return
-		case <-l.renewTicker.C:
+		case <-ticker.C:
 			if err := l.Renew(ctx); err != nil {
 				l.logger.Error("failed to renew lock", "error", err)
 			}
@@ -158,12 +170,16 @@ func (l *GORMLocker) renewLock(ctx context.Context) {
 	}
 }
 
-// isDuplicateEntry checks if the error is a duplicate entry error for MySQL and PostgreSQL.
+// isDuplicateEntry checks if the error is a duplicate entry error for MySQL, PostgreSQL and SQLite.
 func isDuplicateEntry(err error) bool {
 	if err == nil {
 		return false
 	}
 
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+
 	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 		return mysqlErr.Number == 1062 // MySQL error code for duplicate entry
 	}
@@ -172,5 +188,5 @@ func isDuplicateEntry(err error) bool {
 		return pgErr.Code == "23505" // PostgreSQL error code for unique violation
 	}
 
-	return false
+	return strings.Contains(err.Error(), "UNIQUE constraint failed") // SQLite
 }
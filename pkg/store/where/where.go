@@ -239,6 +239,15 @@ func F(kvs ...any) *Options {
 	return NewWhere().F(kvs...)
 }
 
+// TenantValue returns the value of the registered tenant for the context,
+// or an empty string if no tenant is registered.
+func TenantValue(ctx context.Context) string {
+	if registeredTenant.ValueFunc == nil {
+		return ""
+	}
+	return registeredTenant.ValueFunc(ctx)
+}
+
 // RegisterTenant registers a new tenant with the specified key and value function.
 func RegisterTenant(key string, valueFunc func(context.Context) string) {
 	registeredTenant = Tenant{
@@ -14,76 +14,132 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+
+	"github.com/onexstack/onexstack/pkg/log"
+
+	jwtauth "github.com/ydcloud-dy/publicPkg/pkg/authn/jwt"
+)
+
+const (
+	// defaultKey 是未指定密钥时用于签发和解析 token 的密钥.
+	// 该密钥是公开的，生产环境必须通过 Config.Key 或 Init 指定密钥.
+	defaultKey = "Rtg8BPKNEf2mB4mgvKONGPZZQSaJWNLijxR42qRgq0iBb5"
+	// defaultIdentityKey 是未指定时 token 中用户身份的键.
+	defaultIdentityKey = "identityKey"
+	// defaultExpiration 是未指定时签发的 token 过期时间.
+	defaultExpiration = 2 * time.Hour
 )
 
 // Config 包括 token 包的配置选项.
 type Config struct {
-	// key 用于签发和解析 token 的密钥.
-	key string
-	// identityKey 是 token 中用户身份的键.
+	// Key 用于签发和解析 token 的密钥，为空时使用公开的默认密钥并打印告警.
+	Key string
+	// IdentityKey 是 token 中用户身份的键，用户身份同时保存在 sub 声明中.
+	// 解析没有 sub 声明的旧格式 token 时，从该键读取用户身份.
+	IdentityKey string
+	// Expiration 是签发的 token 过期时间.
+	Expiration time.Duration
+	// Store 是可选的 token 吊销存储.
+	Store jwtauth.Storer
+	// Options 是透传给 jwt.JWTAuth 的其他签发选项.
+	Options []jwtauth.Option
+}
+
+// Token 是基于 jwt.JWTAuth 的 token 签发和解析器.
+type Token struct {
+	auth        *jwtauth.JWTAuth
 	identityKey string
-	// expiration 是签发的 token 过期时间
-	expiration time.Duration
+	defaultKey  bool // 是否使用公开的默认密钥
 }
 
 var (
-	config = Config{"Rtg8BPKNEf2mB4mgvKONGPZZQSaJWNLijxR42qRgq0iBb5", "identityKey", 2 * time.Hour}
-	once   sync.Once // 确保配置只被初始化一次
+	std   = New(Config{}) // 包级别函数使用的默认实例
+	stdMu sync.RWMutex    // 保护默认实例的替换
+
+	defaultKeyOnce sync.Once // 确保默认密钥告警只打印一次
 )
 
-// Init 设置包级别的配置 config, config 会用于本包后面的 token 签发和解析.
-func Init(key string, identityKey string, expiration time.Duration) {
-	once.Do(func() {
-		if key != "" {
-			config.key = key // 设置密钥
-		}
-		if identityKey != "" {
-			config.identityKey = identityKey // 设置身份键
-		}
-		if expiration != 0 {
-			config.expiration = expiration
-		}
+// New 根据配置创建一个 Token 实例，未设置的配置项使用默认值.
+func New(cfg Config) *Token {
+	useDefaultKey := cfg.Key == ""
+	if useDefaultKey {
+		cfg.Key = defaultKey
+	}
+	if cfg.IdentityKey == "" {
+		cfg.IdentityKey = defaultIdentityKey
+	}
+	if cfg.Expiration == 0 {
+		cfg.Expiration = defaultExpiration
+	}
+
+	opts := append([]jwtauth.Option{
+		jwtauth.WithSigningKey([]byte(cfg.Key)),
+		jwtauth.WithExpired(cfg.Expiration),
+		// 旧版本从顶层声明读取用户身份，滚动升级期间仍需写入该声明.
+		jwtauth.WithIdentityClaim(cfg.IdentityKey),
+	}, cfg.Options...)
+
+	return &Token{auth: jwtauth.New(cfg.Store, opts...), identityKey: cfg.IdentityKey, defaultKey: useDefaultKey}
+}
+
+// warnDefaultKey 在使用公开的默认密钥签发或解析 token 时打印一次告警.
+func (t *Token) warnDefaultKey() {
+	if !t.defaultKey {
+		return
+	}
+	defaultKeyOnce.Do(func() {
+		log.Warnw("Token is signed with the built-in default key, set a key with token.Config.Key or token.Init")
 	})
 }
 
-// Parse 使用指定的密钥 key 解析 token，解析成功返回 token 上下文，否则报错.
-func Parse(tokenString string, key string) (string, error) {
-	// 解析 token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// 确保 token 加密算法是预期的加密算法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
+// Authenticator 返回底层的 jwt.JWTAuth，用于刷新 token、吊销 token 等高级功能.
+func (t *Token) Authenticator() *jwtauth.JWTAuth {
+	return t.auth
+}
 
-		return []byte(key), nil // 返回密钥
-	})
-	// 解析失败
+// Sign 签发 token，用户身份同时存放在 sub 声明和 IdentityKey 对应的顶层声明中.
+func (t *Token) Sign(ctx context.Context, identity string) (string, time.Time, error) {
+	t.warnDefaultKey()
+
+	token, err := t.auth.Sign(ctx, identity)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token.GetToken(), time.Unix(token.GetExpiresAt(), 0), nil
+}
+
+// Parse 解析 token，解析成功返回 token 中的用户身份，否则报错.
+// 用户身份优先从 sub 声明读取，没有 sub 声明时从 IdentityKey 对应的声明读取.
+func (t *Token) Parse(ctx context.Context, tokenString string) (string, error) {
+	t.warnDefaultKey()
+
+	claims, err := t.auth.ParseClaims(ctx, tokenString)
 	if err != nil {
 		return "", err
 	}
 
-	var identityKey string
-	// 如果解析成功，从 token 中取出 token 的主题
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if key, exists := claims[config.identityKey]; exists {
-			if identity, valid := key.(string); valid {
-				identityKey = identity // 获取身份键
-			}
-		}
+	if claims.Subject != "" {
+		return claims.Subject, nil
 	}
-	if identityKey == "" {
-		return "", jwt.ErrSignatureInvalid
+
+	// 旧格式的 token 将用户身份存放在顶层声明中，签名已经在上面校验过.
+	var mapClaims jwt.MapClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &mapClaims); err == nil {
+		if identity, ok := mapClaims[t.identityKey].(string); ok && identity != "" {
+			return identity, nil
+		}
 	}
 
-	return identityKey, nil
+	return "", jwtauth.ErrTokenInvalid
 }
 
-// ParseRequest 从请求头中获取令牌，并将其传递给 Parse 函数以解析令牌.
-func ParseRequest(ctx context.Context) (string, error) {
+// ParseRequest 从请求头中获取令牌，并将其传递给 Parse 方法以解析令牌.
+func (t *Token) ParseRequest(ctx context.Context) (string, error) {
 	var (
 		token string
 		err   error
@@ -100,6 +156,7 @@ func ParseRequest(ctx context.Context) (string, error) {
 
 		// 从请求头中取出 token
 		_, _ = fmt.Sscanf(header, "Bearer %s", &token) // 解析 Bearer token
+		ctx = typed.Request.Context()
 	// 使用 google.golang.org/grpc 框架开发的 gRPC 服务
 	default:
 		token, err = auth.AuthFromMD(typed, "Bearer")
@@ -108,30 +165,42 @@ func ParseRequest(ctx context.Context) (string, error) {
 		}
 	}
 
-	return Parse(token, config.key) // 解析 token
+	return t.Parse(ctx, token) // 解析 token
 }
 
-// Sign 使用 jwtSecret 签发 token，token 的 claims 中会存放传入的 subject.
-func Sign(identityKey string) (string, time.Time, error) {
-	// 计算过期时间
-	expireAt := time.Now().Add(config.expiration)
-
-	// Token 的内容
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		config.identityKey: identityKey,       // 存放用户身份
-		"nbf":              time.Now().Unix(), // token 生效时间
-		"iat":              time.Now().Unix(), // token 签发时间
-		"exp":              expireAt.Unix(),   // token 过期时间
-	})
-	if config.key == "" {
-		return "", time.Time{}, jwt.ErrInvalidKey
-	}
+// Destroy 吊销 token，需要在配置中设置 Store.
+func (t *Token) Destroy(ctx context.Context, tokenString string) error {
+	return t.auth.Destroy(ctx, tokenString)
+}
 
-	// 签发 token
-	tokenString, err := token.SignedString([]byte(config.key))
-	if err != nil {
-		return "", time.Time{}, err
-	}
+// Default 返回包级别函数使用的默认实例.
+func Default() *Token {
+	stdMu.RLock()
+	defer stdMu.RUnlock()
+	return std
+}
+
+// Init 使用给定配置替换包级别函数使用的默认实例，空值使用默认配置.
+// 保留该函数是为了兼容，新代码应使用 New 创建独立的实例.
+func Init(key string, identityKey string, expiration time.Duration) {
+	t := New(Config{Key: key, IdentityKey: identityKey, Expiration: expiration})
 
-	return tokenString, expireAt, nil // 返回 token 字符串、过期时间和错误
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	std = t
+}
+
+// Parse 使用指定的密钥 key 解析 token，解析成功返回用户身份，否则报错.
+func Parse(tokenString string, key string) (string, error) {
+	return New(Config{Key: key, IdentityKey: Default().identityKey}).Parse(context.Background(), tokenString)
+}
+
+// ParseRequest 使用默认实例从请求头中获取并解析令牌.
+func ParseRequest(ctx context.Context) (string, error) {
+	return Default().ParseRequest(ctx)
+}
+
+// Sign 使用默认实例签发 token，token 的 claims 中会存放传入的用户身份.
+func Sign(identityKey string) (string, time.Time, error) {
+	return Default().Sign(context.Background(), identityKey)
 }
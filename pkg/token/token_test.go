@@ -14,30 +14,104 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/metadata"
 )
 
 // TestInit 测试 Init 函数
 func TestInit(t *testing.T) {
+	t.Cleanup(func() { Init("", "", 0) })
+
 	// 测试默认配置
-	assert.Equal(t, "Rtg8BPKNEf2mB4mgvKONGPZZQSaJWNLijxR42qRgq0iBb5", config.key)
-	assert.Equal(t, "identityKey", config.identityKey)
-	assert.Equal(t, 2*time.Hour, config.expiration)
+	tokenString, expireAt, err := Sign("testUser")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), expireAt, time.Minute)
+	_, err = Parse(tokenString, defaultKey)
+	assert.NoError(t, err)
 
 	// 测试自定义配置
 	Init("newKey", "newIdentityKey", 3*time.Hour)
 
-	assert.Equal(t, "newKey", config.key)
-	assert.Equal(t, "newIdentityKey", config.identityKey)
-	assert.Equal(t, 3*time.Hour, config.expiration)
+	tokenString, expireAt, err = Sign("testUser")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(3*time.Hour), expireAt, time.Minute)
+	_, err = Parse(tokenString, "newKey")
+	assert.NoError(t, err)
 
-	// 再次调用 Init，确保配置不会被覆盖
+	// 再次调用 Init，配置会被替换
 	Init("anotherKey", "anotherIdentityKey", 1*time.Hour)
 
-	assert.Equal(t, "newKey", config.key)                 // 仍然是 "newKey"
-	assert.Equal(t, "newIdentityKey", config.identityKey) // 仍然是 "newIdentityKey"
-	assert.Equal(t, 3*time.Hour, config.expiration)       // 仍然是 3小时
+	tokenString, _, err = Sign("testUser")
+	assert.NoError(t, err)
+	_, err = Parse(tokenString, "newKey")
+	assert.Error(t, err)
+	_, err = Parse(tokenString, "anotherKey")
+	assert.NoError(t, err)
+}
+
+// TestNew 测试不同实例之间互相隔离
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	a := New(Config{Key: "keyA"})
+	b := New(Config{Key: "keyB", Expiration: time.Minute})
+
+	tokenString, expireAt, err := a.Sign(ctx, "testUser")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), expireAt, time.Minute)
+
+	identity, err := a.Parse(ctx, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, "testUser", identity)
+
+	_, err = b.Parse(ctx, tokenString)
+	assert.Error(t, err)
+}
+
+// TestParseLegacyToken 测试解析旧格式的 token，用户身份存放在 IdentityKey 对应的顶层声明中
+func TestParseLegacyToken(t *testing.T) {
+	ctx := context.Background()
+	tk := New(Config{Key: "legacyKey", IdentityKey: "userID"})
+
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userID": "testUser",
+		"nbf":    time.Now().Unix(),
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+	tokenString, err := legacy.SignedString([]byte("legacyKey"))
+	assert.NoError(t, err)
+
+	identity, err := tk.Parse(ctx, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, "testUser", identity)
+
+	// 用户身份的键与配置不一致时解析失败
+	_, err = New(Config{Key: "legacyKey"}).Parse(ctx, tokenString)
+	assert.Error(t, err)
+
+	// 签名错误的旧格式 token 解析失败
+	_, err = New(Config{Key: "otherKey", IdentityKey: "userID"}).Parse(ctx, tokenString)
+	assert.Error(t, err)
+
+	// 包级别的 Parse 使用 Init 设置的用户身份的键
+	Init("legacyKey", "userID", time.Hour)
+	defer Init("", "", 0)
+	identity, err = Parse(tokenString, "legacyKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "testUser", identity)
+}
+
+// TestSignLegacyClaim 测试签发的 token 仍在顶层声明中存放用户身份，旧版本可以解析
+func TestSignLegacyClaim(t *testing.T) {
+	tokenString, _, err := New(Config{Key: "legacyKey", IdentityKey: "userID"}).Sign(context.Background(), "testUser")
+	assert.NoError(t, err)
+
+	var claims jwt.MapClaims
+	_, err = jwt.ParseWithClaims(tokenString, &claims, func(*jwt.Token) (any, error) { return []byte("legacyKey"), nil })
+	assert.NoError(t, err)
+	assert.Equal(t, "testUser", claims["userID"])
+	assert.Equal(t, "testUser", claims["sub"])
 }
 
 // TestSign 测试 Sign 函数
@@ -49,7 +123,7 @@ func TestSign(t *testing.T) {
 	assert.NotEmpty(t, tokenString)
 
 	// 解析 token
-	parsedIdentityKey, err := Parse(tokenString, config.key)
+	parsedIdentityKey, err := Parse(tokenString, defaultKey)
 	assert.NoError(t, err)
 	assert.Equal(t, identityKey, parsedIdentityKey)
 }
@@ -57,7 +131,7 @@ func TestSign(t *testing.T) {
 // TestParseInvalidToken 测试解析无效的 token
 func TestParseInvalidToken(t *testing.T) {
 	invalidToken := "invalid.token.string"
-	identityKey, err := Parse(invalidToken, config.key)
+	identityKey, err := Parse(invalidToken, defaultKey)
 
 	assert.Error(t, err)
 	assert.Empty(t, identityKey)
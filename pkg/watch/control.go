@@ -0,0 +1,394 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/robfig/cron/v3"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/manager"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/trigger"
+)
+
+// stateSyncInterval is the interval to load the states of watchers changed on other replicas.
+var stateSyncInterval = 10 * time.Second
+
+var (
+	// ErrInvalidSpec is returned when a watcher is rescheduled with an invalid cron spec.
+	ErrInvalidSpec = errors.New("invalid cron spec")
+	// ErrEventDriven is returned when a watcher run by the events of a trigger is rescheduled.
+	ErrEventDriven = errors.New("watcher is run by the events of a trigger")
+	// ErrNotLeader is returned when a watcher is triggered on a replica which
+	// does not hold the lock in leader mode.
+	ErrNotLeader = errors.New("replica is not the leader")
+)
+
+// job is a watcher added to the cron, it can be paused, triggered and rescheduled at runtime.
+type job struct {
+	name string
+	// defaultSpec is the spec of the watcher, used when the spec is not overridden.
+	defaultSpec string
+	// spec is the current spec, protected by Watch.mu.
+	spec string
+	// next runs the watcher, it records the run and takes the lock in per-watcher mode.
+	next cron.Job
+	// locked is the lock of the watcher in per-watcher mode.
+	locked *lockedJob
+	// source is the trigger of an event-driven watcher, which is not added to the cron.
+	source   trigger.Trigger
+	debounce registry.DebouncePolicy
+	// events are the events of the current run, protected by running.
+	events []trigger.Event
+	// scheduled returns the scheduled time of the current run of the watcher.
+	scheduled func() time.Time
+	metrics   *metrics
+	logger    Logger
+
+	// running is held while the watcher is running, it delays the next run
+	// until the running one is completed, the same as cron.DelayIfStillRunning.
+	running sync.Mutex
+	paused  atomic.Bool
+	// triggered is the number of runs triggered manually, they also run when the watcher is paused.
+	triggered atomic.Int64
+}
+
+// Run implements the cron.Job interface.
+func (j *job) Run() {
+	for {
+		n := j.triggered.Load()
+		if n == 0 {
+			break
+		}
+		if j.triggered.CompareAndSwap(n, n-1) {
+			j.run(time.Time{}, nil)
+			return
+		}
+	}
+
+	if j.paused.Load() {
+		j.metrics.skip(j.name, skipPaused)
+		return
+	}
+
+	var scheduled time.Time
+	if j.scheduled != nil {
+		scheduled = j.scheduled()
+	}
+	j.run(scheduled, nil)
+}
+
+// fire runs an event-driven watcher with a batch of events.
+func (j *job) fire(events []trigger.Event) {
+	defer j.recover()
+
+	if j.paused.Load() {
+		j.metrics.skip(j.name, skipPaused)
+		return
+	}
+
+	var first time.Time
+	if len(events) > 0 {
+		first = events[0].Time
+	}
+	j.run(first, events)
+}
+
+// run runs the watcher once the running one is completed. The lag is recorded
+// for scheduled runs and events, manually triggered runs have a zero scheduled time.
+func (j *job) run(scheduled time.Time, events []trigger.Event) {
+	start := time.Now()
+	if !j.running.TryLock() {
+		j.metrics.skip(j.name, skipStillRunning)
+		j.running.Lock()
+	}
+	defer j.running.Unlock()
+
+	j.events = events
+	defer func() { j.events = nil }()
+
+	if delay := time.Since(start); delay > time.Minute {
+		j.logger.Info("delay", "duration", delay)
+	}
+	if !scheduled.IsZero() {
+		j.metrics.observeLag(j.name, time.Since(scheduled))
+	}
+
+	j.next.Run()
+}
+
+// recover recovers the panics of the runs which are not run by the cron, the
+// same as cron.Recover.
+func (j *job) recover() {
+	if r := recover(); r != nil {
+		err, ok := r.(error)
+		if !ok {
+			err = fmt.Errorf("%v", r)
+		}
+		j.logger.Error(err, "panic", "stack", "...\n"+string(debug.Stack()))
+	}
+}
+
+// lookup returns the watcher with the given name.
+func (w *Watch) lookup(name string) (*job, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	j, ok := w.jobs[name]
+	if !ok {
+		return nil, &manager.JobNotFoundError{JobName: name}
+	}
+	return j, nil
+}
+
+// currentState returns the state of the watcher to be persisted.
+func (w *Watch) currentState(j *job) *state.JobState {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	s := &state.JobState{Job: j.name, Paused: j.paused.Load()}
+	if j.spec != j.defaultSpec {
+		s.Spec = j.spec
+	}
+	return s
+}
+
+// Pause stops the scheduled runs of a watcher on all replicas until it is resumed.
+func (w *Watch) Pause(ctx context.Context, name string) error {
+	j, err := w.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+
+	s := w.currentState(j)
+	s.Paused = true
+	if err := w.states.Save(ctx, s); err != nil {
+		return err
+	}
+
+	j.paused.Store(true)
+	w.logger.Info("Paused watcher", "watcher", name)
+	return nil
+}
+
+// Resume resumes the scheduled runs of a paused watcher.
+func (w *Watch) Resume(ctx context.Context, name string) error {
+	j, err := w.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+
+	s := w.currentState(j)
+	s.Paused = false
+	if err := w.states.Save(ctx, s); err != nil {
+		return err
+	}
+
+	j.paused.Store(false)
+	w.logger.Info("Resumed watcher", "watcher", name)
+	return nil
+}
+
+// Trigger runs a watcher immediately in the background, even when it is paused.
+// The run still waits for the running one and takes the lock in per-watcher mode.
+// In leader mode only the leader runs watchers, ErrNotLeader is returned on the
+// other replicas.
+func (w *Watch) Trigger(ctx context.Context, name string) error {
+	j, err := w.lookup(name)
+	if err != nil {
+		return err
+	}
+	if w.mode == ModeLeader && !w.leader.Load() {
+		return ErrNotLeader
+	}
+
+	if j.source != nil {
+		j.triggered.Add(1)
+		go func() {
+			defer j.recover()
+			j.Run()
+		}()
+		w.logger.Info("Triggered watcher", "watcher", name)
+		return nil
+	}
+
+	entry, ok := w.jm.GetEntry(name)
+	if !ok {
+		return &manager.JobNotFoundError{JobName: name}
+	}
+
+	j.triggered.Add(1)
+	go entry.WrappedJob.Run()
+
+	w.logger.Info("Triggered watcher", "watcher", name)
+	return nil
+}
+
+// Reschedule changes the cron spec of a watcher on all replicas. An empty spec
+// restores the spec of the watcher.
+func (w *Watch) Reschedule(ctx context.Context, name string, spec string) error {
+	j, err := w.lookup(name)
+	if err != nil {
+		return err
+	}
+	if j.source != nil {
+		return fmt.Errorf("%w: %s", ErrEventDriven, j.source)
+	}
+
+	if spec != "" {
+		if _, err := specParser.Parse(spec); err != nil {
+			return fmt.Errorf("%w %q: %w", ErrInvalidSpec, spec, err)
+		}
+	}
+
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+
+	s := w.currentState(j)
+	s.Spec = spec
+	if spec == j.defaultSpec {
+		s.Spec = ""
+	}
+	if err := w.states.Save(ctx, s); err != nil {
+		return err
+	}
+
+	return w.applyState(s)
+}
+
+// syncStates loads the states of watchers and applies them.
+func (w *Watch) syncStates(ctx context.Context) error {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+
+	states, err := w.states.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range states {
+		if err := w.applyState(s); err != nil {
+			w.logger.Error(err, "Failed to apply the state of watcher", "watcher", s.Job)
+		}
+	}
+	return nil
+}
+
+// watchStates periodically loads the states changed on other replicas until ctx is done.
+func (w *Watch) watchStates(ctx context.Context) {
+	ticker := time.NewTicker(stateSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.syncStates(ctx); err != nil {
+				w.logger.Error(err, "Failed to load the states of watchers")
+			}
+		}
+	}
+}
+
+// applyState pauses or reschedules the watcher according to its state.
+func (w *Watch) applyState(s *state.JobState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	j, ok := w.jobs[s.Job]
+	if !ok {
+		// The watcher is disabled or not registered on this replica.
+		return nil
+	}
+
+	j.paused.Store(s.Paused)
+	if j.source != nil {
+		return nil
+	}
+
+	spec := s.Spec
+	if spec == "" {
+		spec = j.defaultSpec
+	}
+	if spec == j.spec {
+		return nil
+	}
+
+	schedule, err := specParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", ErrInvalidSpec, spec, err)
+	}
+
+	if err := w.jm.UpdateJob(j.name, spec, j); err != nil {
+		return err
+	}
+	if j.locked != nil {
+		j.locked.SetSchedule(schedule)
+	}
+	j.spec = spec
+
+	w.logger.Info("Rescheduled watcher", "watcher", j.name, "spec", spec)
+	return nil
+}
+
+// controlHandler returns a handler which applies the control function to the
+// watcher in the path and responds with its status.
+func (w *Watch) controlHandler(control func(ctx context.Context, name string) error) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if err := control(r.Context(), name); err != nil {
+			writeControlError(rw, err)
+			return
+		}
+		w.writeJobStatus(rw, r, name)
+	}
+}
+
+// scheduleHandler changes the cron spec of a watcher, the request body is
+// `{"spec": "@every 1m"}`.
+func (w *Watch) scheduleHandler(rw http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Spec string `json:"spec"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := w.Reschedule(r.Context(), name, req.Spec); err != nil {
+		writeControlError(rw, err)
+		return
+	}
+	w.writeJobStatus(rw, r, name)
+}
+
+func writeControlError(rw http.ResponseWriter, err error) {
+	var notFound *manager.JobNotFoundError
+	switch {
+	case errors.As(err, &notFound):
+		writeError(rw, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvalidSpec), errors.Is(err, ErrEventDriven):
+		writeError(rw, http.StatusBadRequest, err)
+	case errors.Is(err, ErrNotLeader):
+		writeError(rw, http.StatusServiceUnavailable, err)
+	default:
+		writeError(rw, http.StatusInternalServerError, err)
+	}
+}
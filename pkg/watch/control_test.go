@@ -0,0 +1,171 @@
+package watch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+)
+
+func TestJobControl(t *testing.T) {
+	ctx := context.Background()
+	states := state.NewMemoryStore()
+	w := newTestWatch(states)
+
+	var runs atomic.Int64
+	require.NoError(t, w.addWatcher("count", cron.FuncJob(func() { runs.Add(1) })))
+
+	require.NoError(t, w.Pause(ctx, "count"))
+	w.jobs["count"].Run()
+	assert.Equal(t, int64(0), runs.Load())
+
+	// Only the leader runs watchers in leader mode.
+	assert.ErrorIs(t, w.Trigger(ctx, "count"), ErrNotLeader)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(0), runs.Load())
+
+	// A triggered run ignores the pause.
+	w.leader.Store(true)
+	require.NoError(t, w.Trigger(ctx, "count"))
+	assert.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, w.Resume(ctx, "count"))
+	w.jobs["count"].Run()
+	assert.Equal(t, int64(2), runs.Load())
+
+	require.NoError(t, w.Reschedule(ctx, "count", "@every 1m"))
+	assert.Equal(t, "@every 1m", w.jobs["count"].spec)
+	assert.ErrorIs(t, w.Reschedule(ctx, "count", "every minute"), ErrInvalidSpec)
+	require.NoError(t, w.Pause(ctx, "count"))
+
+	// The states are applied by another replica, e.g. after a leader handover.
+	other := newTestWatch(states)
+	require.NoError(t, other.addWatcher("count", cron.FuncJob(func() {})))
+	require.NoError(t, other.syncStates(ctx))
+	assert.True(t, other.jobs["count"].paused.Load())
+	assert.Equal(t, "@every 1m", other.jobs["count"].spec)
+
+	require.NoError(t, w.Reschedule(ctx, "count", ""))
+	require.NoError(t, other.syncStates(ctx))
+	assert.Equal(t, "@every 3s", other.jobs["count"].spec)
+}
+
+// slowStore widens the window between reading and saving a state.
+type slowStore struct {
+	state.Store
+}
+
+func (s *slowStore) Save(ctx context.Context, js *state.JobState) error {
+	time.Sleep(20 * time.Millisecond)
+	return s.Store.Save(ctx, js)
+}
+
+func TestJobControlConcurrently(t *testing.T) {
+	ctx := context.Background()
+	states := state.NewMemoryStore()
+	w := newTestWatch(&slowStore{Store: states})
+	require.NoError(t, w.addWatcher("count", cron.FuncJob(func() {})))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, w.Pause(ctx, "count"))
+	}()
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, w.Reschedule(ctx, "count", "@every 1m"))
+	}()
+	wg.Wait()
+
+	// Neither change is lost.
+	saved, err := states.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.True(t, saved[0].Paused)
+	assert.Equal(t, "@every 1m", saved[0].Spec)
+	assert.True(t, w.jobs["count"].paused.Load())
+	assert.Equal(t, "@every 1m", w.jobs["count"].spec)
+}
+
+func TestJobControlHandlers(t *testing.T) {
+	w := newTestWatch(state.NewMemoryStore())
+	require.NoError(t, w.addWatcher("noop", cron.FuncJob(func() {})))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/jobs/{name}/pause", w.controlHandler(w.Pause)).Methods(http.MethodPost)
+	r.HandleFunc("/jobs/{name}/trigger", w.controlHandler(w.Trigger)).Methods(http.MethodPost)
+	r.HandleFunc("/jobs/{name}/schedule", w.scheduleHandler).Methods(http.MethodPut)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/noop/pause", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"paused":true`)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/unknown/pause", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/noop/trigger", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/jobs/noop/schedule", strings.NewReader(`{"spec": "bad"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/jobs/noop/schedule", strings.NewReader(`{"spec": "@every 1m"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"spec":"@every 1m"`)
+}
+
+func TestControlRoutes(t *testing.T) {
+	w := newTestWatch(state.NewMemoryStore())
+	require.NoError(t, w.addWatcher("noop", cron.FuncJob(func() {})))
+
+	// The control routes are not served by default.
+	rec := httptest.NewRecorder()
+	w.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/noop/pause", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.False(t, w.jobs["noop"].paused.Load())
+
+	w.controlAuth = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(rw, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+	r := w.router()
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/noop/pause", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.False(t, w.jobs["noop"].paused.Load())
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs/noop/pause", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, w.jobs["noop"].paused.Load())
+
+	// The read-only routes do not need authentication.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,148 @@
+package watch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/distlock"
+)
+
+const (
+	// ModeLeader runs all watchers on the replica holding the global lock.
+	ModeLeader = "leader"
+	// ModePerWatcher runs the cron on every replica, each run of a watcher takes
+	// the lock of that watcher, so watchers spread across replicas.
+	ModePerWatcher = "per-watcher"
+)
+
+// LockerFactory creates the distributed lock of a watcher in per-watcher mode.
+type LockerFactory func(name string) (distlock.Locker, error)
+
+// specParser parses cron specs the same way as the cron scheduler of Watch, see cron.WithSeconds.
+var specParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// lockedJob runs a job only when the lock of the job is acquired.
+//
+// The lock is kept for at least half of the interval to the next scheduled run
+// after the run starts, otherwise a replica whose cron ticks a bit later would
+// run the job again for the same schedule. A replica which dies stops renewing
+// its lock, and another replica takes over the job once the lock expires.
+type lockedJob struct {
+	name     string
+	job      cron.Job
+	schedule cron.Schedule
+	locker   distlock.Locker
+	logger   Logger
+	metrics  *metrics
+
+	mu sync.Mutex
+	// held reports whether the lock is held by this replica.
+	held bool
+	// release is the pending release of the lock.
+	release *time.Timer
+	// generation invalidates pending releases when the lock is acquired again.
+	generation uint64
+}
+
+// newLockedJob wraps the job with the lock created by newLocker. The lock of a
+// job without spec, e.g. an event-driven watcher, is released after each run.
+func newLockedJob(name, spec string, job cron.Job, newLocker LockerFactory, logger Logger) (*lockedJob, error) {
+	var schedule cron.Schedule
+	if spec != "" {
+		var err error
+		if schedule, err = specParser.Parse(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	locker, err := newLocker(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockedJob{name: name, job: job, schedule: schedule, locker: locker, logger: logger}, nil
+}
+
+// Run implements the cron.Job interface.
+func (j *lockedJob) Run() {
+	start := time.Now()
+
+	j.mu.Lock()
+	var hold time.Duration
+	if j.schedule != nil {
+		hold = j.schedule.Next(start).Sub(start) / 2
+	}
+	j.generation++
+	if j.release != nil {
+		j.release.Stop()
+		j.release = nil
+	}
+	err := j.locker.Lock(context.Background())
+	j.held = err == nil
+	j.mu.Unlock()
+
+	if err != nil {
+		j.metrics.skip(j.name, skipLocked)
+		j.logger.Debug("Skip the run, the watcher is running on another replica", "watcher", j.name, "err", err)
+		return
+	}
+
+	j.job.Run()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	remaining := hold - time.Since(start)
+	if remaining <= 0 {
+		j.unlockLocked()
+		return
+	}
+
+	generation := j.generation
+	j.release = time.AfterFunc(remaining, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		if j.generation == generation {
+			j.unlockLocked()
+		}
+	})
+}
+
+// SetSchedule updates the schedule used to compute how long the lock is kept.
+func (j *lockedJob) SetSchedule(schedule cron.Schedule) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.schedule = schedule
+}
+
+// Unlock releases the lock immediately if it is held by this replica.
+func (j *lockedJob) Unlock() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.generation++
+	if j.release != nil {
+		j.release.Stop()
+		j.release = nil
+	}
+	j.unlockLocked()
+}
+
+func (j *lockedJob) unlockLocked() {
+	if !j.held {
+		return
+	}
+
+	j.held = false
+	j.release = nil
+	if err := j.locker.Unlock(context.Background()); err != nil {
+		j.logger.Debug("Failed to release lock", "watcher", j.name, "err", err)
+	}
+}
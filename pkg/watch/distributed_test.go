@@ -0,0 +1,51 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/distlock"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/logger/empty"
+)
+
+func TestLockedJob(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	newLocker := func(owner string) LockerFactory {
+		return func(name string) (distlock.Locker, error) {
+			return distlock.NewGORMLocker(db, distlock.WithLockName(name), distlock.WithOwnerID(owner))
+		}
+	}
+
+	var runs []string
+	var b *lockedJob
+	a, err := newLockedJob("job", "@every 1h", cron.FuncJob(func() {
+		runs = append(runs, "a")
+		// The lock is held by a while it is running.
+		b.Run()
+	}), newLocker("a"), empty.NewLogger())
+	require.NoError(t, err)
+	b, err = newLockedJob("job", "@every 1h", cron.FuncJob(func() {
+		runs = append(runs, "b")
+	}), newLocker("b"), empty.NewLogger())
+	require.NoError(t, err)
+
+	a.Run()
+	assert.Equal(t, []string{"a"}, runs)
+
+	// The lock is kept after the run, so b skips the same schedule.
+	b.Run()
+	assert.Equal(t, []string{"a"}, runs)
+
+	a.Unlock()
+	b.Run()
+	assert.Equal(t, []string{"a", "b"}, runs)
+	b.Unlock()
+}
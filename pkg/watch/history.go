@@ -0,0 +1,200 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
+)
+
+const (
+	// defaultRunsLimit is the default number of runs served on `/jobs/{name}/runs`.
+	defaultRunsLimit = 20
+	// maxRunsLimit is the maximum number of runs served on `/jobs/{name}/runs`.
+	maxRunsLimit = 1000
+
+	// recordTimeout bounds the time spent recording a run in the history store.
+	recordTimeout = 10 * time.Second
+)
+
+// recordedJob records every run of a job in the history store.
+type recordedJob struct {
+	name    string
+	task    task
+	ctx     func() context.Context
+	replica string
+	store   history.Store
+	metrics *metrics
+	logger  Logger
+}
+
+// Run implements the cron.Job interface. A panic is recorded and then
+// propagated, so it is still handled by cron.Recover.
+func (j *recordedJob) Run() {
+	run := &history.Run{Job: j.name, Replica: j.replica, StartedAt: time.Now(), Outcome: history.OutcomeSucceeded}
+
+	defer func() {
+		if r := recover(); r != nil {
+			run.Outcome = history.OutcomePanicked
+			run.Attempts = max(run.Attempts, 1)
+			run.Error = fmt.Sprint(r)
+			run.Panic = fmt.Sprintf("%v\n%s", r, debug.Stack())
+			j.record(run)
+			panic(r)
+		}
+		j.record(run)
+	}()
+
+	ctx := j.ctx()
+	attempts, err := j.task.run(ctx)
+	run.Attempts = attempts
+	if err != nil {
+		run.Outcome = history.OutcomeFailed
+		if ctx.Err() != nil {
+			run.Outcome = history.OutcomeCanceled
+		}
+		run.Error = err.Error()
+		j.logger.Error(err, "Failed to run watcher", "watcher", j.name, "attempts", attempts)
+	}
+}
+
+func (j *recordedJob) record(run *history.Run) {
+	run.FinishedAt = time.Now()
+	run.Duration = run.FinishedAt.Sub(run.StartedAt)
+	j.metrics.observeRun(run)
+
+	// The context of the run may be cancelled already, a cancelled run is
+	// still recorded.
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	if err := j.store.Add(ctx, run); err != nil {
+		j.logger.Error(err, "Failed to record the run of watcher", "watcher", j.name)
+	}
+}
+
+// jobStatus is the status of a watcher served on `/jobs`.
+type jobStatus struct {
+	Name    string       `json:"name"`
+	Spec    string       `json:"spec"`
+	Trigger string       `json:"trigger,omitempty"`
+	Paused  bool         `json:"paused"`
+	Prev    *time.Time   `json:"prev,omitempty"`
+	Next    *time.Time   `json:"next,omitempty"`
+	LastRun *history.Run `json:"lastRun,omitempty"`
+}
+
+// jobStatus returns the status of a watcher.
+func (w *Watch) jobStatus(ctx context.Context, name string) (*jobStatus, error) {
+	j, err := w.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.RLock()
+	status := &jobStatus{Name: name, Spec: j.spec, Paused: j.paused.Load()}
+	if j.source != nil {
+		status.Trigger = j.source.String()
+	}
+	w.mu.RUnlock()
+
+	if entry, ok := w.jm.GetEntry(name); ok {
+		status.Prev = timeOrNil(entry.Prev)
+		status.Next = timeOrNil(entry.Next)
+	}
+
+	runs, err := w.history.List(ctx, name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) > 0 {
+		status.LastRun = runs[0]
+	}
+
+	return status, nil
+}
+
+// jobsHandler lists the watchers with their schedule and last run.
+func (w *Watch) jobsHandler(rw http.ResponseWriter, r *http.Request) {
+	w.mu.RLock()
+	names := make([]string, 0, len(w.jobs))
+	for name := range w.jobs {
+		names = append(names, name)
+	}
+	w.mu.RUnlock()
+	sort.Strings(names)
+
+	jobs := make([]*jobStatus, 0, len(names))
+	for _, name := range names {
+		status, err := w.jobStatus(r.Context(), name)
+		if err != nil {
+			writeControlError(rw, err)
+			return
+		}
+		jobs = append(jobs, status)
+	}
+
+	writeJSON(rw, http.StatusOK, jobs)
+}
+
+// writeJobStatus responds with the status of a watcher.
+func (w *Watch) writeJobStatus(rw http.ResponseWriter, r *http.Request, name string) {
+	status, err := w.jobStatus(r.Context(), name)
+	if err != nil {
+		writeControlError(rw, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, status)
+}
+
+// runsHandler lists the latest runs of a watcher, the number of runs is
+// limited by the `limit` query parameter (default 20, at most 1000).
+func (w *Watch) runsHandler(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if _, err := w.lookup(name); err != nil {
+		writeControlError(rw, err)
+		return
+	}
+
+	limit := defaultRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		limit = min(n, maxRunsLimit)
+	}
+
+	runs, err := w.history.List(r.Context(), name, limit)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, runs)
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeJSON(rw http.ResponseWriter, code int, v any) {
+	rw.Header().Set("Content-type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+func writeError(rw http.ResponseWriter, code int, err error) {
+	writeJSON(rw, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,3 @@
+// Package history records the runs of watchers, so operators can see when a
+// watcher last ran, how long it took and why it failed.
+package history // import "github.com/ydcloud-dy/publicPkg/pkg/watch/history"
@@ -0,0 +1,98 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultMaxRuns is the default number of runs kept for each job by GORMStore.
+const DefaultMaxRuns = 1000
+
+// GORMStore stores runs in a database table, so the history is shared by all
+// replicas and survives restarts. Older runs are deleted when a run is
+// added, according to the retention of the store.
+type GORMStore struct {
+	db      *gorm.DB
+	maxRuns int
+	maxAge  time.Duration
+}
+
+// Ensure GORMStore implements the Store interface.
+var _ Store = (*GORMStore)(nil)
+
+// GORMOption configures a GORMStore.
+type GORMOption func(s *GORMStore)
+
+// WithMaxRuns sets the number of runs kept for each job (default
+// DefaultMaxRuns), a non-positive number keeps every run.
+func WithMaxRuns(n int) GORMOption {
+	return func(s *GORMStore) {
+		s.maxRuns = n
+	}
+}
+
+// WithMaxAge deletes the runs started more than maxAge ago, a non-positive
+// age keeps the runs regardless of their age (default).
+func WithMaxAge(maxAge time.Duration) GORMOption {
+	return func(s *GORMStore) {
+		s.maxAge = maxAge
+	}
+}
+
+// NewGORMStore creates a GORMStore and migrates the `watch_run` table.
+func NewGORMStore(db *gorm.DB, opts ...GORMOption) (*GORMStore, error) {
+	if err := db.AutoMigrate(&Run{}); err != nil {
+		return nil, err
+	}
+
+	s := &GORMStore{db: db, maxRuns: DefaultMaxRuns}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Add records a finished run and deletes the runs of the job which fall
+// out of the retention.
+func (s *GORMStore) Add(ctx context.Context, run *Run) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Create(run).Error; err != nil {
+		return err
+	}
+
+	if s.maxAge > 0 {
+		if err := db.Where("job = ? AND started_at < ?", run.Job, time.Now().Add(-s.maxAge)).Delete(&Run{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if s.maxRuns > 0 {
+		// The newest run beyond the limit, it and every older run are deleted.
+		var ids []uint64
+		err := db.Model(&Run{}).Where("job = ?", run.Job).Order("id DESC").Offset(s.maxRuns).Limit(1).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			return db.Where("job = ? AND id <= ?", run.Job, ids[0]).Delete(&Run{}).Error
+		}
+	}
+
+	return nil
+}
+
+// List returns at most limit runs of the job, newest first.
+func (s *GORMStore) List(ctx context.Context, job string, limit int) ([]*Run, error) {
+	query := s.db.WithContext(ctx).Where("job = ?", job).Order("started_at DESC").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	runs := []*Run{}
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,46 @@
+package history
+
+import (
+	"context"
+	"time"
+)
+
+// Outcomes of a run.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomePanicked  = "panicked"
+	// OutcomeCanceled means the run was cancelled because the watch server
+	// stopped or the replica lost the leadership.
+	OutcomeCanceled = "canceled"
+)
+
+// Run is a single execution of a watcher.
+type Run struct {
+	ID         uint64        `json:"id" gorm:"primarykey"`
+	Job        string        `json:"job" gorm:"index:idx_watch_run_job;size:255"`
+	Replica    string        `json:"replica,omitempty" gorm:"size:255"`
+	StartedAt  time.Time     `json:"startedAt" gorm:"index:idx_watch_run_job"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Duration   time.Duration `json:"duration"`
+	Outcome    string        `json:"outcome" gorm:"size:32"`
+	Attempts   int           `json:"attempts"`
+	Error      string        `json:"error,omitempty"`
+	// Panic is the panic value and the stack trace when the run panicked.
+	Panic string `json:"panic,omitempty"`
+}
+
+// TableName returns the table name of Run.
+func (*Run) TableName() string {
+	return "watch_run"
+}
+
+// Store stores the runs of watchers.
+type Store interface {
+	// Add records a finished run.
+	Add(ctx context.Context, run *Run) error
+
+	// List returns at most limit runs of the job, newest first. A limit <= 0
+	// returns all stored runs.
+	List(ctx context.Context, job string, limit int) ([]*Run, error)
+}
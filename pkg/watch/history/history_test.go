@@ -0,0 +1,89 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		run := &Run{Job: "a", StartedAt: start.Add(time.Duration(i) * time.Second), Outcome: OutcomeSucceeded, Error: fmt.Sprint(i)}
+		require.NoError(t, store.Add(ctx, run))
+		assert.NotZero(t, run.ID)
+	}
+	require.NoError(t, store.Add(ctx, &Run{Job: "b", StartedAt: start, Outcome: OutcomeFailed}))
+
+	runs, err := store.List(ctx, "a", 2)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "4", runs[0].Error)
+	assert.Equal(t, "3", runs[1].Error)
+
+	runs, err = store.List(ctx, "b", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, OutcomeFailed, runs[0].Outcome)
+
+	runs, err = store.List(ctx, "c", 0)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(10))
+
+	// The oldest runs are overwritten when the buffer is full.
+	store := NewMemoryStore(3)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Add(context.Background(), &Run{Job: "a", Error: fmt.Sprint(i)}))
+	}
+	runs, err := store.List(context.Background(), "a", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.Equal(t, []string{"4", "3", "2"}, []string{runs[0].Error, runs[1].Error, runs[2].Error})
+}
+
+func TestGORMStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	store, err := NewGORMStore(db)
+	require.NoError(t, err)
+	testStore(t, store)
+}
+
+func TestGORMStoreRetention(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	store, err := NewGORMStore(db, WithMaxRuns(3), WithMaxAge(time.Hour))
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, store.Add(ctx, &Run{Job: "a", StartedAt: start.Add(-2 * time.Hour), Error: "old"}))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Add(ctx, &Run{Job: "a", StartedAt: start.Add(time.Duration(i) * time.Second), Error: fmt.Sprint(i)}))
+	}
+	require.NoError(t, store.Add(ctx, &Run{Job: "b", StartedAt: start}))
+
+	runs, err := store.List(ctx, "a", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4", "3", "2"}, []string{runs[0].Error, runs[1].Error, runs[2].Error})
+	require.Len(t, runs, 3)
+
+	// The retention applies to each job separately.
+	runs, err = store.List(ctx, "b", 0)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
@@ -0,0 +1,85 @@
+package history
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultSize is the default number of runs kept for each job by MemoryStore.
+const DefaultSize = 100
+
+// MemoryStore keeps the latest runs of each job in a ring buffer.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	size   int
+	nextID uint64
+	rings  map[string]*ring
+}
+
+// Ensure MemoryStore implements the Store interface.
+var _ Store = (*MemoryStore)(nil)
+
+// ring is a fixed size buffer, the oldest run is overwritten when it is full.
+type ring struct {
+	runs []*Run
+	next int
+	full bool
+}
+
+// NewMemoryStore creates a MemoryStore which keeps the latest size runs of each job.
+func NewMemoryStore(size int) *MemoryStore {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &MemoryStore{size: size, rings: make(map[string]*ring)}
+}
+
+// Add records a finished run.
+func (s *MemoryStore) Add(ctx context.Context, run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.rings[run.Job]
+	if !ok {
+		r = &ring{runs: make([]*Run, s.size)}
+		s.rings[run.Job] = r
+	}
+
+	s.nextID++
+	stored := *run
+	stored.ID = s.nextID
+	run.ID = stored.ID
+
+	r.runs[r.next] = &stored
+	r.next = (r.next + 1) % len(r.runs)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+// List returns at most limit runs of the job, newest first.
+func (s *MemoryStore) List(ctx context.Context, job string, limit int) ([]*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.rings[job]
+	if !ok {
+		return []*Run{}, nil
+	}
+
+	count := r.next
+	if r.full {
+		count = len(r.runs)
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	runs := make([]*Run, 0, count)
+	for i := 1; i <= count; i++ {
+		run := *r.runs[(r.next-i+len(r.runs))%len(r.runs)]
+		runs = append(runs, &run)
+	}
+	return runs, nil
+}
@@ -0,0 +1,72 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+)
+
+func TestJobHistory(t *testing.T) {
+	w := newTestWatch(state.NewMemoryStore())
+	require.NoError(t, w.addWatcher("ok", cron.FuncJob(func() {})))
+	require.NoError(t, w.addWatcher("panic", cron.FuncJob(func() { panic("boom") })))
+
+	w.jobs["ok"].Run()
+	assert.PanicsWithValue(t, "boom", w.jobs["panic"].Run)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/jobs", w.jobsHandler)
+	r.HandleFunc("/jobs/{name}/runs", w.runsHandler)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var jobs []*jobStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&jobs))
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "ok", jobs[0].Name)
+	assert.Equal(t, history.OutcomeSucceeded, jobs[0].LastRun.Outcome)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/panic/runs?limit=5", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var runs []*history.Run
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&runs))
+	require.Len(t, runs, 1)
+	assert.Equal(t, history.OutcomePanicked, runs[0].Outcome)
+	assert.Equal(t, "boom", runs[0].Error)
+	assert.Contains(t, runs[0].Panic, "goroutine")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/unknown/runs", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The number of runs is capped.
+	store := &limitStore{Store: w.history}
+	w.history = store
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/ok/runs?limit=1000000", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, maxRunsLimit, store.limit)
+}
+
+// limitStore records the limit of the last List call.
+type limitStore struct {
+	history.Store
+	limit int
+}
+
+func (s *limitStore) List(ctx context.Context, job string, limit int) ([]*history.Run, error) {
+	s.limit = limit
+	return s.Store.List(ctx, job, limit)
+}
@@ -1,8 +1,11 @@
 package initializer
 
 import (
-	"github.com/onexstack/onexstack/pkg/watch/manager"
-	"github.com/onexstack/onexstack/pkg/watch/registry"
+	"sync"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/manager"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/pool"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
 )
 
 // watcherInitializer is responsible for initializing specific watcher plugins.
@@ -10,19 +13,84 @@ type watcherInitializer struct {
 	jm *manager.JobManager
 	// Specify the maximum concurrency event of user watcher.
 	maxWorkers int64
+	// Options of the worker pools, the number of workers is maxWorkers.
+	poolOptions pool.Options
+	// Panic handler of the worker pools.
+	panicHandler func(name string, err error)
+
+	mu sync.Mutex
+	// Worker pools of the named watchers.
+	pools map[string]*pool.Pool
 }
 
-// Ensure that watcherInitializer implements the WatcherInitializer interface.
-var _ WatcherInitializer = (*watcherInitializer)(nil)
+// Ensure that watcherInitializer implements the PoolInitializer and
+// ContextWatcherInitializer interfaces.
+var (
+	_ PoolInitializer           = (*watcherInitializer)(nil)
+	_ ContextWatcherInitializer = (*watcherInitializer)(nil)
+)
+
+// Option configures the watcherInitializer.
+type Option func(i *watcherInitializer)
+
+// WithPoolOptions sets the queue size and the rate limit of the worker pools.
+func WithPoolOptions(opts pool.Options) Option {
+	return func(i *watcherInitializer) {
+		i.poolOptions = opts
+	}
+}
+
+// WithPoolPanicHandler sets the function called when a task of the worker pool of a watcher panics.
+func WithPoolPanicHandler(handler func(name string, err error)) Option {
+	return func(i *watcherInitializer) {
+		i.panicHandler = handler
+	}
+}
 
 // NewInitializer creates and returns a new watcherInitializer instance.
-func NewInitializer(jm *manager.JobManager, maxWorkers int64) *watcherInitializer {
-	return &watcherInitializer{jm: jm, maxWorkers: maxWorkers}
+func NewInitializer(jm *manager.JobManager, maxWorkers int64, opts ...Option) *watcherInitializer {
+	i := &watcherInitializer{jm: jm, maxWorkers: maxWorkers, pools: make(map[string]*pool.Pool)}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.poolOptions.Workers = int(maxWorkers)
+	return i
 }
 
 // Initialize configures the provided watcher by setting up the necessary dependencies
-// such as the JobManager and maximum workers.
+// such as the JobManager and maximum workers. A worker pool is only set by
+// InitializeNamed and InitializeNamedContext, which track it to be closed.
 func (i *watcherInitializer) Initialize(wc registry.Watcher) {
+	i.setDependencies(wc)
+}
+
+// InitializeNamed configures the provided watcher the same as Initialize, the
+// worker pool of a named watcher is reported by Pools.
+func (i *watcherInitializer) InitializeNamed(name string, wc registry.Watcher) {
+	i.initialize(name, wc)
+}
+
+// InitializeContext configures the provided context watcher the same as Initialize.
+func (i *watcherInitializer) InitializeContext(wc registry.ContextWatcher) {
+	i.setDependencies(wc)
+}
+
+// InitializeNamedContext configures the provided context watcher the same as InitializeNamed.
+func (i *watcherInitializer) InitializeNamedContext(name string, wc registry.ContextWatcher) {
+	i.initialize(name, wc)
+}
+
+// initialize sets the resources wanted by a registry.Watcher or a registry.ContextWatcher.
+func (i *watcherInitializer) initialize(name string, wc any) {
+	i.setDependencies(wc)
+
+	if wants, ok := wc.(WantsWorkerPool); ok {
+		wants.SetWorkerPool(i.newPool(name))
+	}
+}
+
+// setDependencies sets the JobManager and maximum workers of a watcher.
+func (i *watcherInitializer) setDependencies(wc any) {
 	// We can set a specific configuration as needed, as shown in the example below.
 	// However, for convenience, I directly assign all configurations to each watcher,
 	// allowing the watcher to choose which ones to use.
@@ -34,3 +102,41 @@ func (i *watcherInitializer) Initialize(wc registry.Watcher) {
 		wants.SetMaxWorkers(i.maxWorkers)
 	}
 }
+
+// newPool creates the worker pool of a watcher.
+func (i *watcherInitializer) newPool(name string) *pool.Pool {
+	var opts []pool.Option
+	if i.panicHandler != nil {
+		opts = append(opts, pool.WithPanicHandler(func(err error) {
+			i.panicHandler(name, err)
+		}))
+	}
+	p := pool.New(i.poolOptions, opts...)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if old, ok := i.pools[name]; ok {
+		go old.Close()
+	}
+	i.pools[name] = p
+	return p
+}
+
+// Pools returns the worker pools of the watchers.
+func (i *watcherInitializer) Pools() map[string]*pool.Pool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pools := make(map[string]*pool.Pool, len(i.pools))
+	for name, p := range i.pools {
+		pools[name] = p
+	}
+	return pools
+}
+
+// Close closes the worker pools of the watchers, it waits for their queued tasks to complete.
+func (i *watcherInitializer) Close() {
+	for _, p := range i.Pools() {
+		p.Close()
+	}
+}
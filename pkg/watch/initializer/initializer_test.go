@@ -0,0 +1,55 @@
+package initializer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/pool"
+)
+
+type poolWatcher struct {
+	maxWorkers int64
+	pool       *pool.Pool
+}
+
+func (w *poolWatcher) Run() {}
+
+func (w *poolWatcher) SetMaxWorkers(maxWorkers int64) { w.maxWorkers = maxWorkers }
+
+func (w *poolWatcher) SetWorkerPool(p *pool.Pool) { w.pool = p }
+
+type contextPoolWatcher struct {
+	poolWatcher
+}
+
+func (w *contextPoolWatcher) Run(ctx context.Context) error { return nil }
+
+func TestInitializer(t *testing.T) {
+	i := NewInitializer(nil, 2)
+	defer i.Close()
+
+	// An unnamed watcher gets no pool, it would never be closed.
+	unnamed := &poolWatcher{}
+	i.Initialize(unnamed)
+	assert.Equal(t, int64(2), unnamed.maxWorkers)
+	assert.Nil(t, unnamed.pool)
+	assert.Empty(t, i.Pools())
+
+	named := &poolWatcher{}
+	i.InitializeNamed("named", named)
+	assert.Equal(t, int64(2), named.maxWorkers)
+	assert.NotNil(t, named.pool)
+
+	unnamedCtx := &contextPoolWatcher{}
+	i.InitializeContext(unnamedCtx)
+	assert.Equal(t, int64(2), unnamedCtx.maxWorkers)
+	assert.Nil(t, unnamedCtx.pool)
+
+	ctxWatcher := &contextPoolWatcher{}
+	i.InitializeNamedContext("context", ctxWatcher)
+	assert.NotNil(t, ctxWatcher.pool)
+
+	assert.Equal(t, map[string]*pool.Pool{"named": named.pool, "context": ctxWatcher.pool}, i.Pools())
+}
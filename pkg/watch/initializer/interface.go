@@ -1,8 +1,9 @@
 package initializer
 
 import (
-	"github.com/onexstack/onexstack/pkg/watch/manager"
-	"github.com/onexstack/onexstack/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/manager"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/pool"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
 )
 
 // WatcherInitializer is used for initialization of shareable resources between watcher plugins.
@@ -11,14 +12,40 @@ type WatcherInitializer interface {
 	Initialize(watcher registry.Watcher)
 }
 
+// ContextWatcherInitializer is a WatcherInitializer which also initializes
+// the watchers registered by registry.RegisterContext. This interface is
+// optional for a WatcherInitializer.
+type ContextWatcherInitializer interface {
+	InitializeContext(watcher registry.ContextWatcher)
+}
+
+// PoolInitializer is a WatcherInitializer which also creates the worker pools
+// of watchers, the pools are named by the registered names of the watchers.
+type PoolInitializer interface {
+	WatcherInitializer
+	// InitializeNamed initializes the watcher registered with name.
+	InitializeNamed(name string, watcher registry.Watcher)
+	// InitializeNamedContext initializes the context watcher registered with name.
+	InitializeNamedContext(name string, watcher registry.ContextWatcher)
+	// Pools returns the worker pools of the named watchers.
+	Pools() map[string]*pool.Pool
+	// Close closes the worker pools.
+	Close()
+}
+
 // WantsJobManager defines a function which sets job manager for watcher plugins that need it.
+// The Wants interfaces apply to a registry.Watcher and a registry.ContextWatcher.
 type WantsJobManager interface {
-	registry.Watcher
 	SetJobManager(jm *manager.JobManager)
 }
 
 // WantsMaxWorkers defines a function which sets max workers for watcher plugins that need it.
 type WantsMaxWorkers interface {
-	registry.Watcher
 	SetMaxWorkers(maxWorkers int64)
 }
+
+// WantsWorkerPool defines a function which sets a bounded worker pool for watcher plugins that need it.
+// The pool runs at most max-workers tasks of the watcher concurrently.
+type WantsWorkerPool interface {
+	SetWorkerPool(pool *pool.Pool)
+}
@@ -2,16 +2,45 @@ package manager
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
 // JobManager manages cron jobs.
 type JobManager struct {
-	mu            sync.Mutex              // Mutex for synchronizing access to jobs
-	cronScheduler *cron.Cron              // The cron scheduler instance
-	jobs          map[string]cron.EntryID // Map to store job names and their Entry IDs
+	mu            sync.Mutex             // Mutex for synchronizing access to jobs
+	cronScheduler *cron.Cron             // The cron scheduler instance
+	jobs          map[string]*managedJob // Map to store job names and their cron entries
+	running       atomic.Bool            // Whether the cron scheduler is started
+}
+
+// managedJob is the job added to the cron scheduler. The job it runs can be
+// swapped in place, so updating a job does not change its cron entry.
+type managedJob struct {
+	entryID cron.EntryID
+	spec    string
+	cmd     atomic.Pointer[cron.Job]
+}
+
+// Run implements the cron.Job interface.
+func (j *managedJob) Run() {
+	(*j.cmd.Load()).Run()
+}
+
+// JobInfo is a snapshot of a cron job.
+type JobInfo struct {
+	Name    string
+	Spec    string
+	EntryID cron.EntryID
+	// Prev is the last time the job was run, or the zero time if never.
+	Prev time.Time
+	// Next is the next time the job will run, or the zero time if the cron
+	// scheduler has not been started.
+	Next time.Time
 }
 
 // Option defines a function type that configures JobManager options.
@@ -28,7 +57,7 @@ func WithCron(c *cron.Cron) Option {
 func NewJobManager(opts ...Option) *JobManager {
 	jm := &JobManager{
 		cronScheduler: cron.New(),
-		jobs:          make(map[string]cron.EntryID),
+		jobs:          make(map[string]*managedJob),
 	}
 
 	// Set with custom options
@@ -49,15 +78,14 @@ func (jm *JobManager) AddJob(jobName string, schedule string, cmd cron.Job) (cro
 		return 0, &JobExistsError{JobName: jobName}
 	}
 
-	// Add the job to the cron scheduler
-	entryID, err := jm.cronScheduler.AddJob(schedule, cmd)
+	job, err := jm.addLocked(schedule, cmd)
 	if err != nil {
 		return 0, err // Return error if adding the job fails
 	}
 
 	// Store the job in the map
-	jm.jobs[jobName] = entryID
-	return entryID, nil
+	jm.jobs[jobName] = job
+	return job.entryID, nil
 }
 
 // RemoveJob removes a specified cron job from the manager.
@@ -65,44 +93,129 @@ func (jm *JobManager) RemoveJob(jobName string) error {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
 
-	entryID, exists := jm.jobs[jobName]
+	job, exists := jm.jobs[jobName]
 	if !exists {
 		return nil
 	}
 
 	// Remove the job from the cron scheduler and delete it from the map
-	jm.cronScheduler.Remove(entryID)
+	jm.cronScheduler.Remove(job.entryID)
 	delete(jm.jobs, jobName)
 	return nil
-
 }
 
 // UpdateJob updates a specified cron job with a new schedule and function.
+//
+// When the schedule is unchanged the function is swapped in place and the job
+// keeps its cron entry. Otherwise the new entry is added before the old one is
+// removed, so the job is never missing from the cron scheduler.
 func (jm *JobManager) UpdateJob(jobName string, schedule string, cmd cron.Job) error {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
 
-	// Check if the job exists before attempting to remove it
-	if _, exists := jm.jobs[jobName]; !exists {
+	// Check if the job exists before attempting to update it
+	old, exists := jm.jobs[jobName]
+	if !exists {
 		return &JobNotFoundError{JobName: jobName}
 	}
 
-	// Remove the existing job and add it again with new parameters
-	err := jm.RemoveJob(jobName)
+	if old.spec == schedule {
+		old.cmd.Store(&cmd)
+		return nil
+	}
+
+	job, err := jm.addLocked(schedule, cmd)
 	if err != nil {
 		return err
 	}
 
-	_, err = jm.AddJob(jobName, schedule, cmd)
-	return err
+	jm.cronScheduler.Remove(old.entryID)
+	jm.jobs[jobName] = job
+	return nil
 }
 
-// GetJobs returns a map of all the current cron jobs.
+// ReplaceJob swaps the function of a specified cron job in place, the schedule
+// and the cron entry of the job are kept.
+func (jm *JobManager) ReplaceJob(jobName string, cmd cron.Job) error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	job, exists := jm.jobs[jobName]
+	if !exists {
+		return &JobNotFoundError{JobName: jobName}
+	}
+
+	job.cmd.Store(&cmd)
+	return nil
+}
+
+// addLocked adds the job to the cron scheduler, jm.mu must be held.
+func (jm *JobManager) addLocked(schedule string, cmd cron.Job) (*managedJob, error) {
+	job := &managedJob{spec: schedule}
+	job.cmd.Store(&cmd)
+
+	entryID, err := jm.cronScheduler.AddJob(schedule, job)
+	if err != nil {
+		return nil, err
+	}
+
+	job.entryID = entryID
+	return job, nil
+}
+
+// GetJobs returns a snapshot of the names and entry IDs of all the current cron jobs.
 func (jm *JobManager) GetJobs() map[string]cron.EntryID {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
 
-	return jm.jobs
+	jobs := make(map[string]cron.EntryID, len(jm.jobs))
+	for name, job := range jm.jobs {
+		jobs[name] = job.entryID
+	}
+	return jobs
+}
+
+// GetJob returns a snapshot of a specific job.
+func (jm *JobManager) GetJob(jobName string) (JobInfo, bool) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	job, exists := jm.jobs[jobName]
+	if !exists {
+		return JobInfo{}, false
+	}
+	return jm.infoLocked(jobName, job), true
+}
+
+// ListJobs returns snapshots of all the current cron jobs sorted by name.
+func (jm *JobManager) ListJobs() []JobInfo {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(jm.jobs))
+	for name, job := range jm.jobs {
+		infos = append(infos, jm.infoLocked(name, job))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+func (jm *JobManager) infoLocked(name string, job *managedJob) JobInfo {
+	entry := jm.cronScheduler.Entry(job.entryID)
+	return JobInfo{Name: name, Spec: job.spec, EntryID: job.entryID, Prev: entry.Prev, Next: entry.Next}
+}
+
+// GetEntry returns the cron entry of a specific job, which carries its previous
+// and next scheduled time.
+func (jm *JobManager) GetEntry(jobName string) (cron.Entry, bool) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	job, exists := jm.jobs[jobName]
+	if !exists {
+		return cron.Entry{}, false
+	}
+	return jm.cronScheduler.Entry(job.entryID), true
 }
 
 // JobExists checks if a specific job exists in the manager.
@@ -117,9 +230,16 @@ func (jm *JobManager) JobExists(jobName string) bool {
 // Start starts the cron scheduler to begin executing jobs.
 func (jm *JobManager) Start() {
 	jm.cronScheduler.Start()
+	jm.running.Store(true)
 }
 
 // Stop stops the cron scheduler.
 func (jm *JobManager) Stop() context.Context {
+	jm.running.Store(false)
 	return jm.cronScheduler.Stop()
 }
+
+// Running reports whether the cron scheduler is started and not stopped.
+func (jm *JobManager) Running() bool {
+	return jm.running.Load()
+}
@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateJob(t *testing.T) {
+	jm := NewJobManager(WithCron(cron.New(cron.WithSeconds())))
+
+	var calls atomic.Int64
+	entryID, err := jm.AddJob("job", "@every 1h", cron.FuncJob(func() { calls.Add(1) }))
+	require.NoError(t, err)
+
+	_, err = jm.AddJob("job", "@every 1h", cron.FuncJob(func() {}))
+	assert.IsType(t, &JobExistsError{}, err)
+	assert.IsType(t, &JobNotFoundError{}, jm.UpdateJob("missing", "@every 1h", cron.FuncJob(func() {})))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- jm.UpdateJob("job", "@every 1h", cron.FuncJob(func() { calls.Add(10) }))
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("UpdateJob deadlocked")
+	}
+
+	// The same schedule swaps the job in place.
+	info, ok := jm.GetJob("job")
+	require.True(t, ok)
+	assert.Equal(t, entryID, info.EntryID)
+	entryOf(t, jm, "job").WrappedJob.Run()
+	assert.Equal(t, int64(10), calls.Load())
+
+	// A new schedule moves the job to a new entry.
+	require.NoError(t, jm.UpdateJob("job", "@every 2h", cron.FuncJob(func() { calls.Add(100) })))
+	info, ok = jm.GetJob("job")
+	require.True(t, ok)
+	assert.NotEqual(t, entryID, info.EntryID)
+	assert.Equal(t, "@every 2h", info.Spec)
+	assert.Len(t, jm.cronScheduler.Entries(), 1)
+
+	require.NoError(t, jm.ReplaceJob("job", cron.FuncJob(func() { calls.Add(1000) })))
+	entryOf(t, jm, "job").WrappedJob.Run()
+	assert.Equal(t, int64(1010), calls.Load())
+
+	assert.Error(t, jm.UpdateJob("job", "bad spec", cron.FuncJob(func() {})))
+	assert.True(t, jm.JobExists("job"))
+}
+
+func TestGetJobsSnapshot(t *testing.T) {
+	jm := NewJobManager()
+	_, err := jm.AddJob("job", "@every 1h", cron.FuncJob(func() {}))
+	require.NoError(t, err)
+
+	jobs := jm.GetJobs()
+	delete(jobs, "job")
+	assert.True(t, jm.JobExists("job"))
+	assert.Len(t, jm.GetJobs(), 1)
+}
+
+func TestConcurrentJobChanges(t *testing.T) {
+	jm := NewJobManager(WithCron(cron.New(cron.WithSeconds())))
+	jm.Start()
+	defer func() { <-jm.Stop().Done() }()
+
+	var runs atomic.Int64
+	job := cron.FuncJob(func() { runs.Add(1) })
+
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(1500 * time.Millisecond)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			name := fmt.Sprintf("job-%d", i%4)
+			for n := 0; time.Now().Before(deadline); n++ {
+				switch n % 5 {
+				case 0:
+					_, _ = jm.AddJob(name, "* * * * * *", job)
+				case 1:
+					_ = jm.UpdateJob(name, "* * * * * *", job)
+				case 2:
+					_ = jm.UpdateJob(name, "*/2 * * * * *", job)
+				case 3:
+					_ = jm.ListJobs()
+					_ = jm.GetJobs()
+					_, _ = jm.GetJob(name)
+				case 4:
+					_ = jm.RemoveJob(name)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every job known by the manager has exactly one cron entry.
+	jobs := jm.GetJobs()
+	assert.Len(t, jm.cronScheduler.Entries(), len(jobs))
+	for _, info := range jm.ListJobs() {
+		assert.Equal(t, jobs[info.Name], info.EntryID)
+	}
+}
+
+// entryOf returns the cron entry of the job or fails the test.
+func entryOf(t *testing.T, jm *JobManager, jobName string) cron.Entry {
+	t.Helper()
+
+	entry, ok := jm.GetEntry(jobName)
+	require.True(t, ok)
+	return entry
+}
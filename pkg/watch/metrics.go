@@ -0,0 +1,190 @@
+package watch
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/pool"
+)
+
+const metricsNamespace = "watch"
+
+// Reasons of the skipped runs.
+const (
+	// skipStillRunning is a scheduled run delayed by DelayIfStillRunning until
+	// the previous run of the watcher is completed.
+	skipStillRunning = "still_running"
+	// skipPaused is a scheduled run of a paused watcher.
+	skipPaused = "paused"
+	// skipLocked is a run in per-watcher mode whose lock is held by another replica.
+	skipLocked = "locked"
+)
+
+// runBuckets are the buckets of run durations and lags in seconds, from 5ms to about 22 minutes.
+var runBuckets = prometheus.ExponentialBuckets(0.005, 4, 10)
+
+// metrics are the Prometheus metrics of the watchers. A nil *metrics records nothing.
+type metrics struct {
+	runs        *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	lag         *prometheus.HistogramVec
+	lastSuccess *prometheus.GaugeVec
+	skipped     *prometheus.CounterVec
+	leader      prometheus.Gauge
+	handler     http.Handler
+}
+
+// newMetrics creates the metrics of the watchers and registers them to registry.
+// pools returns the worker pools of the watchers, it may be nil.
+func newMetrics(registry *prometheus.Registry, pools func() map[string]*pool.Pool) (*metrics, error) {
+	m := &metrics{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "runs_total",
+			Help:      "Number of runs of the watcher by outcome.",
+		}, []string{"watcher", "outcome"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "run_duration_seconds",
+			Help:      "Duration of the runs of the watcher in seconds.",
+			Buckets:   runBuckets,
+		}, []string{"watcher"}),
+		lag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "run_lag_seconds",
+			Help:      "Delay between the scheduled time and the start of the runs of the watcher in seconds.",
+			Buckets:   runBuckets,
+		}, []string{"watcher"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful run of the watcher.",
+		}, []string{"watcher"}),
+		skipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "skipped_runs_total",
+			Help: "Number of scheduled runs of the watcher not started on time by reason: " +
+				"still_running (delayed by DelayIfStillRunning), paused or locked (running on another replica).",
+		}, []string{"watcher", "reason"}),
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "leader",
+			Help:      "Whether this replica holds the lock of the watch server in leader mode.",
+		}),
+	}
+
+	collectors := []prometheus.Collector{m.runs, m.duration, m.lag, m.lastSuccess, m.skipped, m.leader}
+	if pools != nil {
+		collectors = append(collectors, newPoolCollector(pools))
+	}
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	m.handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+	return m, nil
+}
+
+// observeRun records a completed run.
+func (m *metrics) observeRun(run *history.Run) {
+	if m == nil {
+		return
+	}
+
+	m.runs.WithLabelValues(run.Job, run.Outcome).Inc()
+	m.duration.WithLabelValues(run.Job).Observe(run.Duration.Seconds())
+	if run.Outcome == history.OutcomeSucceeded {
+		m.lastSuccess.WithLabelValues(run.Job).Set(float64(run.FinishedAt.Unix()))
+	}
+}
+
+// observeLag records the delay of a scheduled run.
+func (m *metrics) observeLag(watcher string, lag time.Duration) {
+	if m == nil {
+		return
+	}
+	m.lag.WithLabelValues(watcher).Observe(max(lag, 0).Seconds())
+}
+
+// skip records a scheduled run not started on time.
+func (m *metrics) skip(watcher, reason string) {
+	if m == nil {
+		return
+	}
+	m.skipped.WithLabelValues(watcher, reason).Inc()
+}
+
+// setLeader records whether this replica holds the lock of the watch server.
+func (m *metrics) setLeader(leader bool) {
+	if m == nil {
+		return
+	}
+
+	if leader {
+		m.leader.Set(1)
+		return
+	}
+	m.leader.Set(0)
+}
+
+// poolCollector reports the utilisation of the worker pools of the watchers.
+type poolCollector struct {
+	pools     func() map[string]*pool.Pool
+	workers   *prometheus.Desc
+	busy      *prometheus.Desc
+	queued    *prometheus.Desc
+	queueSize *prometheus.Desc
+	completed *prometheus.Desc
+	panics    *prometheus.Desc
+}
+
+func newPoolCollector(pools func() map[string]*pool.Pool) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "pool", name), help, []string{"watcher"}, nil)
+	}
+
+	return &poolCollector{
+		pools:     pools,
+		workers:   desc("workers", "Number of workers of the worker pool of the watcher."),
+		busy:      desc("busy_workers", "Number of workers running a task in the worker pool of the watcher."),
+		queued:    desc("queued_tasks", "Number of tasks waiting in the worker pool of the watcher."),
+		queueSize: desc("queue_size", "Capacity of the queue of the worker pool of the watcher."),
+		completed: desc("completed_tasks_total", "Number of tasks completed by the worker pool of the watcher."),
+		panics:    desc("panics_total", "Number of tasks recovered from a panic by the worker pool of the watcher."),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{c.workers, c.busy, c.queued, c.queueSize, c.completed, c.panics} {
+		ch <- desc
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, p := range c.pools() {
+		stats := p.Stats()
+		ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(stats.Workers), name)
+		ch <- prometheus.MustNewConstMetric(c.busy, prometheus.GaugeValue, float64(stats.Busy), name)
+		ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(stats.Queued), name)
+		ch <- prometheus.MustNewConstMetric(c.queueSize, prometheus.GaugeValue, float64(stats.QueueSize), name)
+		ch <- prometheus.MustNewConstMetric(c.completed, prometheus.CounterValue, float64(stats.Completed), name)
+		ch <- prometheus.MustNewConstMetric(c.panics, prometheus.CounterValue, float64(stats.Panics), name)
+	}
+}
+
+// metricsHandler serves the metrics in the Prometheus text format.
+func (w *Watch) metricsHandler(rw http.ResponseWriter, r *http.Request) {
+	if w.metrics == nil {
+		http.NotFound(rw, r)
+		return
+	}
+	w.metrics.handler.ServeHTTP(rw, r)
+}
@@ -0,0 +1,129 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/initializer"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/pool"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWatch(state.NewMemoryStore())
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	require.NoError(t, w.addWatcher("slow", cron.FuncJob(func() {
+		started <- struct{}{}
+		<-release
+	})))
+	require.NoError(t, w.addWatcher("failing", &contextWatcher{run: func(ctx context.Context) error {
+		return errors.New("failed")
+	}}))
+
+	// The second run is delayed until the first one is completed.
+	go w.jobs["slow"].Run()
+	<-started
+	done := make(chan struct{})
+	go func() {
+		w.jobs["slow"].Run()
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(w.metrics.skipped.WithLabelValues("slow", skipStillRunning)) == 1
+	}, time.Second, 10*time.Millisecond)
+	close(release)
+	<-started
+	<-done
+
+	w.jobs["failing"].Run()
+	require.NoError(t, w.Pause(ctx, "failing"))
+	w.jobs["failing"].Run()
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(w.metrics.runs.WithLabelValues("slow", history.OutcomeSucceeded)) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(1), testutil.ToFloat64(w.metrics.runs.WithLabelValues("failing", history.OutcomeFailed)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(w.metrics.skipped.WithLabelValues("failing", skipPaused)))
+	assert.NotZero(t, testutil.ToFloat64(w.metrics.lastSuccess.WithLabelValues("slow")))
+	assert.Zero(t, testutil.ToFloat64(w.metrics.lastSuccess.WithLabelValues("failing")))
+	assert.Equal(t, 2, testutil.CollectAndCount(w.metrics.duration))
+
+	rec := httptest.NewRecorder()
+	w.metricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `watch_runs_total{outcome="failed",watcher="failing"} 1`)
+	assert.Contains(t, rec.Body.String(), `watch_skipped_runs_total{reason="still_running",watcher="slow"} 1`)
+}
+
+func TestHealthz(t *testing.T) {
+	w := newTestWatch(state.NewMemoryStore())
+
+	healthz := func() int {
+		rec := httptest.NewRecorder()
+		w.healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, healthz())
+
+	// The lock is held, but the cron is not running.
+	w.setLeader(true)
+	assert.Equal(t, float64(1), testutil.ToFloat64(w.metrics.leader))
+	assert.Equal(t, http.StatusServiceUnavailable, healthz())
+
+	w.startJobs(context.Background())
+	assert.Equal(t, http.StatusOK, healthz())
+
+	w.setLeader(false)
+	w.stopJobs()
+	assert.Equal(t, float64(0), testutil.ToFloat64(w.metrics.leader))
+	assert.Equal(t, http.StatusOK, healthz())
+}
+
+// poolWatcher wants a worker pool.
+type poolWatcher struct {
+	pool *pool.Pool
+}
+
+func (w *poolWatcher) Run() {}
+
+func (w *poolWatcher) SetWorkerPool(p *pool.Pool) { w.pool = p }
+
+func TestPoolMetrics(t *testing.T) {
+	ini := initializer.NewInitializer(nil, 3, initializer.WithPoolOptions(pool.Options{QueueSize: 5}))
+	defer ini.Close()
+
+	watcher := &poolWatcher{}
+	ini.InitializeNamed("pooled", watcher)
+	require.NotNil(t, watcher.pool)
+	require.NoError(t, watcher.pool.Submit(context.Background(), func() {}))
+
+	registry := prometheus.NewRegistry()
+	_, err := newMetrics(registry, ini.Pools)
+	require.NoError(t, err)
+
+	expected := `
+# HELP watch_pool_workers Number of workers of the worker pool of the watcher.
+# TYPE watch_pool_workers gauge
+watch_pool_workers{watcher="pooled"} 3
+# HELP watch_pool_queue_size Capacity of the queue of the worker pool of the watcher.
+# TYPE watch_pool_queue_size gauge
+watch_pool_queue_size{watcher="pooled"} 5
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "watch_pool_workers", "watch_pool_queue_size"))
+}
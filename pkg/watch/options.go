@@ -2,8 +2,12 @@ package watch
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/spf13/pflag"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
 )
 
 // Options structure holds the configuration options required to create and run a watch server.
@@ -11,23 +15,52 @@ type Options struct {
 	// LockName specifies the name of the lock used by the server.
 	LockName string `json:"lock-name" mapstructure:"lock-name"`
 
+	// Mode specifies how watchers are distributed across replicas, leader or per-watcher.
+	Mode string `json:"mode" mapstructure:"mode"`
+
 	// healthzPort is the port number for the health check endpoint.
 	HealthzPort int `json:"healthz-port" mapstructure:"healthz-port"`
 
 	// DisableWatchers is a slice of watchers that will be disabled when the server is run.
 	DisableWatchers []string `json:"disable-watchers" mapstructure:"disable-watchers"`
 
+	// HistorySize is the number of runs kept in memory for each watcher.
+	HistorySize int `json:"history-size" mapstructure:"history-size"`
+
+	// JobTimeout is the default timeout of each run of a context watcher, 0 means no timeout.
+	JobTimeout time.Duration `json:"job-timeout" mapstructure:"job-timeout"`
+
+	// MaxRetries is the default number of retries of a failed context watcher.
+	MaxRetries int `json:"max-retries" mapstructure:"max-retries"`
+
+	// RetryBackoff is the initial backoff between the retries, it is doubled after each retry.
+	RetryBackoff time.Duration `json:"retry-backoff" mapstructure:"retry-backoff"`
+
 	// MaxWorkers defines the maximum number of concurrent workers that each watcher can spawn.
 	MaxWorkers int64 `json:"max-workers" mapstructure:"max-workers"`
+
+	// PoolQueueSize is the number of tasks waiting in the worker pool of each watcher.
+	PoolQueueSize int `json:"pool-queue-size" mapstructure:"pool-queue-size"`
+
+	// PoolRate is the number of tasks started per second by the worker pool of each watcher, 0 means no limit.
+	PoolRate float64 `json:"pool-rate" mapstructure:"pool-rate"`
+
+	// PoolBurst is the number of tasks started at once above PoolRate.
+	PoolBurst int `json:"pool-burst" mapstructure:"pool-burst"`
 }
 
 // NewOptions initializes and returns a new Options instance with default values.
 func NewOptions() *Options {
 	o := &Options{
 		LockName:        "default-distributed-lock",
+		Mode:            ModeLeader,
 		HealthzPort:     8881,
 		DisableWatchers: []string{},
+		HistorySize:     history.DefaultSize,
+		RetryBackoff:    time.Second,
 		MaxWorkers:      10,
+		PoolQueueSize:   100,
+		PoolBurst:       1,
 	}
 
 	return o
@@ -37,18 +70,39 @@ func NewOptions() *Options {
 // This will allow users to configure the watch server via command-line arguments.
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.LockName, "lock-name", o.LockName, "The name of the lock used by the server.")
+	fs.StringVar(&o.Mode, "mode", o.Mode, "How watchers are distributed across replicas. "+
+		"'leader' runs all watchers on the replica holding the lock, 'per-watcher' locks each run of a watcher separately.")
 	fs.IntVar(&o.HealthzPort, "healthz-port", o.HealthzPort, "The port number for the health check endpoint.")
 	fs.StringSliceVar(&o.DisableWatchers, "disable-watchers", o.DisableWatchers, "The list of watchers that should be disabled.")
+	fs.IntVar(&o.HistorySize, "history-size", o.HistorySize, "The number of runs kept in memory for each watcher.")
+	fs.DurationVar(&o.JobTimeout, "job-timeout", o.JobTimeout, "The default timeout of each run of a context watcher, 0 means no timeout.")
+	fs.IntVar(&o.MaxRetries, "max-retries", o.MaxRetries, "The default number of retries of a failed context watcher.")
+	fs.DurationVar(&o.RetryBackoff, "retry-backoff", o.RetryBackoff, "The initial backoff between the retries of a failed context watcher.")
 	fs.Int64Var(&o.MaxWorkers, "max-workers", o.MaxWorkers, "Specify the maximum concurrency worker of each watcher.")
+	fs.IntVar(&o.PoolQueueSize, "pool-queue-size", o.PoolQueueSize, "The number of tasks waiting in the worker pool of each watcher.")
+	fs.Float64Var(&o.PoolRate, "pool-rate", o.PoolRate, "The number of tasks started per second by the worker pool of each watcher, 0 means no limit.")
+	fs.IntVar(&o.PoolBurst, "pool-burst", o.PoolBurst, "The number of tasks started at once above pool-rate.")
 }
 
 // Validate checks the Options structure for required configurations and returns a slice of errors.
 func (o *Options) Validate() []error {
 	errs := []error{}
 
+	if o.Mode != ModeLeader && o.Mode != ModePerWatcher {
+		errs = append(errs, fmt.Errorf("mode must be %s or %s", ModeLeader, ModePerWatcher))
+	}
+
+	if o.JobTimeout < 0 || o.MaxRetries < 0 || o.RetryBackoff < 0 {
+		errs = append(errs, errors.New("job-timeout, max-retries and retry-backoff must not be negative"))
+	}
+
 	if o.MaxWorkers <= 0 {
 		errs = append(errs, errors.New("max-workers must be greater than 0"))
 	}
 
+	if o.PoolQueueSize < 0 || o.PoolRate < 0 || o.PoolBurst < 0 {
+		errs = append(errs, errors.New("pool-queue-size, pool-rate and pool-burst must not be negative"))
+	}
+
 	return errs
 }
@@ -0,0 +1,3 @@
+// Package pool provides the bounded worker pools injected into watchers, so a
+// watcher does not have to build its own pool to process the items it found.
+package pool // import "github.com/ydcloud-dy/publicPkg/pkg/watch/pool"
@@ -0,0 +1,236 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when a task is submitted to a closed pool.
+	ErrClosed = errors.New("pool is closed")
+	// ErrQueueFull is returned by TrySubmit when the queue of the pool is full.
+	ErrQueueFull = errors.New("pool queue is full")
+)
+
+// Options configures a Pool.
+type Options struct {
+	// Workers is the number of tasks run concurrently, at least 1.
+	Workers int
+	// QueueSize is the number of tasks waiting for a worker, Submit blocks
+	// when the queue is full.
+	QueueSize int
+	// Rate is the number of tasks started per second, 0 means no limit.
+	Rate float64
+	// Burst is the number of tasks started at once above Rate, at least 1.
+	Burst int
+}
+
+// Stats is a snapshot of the utilisation of a Pool.
+type Stats struct {
+	Workers   int
+	Busy      int
+	Queued    int
+	QueueSize int
+	Completed uint64
+	Panics    uint64
+}
+
+// Option configures a Pool.
+type Option func(p *Pool)
+
+// WithPanicHandler sets the function called when a task panics, the error
+// carries the recovered value and the stack trace. The panic is dropped by default.
+func WithPanicHandler(handler func(err error)) Option {
+	return func(p *Pool) {
+		p.panicHandler = handler
+	}
+}
+
+// Pool runs the submitted tasks on a bounded number of workers.
+type Pool struct {
+	workers      int
+	queue        chan func()
+	limiter      *limiter
+	panicHandler func(err error)
+
+	mu     sync.RWMutex
+	closed bool
+	// closing unblocks the pending submits when the pool is closed.
+	closing chan struct{}
+	// stop asks the workers to drain the queue and exit.
+	stop chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+
+	busy      atomic.Int64
+	completed atomic.Uint64
+	panics    atomic.Uint64
+}
+
+// New creates a Pool and starts its workers.
+func New(opts Options, options ...Option) *Pool {
+	p := &Pool{
+		workers: max(opts.Workers, 1),
+		queue:   make(chan func(), max(opts.QueueSize, 0)),
+		closing: make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	if opts.Rate > 0 {
+		p.limiter = newLimiter(opts.Rate, max(opts.Burst, 1))
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	p.wg.Add(p.workers)
+	for range p.workers {
+		go p.worker()
+	}
+
+	return p
+}
+
+// Submit queues the task, it blocks while the queue is full until ctx is done.
+func (p *Pool) Submit(ctx context.Context, task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	select {
+	case p.queue <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.closing:
+		return ErrClosed
+	}
+}
+
+// TrySubmit queues the task, it returns ErrQueueFull instead of blocking.
+func (p *Pool) TrySubmit(task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	select {
+	case p.queue <- task:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting tasks and waits for the queued tasks to complete.
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		close(p.closing)
+
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+
+		close(p.stop)
+	})
+	p.wg.Wait()
+}
+
+// Stats returns the utilisation of the pool.
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:   p.workers,
+		Busy:      int(p.busy.Load()),
+		Queued:    len(p.queue),
+		QueueSize: cap(p.queue),
+		Completed: p.completed.Load(),
+		Panics:    p.panics.Load(),
+	}
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case task := <-p.queue:
+			p.run(task)
+		case <-p.stop:
+			for {
+				select {
+				case task := <-p.queue:
+					p.run(task)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Pool) run(task func()) {
+	if p.limiter != nil {
+		// The rate limit is not applied to the queued tasks once the pool is closed.
+		if delay := p.limiter.reserve(); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-p.stop:
+				timer.Stop()
+			}
+		}
+	}
+
+	p.busy.Add(1)
+	defer func() {
+		p.busy.Add(-1)
+		p.completed.Add(1)
+		if r := recover(); r != nil {
+			p.panics.Add(1)
+			if p.panicHandler != nil {
+				p.panicHandler(fmt.Errorf("panic: %v\n%s", r, debug.Stack()))
+			}
+		}
+	}()
+
+	task()
+}
+
+// limiter is a token bucket refilled at rate tokens per second up to burst.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns how long to wait until the token is available.
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
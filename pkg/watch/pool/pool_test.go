@@ -0,0 +1,95 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolBounded(t *testing.T) {
+	p := New(Options{Workers: 2, QueueSize: 10})
+
+	var running, peak atomic.Int64
+	for range 10 {
+		require.NoError(t, p.Submit(context.Background(), func() {
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+		}))
+	}
+
+	p.Close()
+	assert.LessOrEqual(t, peak.Load(), int64(2))
+	assert.Equal(t, uint64(10), p.Stats().Completed)
+	assert.ErrorIs(t, p.Submit(context.Background(), func() {}), ErrClosed)
+}
+
+func TestPoolQueueFull(t *testing.T) {
+	p := New(Options{Workers: 1, QueueSize: 1})
+	defer p.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	require.NoError(t, p.Submit(context.Background(), func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	require.NoError(t, p.TrySubmit(func() {}))
+	assert.ErrorIs(t, p.TrySubmit(func() {}), ErrQueueFull)
+
+	stats := p.Stats()
+	assert.Equal(t, 1, stats.Busy)
+	assert.Equal(t, 1, stats.Queued)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Submit(ctx, func() {}), context.DeadlineExceeded)
+	close(release)
+}
+
+func TestPoolPanic(t *testing.T) {
+	var mu sync.Mutex
+	var recovered []error
+	p := New(Options{Workers: 1}, WithPanicHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		recovered = append(recovered, err)
+	}))
+
+	require.NoError(t, p.Submit(context.Background(), func() { panic("boom") }))
+	var ran bool
+	require.NoError(t, p.Submit(context.Background(), func() { ran = true }))
+	p.Close()
+
+	assert.True(t, ran)
+	assert.Equal(t, uint64(1), p.Stats().Panics)
+	require.Len(t, recovered, 1)
+	assert.ErrorContains(t, recovered[0], "panic: boom")
+}
+
+func TestPoolRateLimit(t *testing.T) {
+	p := New(Options{Workers: 4, QueueSize: 10, Rate: 50, Burst: 1})
+
+	start := time.Now()
+	for range 6 {
+		require.NoError(t, p.Submit(context.Background(), func() {}))
+	}
+	require.Eventually(t, func() bool { return p.Stats().Completed == 6 }, time.Second, time.Millisecond)
+
+	// The first task takes the burst, the other 5 wait 20ms each.
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	p.Close()
+}
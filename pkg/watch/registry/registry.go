@@ -1,10 +1,14 @@
 package registry
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/trigger"
 )
 
 const (
@@ -16,6 +20,76 @@ type Watcher interface {
 	cron.Job
 }
 
+// ContextWatcher is a watcher which can be cancelled and reports its errors,
+// it is registered by RegisterContext. The context is cancelled when the run
+// times out, when the watch server is stopped or when this replica loses the
+// leadership. The optional interfaces apply the same as to a Watcher.
+type ContextWatcher interface {
+	Run(ctx context.Context) error
+}
+
+// ITimeout interface provides the timeout of each run of a ContextWatcher.
+// This method is optional for a watcher.
+type ITimeout interface {
+	Timeout() time.Duration
+}
+
+// RetryPolicy defines how a failed run of a ContextWatcher is retried. The
+// backoff starts with Backoff and is doubled after each retry up to MaxBackoff.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// IRetryPolicy interface provides the retry policy of a ContextWatcher.
+// This method is optional for a watcher.
+type IRetryPolicy interface {
+	RetryPolicy() RetryPolicy
+}
+
+// ITrigger interface provides the trigger of a watcher. A watcher with a
+// trigger other than trigger.CronTrigger is run on the events of the trigger
+// instead of a cron spec, its ContextWatcher gets the events by trigger.FromContext.
+// This method is optional for a watcher.
+type ITrigger interface {
+	Trigger() trigger.Trigger
+}
+
+// DebouncePolicy defines how the events of a trigger are batched into runs.
+// A run starts when no event arrives for Wait, or MaxWait after the first
+// event. The events arriving while the watcher is running are always
+// coalesced into the next run.
+type DebouncePolicy struct {
+	Wait    time.Duration
+	MaxWait time.Duration
+}
+
+// IDebounce interface provides the debounce policy of a watcher with a trigger.
+// This method is optional for a watcher.
+type IDebounce interface {
+	Debounce() DebouncePolicy
+}
+
+// IDependsOn interface provides the names of the watchers which must succeed
+// before the watcher runs. The dependencies are applied when the watcher is a
+// step of a Workflow, they must be steps of the same workflow.
+// This method is optional for a watcher.
+type IDependsOn interface {
+	DependsOn() []string
+}
+
+// Workflow is a DAG of registered watchers run together on a cron spec. A
+// step runs after the steps it depends on succeeded, and the steps downstream
+// of a failed step are skipped. The steps of a workflow are not scheduled on
+// their own.
+type Workflow struct {
+	// Spec is the cron spec of the workflow, Every3Seconds by default.
+	Spec string
+	// Steps are the names of the registered watchers in the workflow.
+	Steps []string
+}
+
 // Spec interface provides methods to set spec for a cron job.
 type ISpec interface {
 	// Spec return the spec for a cron job.
@@ -29,6 +103,8 @@ type ISpec interface {
 var (
 	registryLock = new(sync.Mutex)
 	registry     = make(map[string]Watcher)
+	contexts     = make(map[string]ContextWatcher)
+	workflows    = make(map[string]Workflow)
 )
 
 var (
@@ -43,11 +119,58 @@ func Register(name string, watcher Watcher) {
 	registryLock.Lock()
 	defer registryLock.Unlock()
 
+	checkName(name)
+	registry[name] = watcher
+}
+
+// RegisterContext registers a ContextWatcher, it is run, paused and triggered
+// by its name the same as a watcher registered by Register.
+func RegisterContext(name string, watcher ContextWatcher) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	checkName(name)
+	contexts[name] = watcher
+}
+
+// checkName panics when name is already registered, registryLock must be held.
+func checkName(name string) {
 	if _, ok := registry[name]; ok {
 		panic("duplicate watcher entry: " + name)
 	}
+	if _, ok := contexts[name]; ok {
+		panic("duplicate watcher entry: " + name)
+	}
+	if _, ok := workflows[name]; ok {
+		panic("watcher entry conflicts with workflow: " + name)
+	}
+}
 
-	registry[name] = watcher
+// RegisterWorkflow registers a workflow of the registered watchers. The
+// workflow is run, paused and triggered by its name the same as a watcher.
+func RegisterWorkflow(name string, workflow Workflow) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := workflows[name]; ok {
+		panic("duplicate workflow entry: " + name)
+	}
+	if _, ok := registry[name]; ok {
+		panic("workflow entry conflicts with watcher: " + name)
+	}
+	if _, ok := contexts[name]; ok {
+		panic("workflow entry conflicts with watcher: " + name)
+	}
+
+	workflows[name] = workflow
+}
+
+// ListWorkflows returns registered workflows in map format.
+func ListWorkflows() map[string]Workflow {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	return workflows
 }
 
 // ListWatchers returns registered watchers in map format.
@@ -57,3 +180,11 @@ func ListWatchers() map[string]Watcher {
 
 	return registry
 }
+
+// ListContextWatchers returns registered context watchers in map format.
+func ListContextWatchers() map[string]ContextWatcher {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	return contexts
+}
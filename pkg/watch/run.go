@@ -0,0 +1,102 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
+)
+
+// task runs a watcher once and reports the number of attempts.
+type task interface {
+	run(ctx context.Context) (attempts int, err error)
+}
+
+// errInvalidWatcher is returned when a watcher is neither a registry.Watcher
+// nor a registry.ContextWatcher.
+var errInvalidWatcher = errors.New("watcher must implement registry.Watcher or registry.ContextWatcher")
+
+// newTask creates the task of a watcher, it returns errInvalidWatcher when
+// the watcher is neither a registry.Watcher nor a registry.ContextWatcher.
+func (w *Watch) newTask(watcher any) (task, error) {
+	switch typed := watcher.(type) {
+	case registry.ContextWatcher:
+		t := &contextTask{watcher: typed, timeout: w.jobTimeout, retry: w.retryPolicy}
+		if obj, ok := watcher.(registry.ITimeout); ok {
+			t.timeout = obj.Timeout()
+		}
+		if obj, ok := watcher.(registry.IRetryPolicy); ok {
+			t.retry = obj.RetryPolicy()
+		}
+		return t, nil
+	case registry.Watcher:
+		return &cronTask{job: typed}, nil
+	default:
+		return nil, errInvalidWatcher
+	}
+}
+
+// cronTask runs a plain cron.Job watcher, it can not be cancelled nor retried.
+type cronTask struct {
+	job cron.Job
+}
+
+func (t *cronTask) run(ctx context.Context) (int, error) {
+	t.job.Run()
+	return 1, nil
+}
+
+// contextTask runs a registry.ContextWatcher with a timeout for each attempt,
+// and retries it with exponential backoff on error.
+type contextTask struct {
+	watcher registry.ContextWatcher
+	timeout time.Duration
+	retry   registry.RetryPolicy
+}
+
+func (t *contextTask) run(ctx context.Context) (int, error) {
+	backoff := t.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := t.attempt(ctx)
+		if err == nil || attempt > t.retry.MaxRetries || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if t.retry.MaxBackoff > 0 && backoff > t.retry.MaxBackoff {
+			backoff = t.retry.MaxBackoff
+		}
+	}
+}
+
+func (t *contextTask) attempt(ctx context.Context) error {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	return t.watcher.Run(ctx)
+}
+
+// runContext returns the context of the runs, it is cancelled when the watch
+// server is stopped or this replica loses the leadership.
+func (w *Watch) runContext() context.Context {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.runCtx == nil {
+		return context.Background()
+	}
+	return w.runCtx
+}
@@ -0,0 +1,125 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/distlock"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+)
+
+// contextWatcher is a registry.ContextWatcher for tests.
+type contextWatcher struct {
+	run     func(ctx context.Context) error
+	timeout time.Duration
+}
+
+func (w *contextWatcher) Run(ctx context.Context) error { return w.run(ctx) }
+
+func (w *contextWatcher) Timeout() time.Duration { return w.timeout }
+
+func (w *contextWatcher) RetryPolicy() registry.RetryPolicy {
+	return registry.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+}
+
+func lastRun(t *testing.T, w *Watch, name string) *history.Run {
+	t.Helper()
+
+	runs, err := w.history.List(context.Background(), name, 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	return runs[0]
+}
+
+func TestContextWatcher(t *testing.T) {
+	w := newTestWatch(state.NewMemoryStore())
+
+	var calls atomic.Int64
+	require.NoError(t, w.addWatcher("retry", &contextWatcher{run: func(ctx context.Context) error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}}))
+	require.NoError(t, w.addWatcher("timeout", &contextWatcher{timeout: 10 * time.Millisecond, run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}))
+	assert.ErrorIs(t, w.addWatcher("invalid", "not a watcher"), errInvalidWatcher)
+
+	w.jobs["retry"].Run()
+	run := lastRun(t, w, "retry")
+	assert.Equal(t, history.OutcomeSucceeded, run.Outcome)
+	assert.Equal(t, 3, run.Attempts)
+
+	w.jobs["timeout"].Run()
+	run = lastRun(t, w, "timeout")
+	assert.Equal(t, history.OutcomeFailed, run.Outcome)
+	assert.Equal(t, 3, run.Attempts)
+	assert.Equal(t, context.DeadlineExceeded.Error(), run.Error)
+}
+
+func TestContextWatcherCancelled(t *testing.T) {
+	w := newTestWatch(state.NewMemoryStore())
+
+	started := make(chan struct{})
+	require.NoError(t, w.addWatcher("block", &contextWatcher{run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}))
+
+	w.startJobs(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.jobs["block"].Run()
+		close(done)
+	}()
+
+	<-started
+	w.stopJobs()
+	<-done
+	run := lastRun(t, w, "block")
+	assert.Equal(t, history.OutcomeCanceled, run.Outcome)
+	assert.Equal(t, 1, run.Attempts)
+}
+
+func TestLeadershipLost(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	interval := leadershipCheckInterval
+	leadershipCheckInterval = 10 * time.Millisecond
+	t.Cleanup(func() { leadershipCheckInterval = interval })
+
+	w := newTestWatch(state.NewMemoryStore())
+	w.lockName = "leader"
+	w.locker, err = distlock.NewGORMLocker(db, distlock.WithLockName(w.lockName), distlock.WithOwnerID("a"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.True(t, w.acquireLock(ctx))
+	w.startJobs(ctx)
+	runCtx := w.runContext()
+	go w.keepLeadership(ctx)
+
+	// Another replica takes over the lock.
+	require.NoError(t, db.Model(&distlock.Lock{}).Where("name = ?", w.lockName).Update("owner_id", "b").Error)
+	select {
+	case <-runCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the runs are not cancelled after the leadership is lost")
+	}
+}
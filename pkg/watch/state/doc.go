@@ -0,0 +1,4 @@
+// Package state persists the runtime state of watchers changed through the
+// watch server, e.g. paused watchers and overridden cron specs, so the changes
+// are applied by every replica and survive a leader handover.
+package state // import "github.com/ydcloud-dy/publicPkg/pkg/watch/state"
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobState is the runtime state of a watcher.
+type JobState struct {
+	Job    string `json:"job" gorm:"primarykey;size:255"`
+	Paused bool   `json:"paused"`
+	// Spec overrides the cron spec of the watcher, empty means the spec of the watcher is used.
+	Spec      string    `json:"spec,omitempty" gorm:"size:255"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName returns the table name of JobState.
+func (*JobState) TableName() string {
+	return "watch_job_state"
+}
+
+// Store stores the runtime state of watchers.
+type Store interface {
+	// Save creates or replaces the state of a watcher.
+	Save(ctx context.Context, state *JobState) error
+
+	// List returns the states of all watchers.
+	List(ctx context.Context) ([]*JobState, error)
+}
+
+// MemoryStore keeps the states in memory, the states are only visible to the
+// current replica.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	states map[string]JobState
+}
+
+// Ensure MemoryStore implements the Store interface.
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]JobState)}
+}
+
+// Save creates or replaces the state of a watcher.
+func (s *MemoryStore) Save(ctx context.Context, state *JobState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.UpdatedAt = time.Now()
+	s.states[state.Job] = *state
+	return nil
+}
+
+// List returns the states of all watchers.
+func (s *MemoryStore) List(ctx context.Context) ([]*JobState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make([]*JobState, 0, len(s.states))
+	for _, state := range s.states {
+		states = append(states, &state)
+	}
+	return states, nil
+}
+
+// GORMStore stores the states in a database table shared by all replicas.
+type GORMStore struct {
+	db *gorm.DB
+}
+
+// Ensure GORMStore implements the Store interface.
+var _ Store = (*GORMStore)(nil)
+
+// NewGORMStore creates a GORMStore and migrates the `watch_job_state` table.
+func NewGORMStore(db *gorm.DB) (*GORMStore, error) {
+	if err := db.AutoMigrate(&JobState{}); err != nil {
+		return nil, err
+	}
+	return &GORMStore{db: db}, nil
+}
+
+// Save creates or replaces the state of a watcher.
+func (s *GORMStore) Save(ctx context.Context, state *JobState) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(state).Error
+}
+
+// List returns the states of all watchers.
+func (s *GORMStore) List(ctx context.Context) ([]*JobState, error) {
+	states := []*JobState{}
+	if err := s.db.WithContext(ctx).Find(&states).Error; err != nil {
+		return nil, err
+	}
+	return states, nil
+}
@@ -0,0 +1,31 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestStores(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	gormStore, err := NewGORMStore(db)
+	require.NoError(t, err)
+
+	for _, store := range []Store{NewMemoryStore(), gormStore} {
+		ctx := context.Background()
+		require.NoError(t, store.Save(ctx, &JobState{Job: "a", Paused: true}))
+		require.NoError(t, store.Save(ctx, &JobState{Job: "a", Spec: "@every 1m"}))
+
+		states, err := store.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, states, 1)
+		assert.False(t, states[0].Paused)
+		assert.Equal(t, "@every 1m", states[0].Spec)
+	}
+}
@@ -0,0 +1,113 @@
+package trigger
+
+import (
+	"context"
+	"time"
+)
+
+// MaxBatchSize is the maximum number of events in a batch, the oldest events
+// are dropped from a larger batch.
+const MaxBatchSize = 1000
+
+// Batcher debounces and coalesces events into batches.
+//
+// A batch is ready when no event is added for wait, or maxWait after its first
+// event, so a steady stream of events can not postpone the run forever. The
+// batches are run one by one: the events added while a batch is running are
+// coalesced into the next batch.
+type Batcher struct {
+	wait    time.Duration
+	maxWait time.Duration
+	run     func(events []Event)
+	events  chan Event
+	done    chan struct{}
+}
+
+// NewBatcher creates a Batcher which calls run for each batch. A zero wait
+// runs a batch as soon as the previous batch is completed, a zero maxWait
+// means no limit.
+func NewBatcher(wait, maxWait time.Duration, run func(events []Event)) *Batcher {
+	return &Batcher{
+		wait:    wait,
+		maxWait: maxWait,
+		run:     run,
+		events:  make(chan Event, 100),
+		done:    make(chan struct{}),
+	}
+}
+
+// Add adds an event to the next batch, it can be used as the Handler of a
+// trigger. The event is dropped once the Batcher is stopped.
+func (b *Batcher) Add(event Event) {
+	select {
+	case b.events <- event:
+	case <-b.done:
+	}
+}
+
+// Run runs the batches until ctx is done, it returns after the running batch
+// is completed.
+func (b *Batcher) Run(ctx context.Context) {
+	defer close(b.done)
+
+	var (
+		pending []Event
+		first   time.Time
+		ready   bool
+		running chan struct{}
+		timer   = time.NewTimer(time.Hour)
+	)
+	timer.Stop()
+	defer timer.Stop()
+
+	start := func() {
+		batch := pending
+		pending, ready = nil, false
+		running = make(chan struct{})
+		go func(done chan struct{}) {
+			defer close(done)
+			b.run(batch)
+		}(running)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if running != nil {
+				<-running
+			}
+			return
+		case event := <-b.events:
+			if len(pending) == 0 {
+				first = time.Now()
+			}
+			if len(pending) == MaxBatchSize {
+				pending = pending[1:]
+			}
+			pending = append(pending, event)
+			if ready {
+				continue
+			}
+
+			delay := b.wait
+			if b.maxWait > 0 {
+				delay = min(delay, b.maxWait-time.Since(first))
+			}
+			if delay <= 0 {
+				timer.Stop()
+				ready = true
+				break
+			}
+			timer.Reset(delay)
+			continue
+		case <-timer.C:
+			ready = true
+		case <-running:
+			running = nil
+		}
+
+		if ready && running == nil && len(pending) > 0 {
+			start()
+		}
+	}
+}
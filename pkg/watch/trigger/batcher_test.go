@@ -0,0 +1,109 @@
+package trigger
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the batches run by a Batcher.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]Event
+	block   chan struct{}
+}
+
+func (r *recorder) run(events []Event) {
+	r.mu.Lock()
+	r.batches = append(r.batches, events)
+	block := r.block
+	r.mu.Unlock()
+
+	if block != nil {
+		<-block
+	}
+}
+
+func (r *recorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sizes := make([]int, 0, len(r.batches))
+	for _, batch := range r.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func startBatcher(t *testing.T, b *Batcher) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func event(i int) Event {
+	return Event{Source: SourceRedis, Key: strconv.Itoa(i), Time: time.Now()}
+}
+
+func TestBatcherDebounce(t *testing.T) {
+	r := &recorder{}
+	b := NewBatcher(50*time.Millisecond, 0, r.run)
+	startBatcher(t, b)
+
+	for i := range 5 {
+		b.Add(event(i))
+	}
+	assert.Eventually(t, func() bool { return len(r.sizes()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{5}, r.sizes())
+}
+
+func TestBatcherMaxWait(t *testing.T) {
+	r := &recorder{}
+	b := NewBatcher(time.Hour, 50*time.Millisecond, r.run)
+	startBatcher(t, b)
+
+	b.Add(event(0))
+	assert.Eventually(t, func() bool { return len(r.sizes()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestBatcherCoalesce(t *testing.T) {
+	r := &recorder{block: make(chan struct{})}
+	b := NewBatcher(0, 0, r.run)
+	startBatcher(t, b)
+
+	b.Add(event(0))
+	require.Eventually(t, func() bool { return len(r.sizes()) == 1 }, time.Second, 10*time.Millisecond)
+
+	// The events added while the first batch is running are coalesced.
+	for i := 1; i <= 3; i++ {
+		b.Add(event(i))
+	}
+	require.Eventually(t, func() bool { return len(b.events) == 0 }, time.Second, time.Millisecond)
+	r.mu.Lock()
+	close(r.block)
+	r.block = nil
+	r.mu.Unlock()
+
+	assert.Eventually(t, func() bool { return len(r.sizes()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{1, 3}, r.sizes())
+}
+
+func TestContext(t *testing.T) {
+	events := []Event{event(0)}
+	assert.Equal(t, events, FromContext(NewContext(context.Background(), events)))
+	assert.Empty(t, FromContext(context.Background()))
+}
@@ -0,0 +1,5 @@
+// Package trigger provides the sources which schedule the runs of watchers:
+// a cron spec, the messages of a Kafka topic or a Redis pub/sub channel. The
+// events of a trigger are debounced and coalesced by a Batcher, so a burst of
+// events, e.g. the change events of a busy table, results in a single run.
+package trigger // import "github.com/ydcloud-dy/publicPkg/pkg/watch/trigger"
@@ -0,0 +1,68 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+
+	"github.com/onexstack/onexstack/pkg/options"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaTrigger fires an event for each message of a Kafka topic.
+//
+// The messages are committed once they are fired, so a watcher should be
+// level-triggered: it reconciles the current state instead of relying on every
+// single message. Set ReaderOptions.GroupID to share the messages between the
+// replicas in per-watcher mode.
+type KafkaTrigger struct {
+	opts *options.KafkaOptions
+}
+
+// Kafka returns a trigger reading the topic of opts.
+func Kafka(opts *options.KafkaOptions) *KafkaTrigger {
+	return &KafkaTrigger{opts: opts}
+}
+
+// Start implements the Trigger interface.
+func (t *KafkaTrigger) Start(ctx context.Context, fire Handler) error {
+	dialer, err := t.opts.Dialer()
+	if err != nil {
+		return err
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:           t.opts.Brokers,
+		Topic:             t.opts.Topic,
+		Dialer:            dialer,
+		GroupID:           t.opts.ReaderOptions.GroupID,
+		Partition:         t.opts.ReaderOptions.Partition,
+		QueueCapacity:     t.opts.ReaderOptions.QueueCapacity,
+		MinBytes:          t.opts.ReaderOptions.MinBytes,
+		MaxBytes:          t.opts.ReaderOptions.MaxBytes,
+		MaxWait:           t.opts.ReaderOptions.MaxWait,
+		ReadBatchTimeout:  t.opts.ReaderOptions.ReadBatchTimeout,
+		HeartbeatInterval: t.opts.ReaderOptions.HeartbeatInterval,
+		CommitInterval:    t.opts.ReaderOptions.CommitInterval,
+		RebalanceTimeout:  t.opts.ReaderOptions.RebalanceTimeout,
+		StartOffset:       t.opts.ReaderOptions.StartOffset,
+		MaxAttempts:       t.opts.ReaderOptions.MaxAttempts,
+	})
+	defer reader.Close()
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				return nil
+			}
+			return err
+		}
+
+		fire(Event{Source: SourceKafka, Key: string(msg.Key), Payload: msg.Value, Time: msg.Time})
+	}
+}
+
+// String implements the Trigger interface.
+func (t *KafkaTrigger) String() string {
+	return "kafka://" + t.opts.Topic
+}
@@ -0,0 +1,71 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTrigger fires an event for each message published on Redis channels.
+//
+// Pub/sub messages are delivered to every subscriber and are lost while no
+// replica is subscribed, so a watcher should be level-triggered and usually
+// also keeps a cron spec as a fallback.
+type RedisTrigger struct {
+	client   redis.UniversalClient
+	channels []string
+}
+
+// Redis returns a trigger subscribing the channels, a channel containing `*`
+// is subscribed as a pattern.
+func Redis(client redis.UniversalClient, channels ...string) *RedisTrigger {
+	return &RedisTrigger{client: client, channels: channels}
+}
+
+// Start implements the Trigger interface.
+func (t *RedisTrigger) Start(ctx context.Context, fire Handler) error {
+	var channels, patterns []string
+	for _, channel := range t.channels {
+		if strings.Contains(channel, "*") {
+			patterns = append(patterns, channel)
+		} else {
+			channels = append(channels, channel)
+		}
+	}
+
+	pubsub := t.client.Subscribe(ctx, channels...)
+	defer pubsub.Close()
+	if len(patterns) > 0 {
+		if err := pubsub.PSubscribe(ctx, patterns...); err != nil {
+			return err
+		}
+	}
+	// Wait for the confirmation, so the errors are reported to the caller.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("redis subscription is closed")
+			}
+			fire(Event{Source: SourceRedis, Key: msg.Channel, Payload: []byte(msg.Payload), Time: time.Now()})
+		}
+	}
+}
+
+// String implements the Trigger interface.
+func (t *RedisTrigger) String() string {
+	return "redis://" + strings.Join(t.channels, ",")
+}
@@ -0,0 +1,87 @@
+package trigger
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Sources of the events.
+const (
+	SourceCron  = "cron"
+	SourceKafka = "kafka"
+	SourceRedis = "redis"
+)
+
+// Event is a notification which asks the watcher to run, e.g. a Kafka message.
+type Event struct {
+	Source string `json:"source"`
+	// Key is the key of the Kafka message or the channel of the Redis message.
+	Key     string    `json:"key,omitempty"`
+	Payload []byte    `json:"payload,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Handler handles the events of a trigger, it must not block for long.
+type Handler func(event Event)
+
+// Trigger is the source of the runs of a watcher.
+type Trigger interface {
+	// Start calls fire for each event, it blocks until ctx is done or the
+	// trigger fails.
+	Start(ctx context.Context, fire Handler) error
+	// String describes the trigger, e.g. `kafka://topic`.
+	String() string
+}
+
+// CronTrigger fires events on a cron spec. A watcher with a CronTrigger is
+// scheduled by the cron of the watch server the same as a watcher with a spec.
+type CronTrigger struct {
+	spec string
+}
+
+// Cron returns a trigger firing events on the cron spec, the spec supports the
+// second field, see cron.WithSeconds.
+func Cron(spec string) *CronTrigger {
+	return &CronTrigger{spec: spec}
+}
+
+// Spec returns the cron spec of the trigger.
+func (t *CronTrigger) Spec() string {
+	return t.spec
+}
+
+// Start implements the Trigger interface.
+func (t *CronTrigger) Start(ctx context.Context, fire Handler) error {
+	c := cron.New(cron.WithSeconds())
+	if _, err := c.AddFunc(t.spec, func() {
+		fire(Event{Source: SourceCron, Time: time.Now()})
+	}); err != nil {
+		return err
+	}
+
+	c.Start()
+	<-ctx.Done()
+	<-c.Stop().Done()
+	return nil
+}
+
+// String implements the Trigger interface.
+func (t *CronTrigger) String() string {
+	return t.spec
+}
+
+type eventsKey struct{}
+
+// NewContext returns a copy of ctx carrying the events of the run.
+func NewContext(ctx context.Context, events []Event) context.Context {
+	return context.WithValue(ctx, eventsKey{}, events)
+}
+
+// FromContext returns the events which triggered the run, it is empty for the
+// runs scheduled by the cron or triggered manually.
+func FromContext(ctx context.Context) []Event {
+	events, _ := ctx.Value(eventsKey{}).([]Event)
+	return events
+}
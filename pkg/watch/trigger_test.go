@@ -0,0 +1,106 @@
+package watch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/trigger"
+)
+
+// chanTrigger is a trigger.Trigger firing the events sent to the channel.
+type chanTrigger chan trigger.Event
+
+func (t chanTrigger) Start(ctx context.Context, fire trigger.Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-t:
+			fire(event)
+		}
+	}
+}
+
+func (t chanTrigger) String() string { return "chan" }
+
+// eventWatcher records the events of its runs.
+type eventWatcher struct {
+	source chanTrigger
+
+	mu      sync.Mutex
+	batches [][]trigger.Event
+}
+
+func (w *eventWatcher) Run(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.batches = append(w.batches, trigger.FromContext(ctx))
+	return nil
+}
+
+func (w *eventWatcher) Trigger() trigger.Trigger { return w.source }
+
+func (w *eventWatcher) Debounce() registry.DebouncePolicy {
+	return registry.DebouncePolicy{Wait: 50 * time.Millisecond}
+}
+
+func (w *eventWatcher) runs() [][]trigger.Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([][]trigger.Event(nil), w.batches...)
+}
+
+// cronWatcher is scheduled by a trigger.CronTrigger.
+type cronWatcher struct{}
+
+func (cronWatcher) Run(ctx context.Context) error { return nil }
+
+func (cronWatcher) Trigger() trigger.Trigger { return trigger.Cron("@every 1m") }
+
+func TestEventWatcher(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWatch(state.NewMemoryStore())
+
+	watcher := &eventWatcher{source: make(chanTrigger)}
+	require.NoError(t, w.addWatcher("events", watcher))
+	require.NoError(t, w.addWatcher("cron", cronWatcher{}))
+	assert.False(t, w.jm.JobExists("events"))
+	assert.True(t, w.jm.JobExists("cron"))
+	assert.Equal(t, "@every 1m", w.jobs["cron"].spec)
+	assert.ErrorIs(t, w.Reschedule(ctx, "events", "@every 1m"), ErrEventDriven)
+
+	w.startJobs(ctx)
+	defer w.stopJobs()
+
+	// A burst of events is debounced into a single run.
+	for _, key := range []string{"a", "b", "c"} {
+		watcher.source <- trigger.Event{Source: "chan", Key: key, Time: time.Now()}
+	}
+	require.Eventually(t, func() bool { return len(watcher.runs()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Len(t, watcher.runs()[0], 3)
+	assert.Equal(t, "succeeded", lastRun(t, w, "events").Outcome)
+
+	status, err := w.jobStatus(ctx, "events")
+	require.NoError(t, err)
+	assert.Equal(t, "chan", status.Trigger)
+
+	// A manual run has no events.
+	w.leader.Store(true)
+	require.NoError(t, w.Trigger(ctx, "events"))
+	require.Eventually(t, func() bool { return len(watcher.runs()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, watcher.runs()[1])
+
+	// The events of a paused watcher are dropped.
+	require.NoError(t, w.Pause(ctx, "events"))
+	watcher.source <- trigger.Event{Source: "chan", Time: time.Now()}
+	assert.Never(t, func() bool { return len(watcher.runs()) > 2 }, 200*time.Millisecond, 10*time.Millisecond)
+}
@@ -1,22 +1,34 @@
 package watch
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/wait"
 
-	"github.com/onexstack/onexstack/pkg/distlock"
 	stringsutil "github.com/onexstack/onexstack/pkg/util/strings"
-	"github.com/onexstack/onexstack/pkg/watch/initializer"
-	"github.com/onexstack/onexstack/pkg/watch/logger/empty"
-	"github.com/onexstack/onexstack/pkg/watch/manager"
-	"github.com/onexstack/onexstack/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/distlock"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/initializer"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/logger/empty"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/manager"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/pool"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/trigger"
 )
 
 var (
@@ -24,6 +36,12 @@ var (
 	jobStopTimeout = 3 * time.Minute
 	// Default expiration time for locks.
 	defaultExpiration = 10 * time.Second
+	// Maximum backoff between the retries of a context watcher.
+	maxRetryBackoff = time.Minute
+	// Interval to check whether this replica still holds the lock.
+	leadershipCheckInterval = defaultExpiration / 2
+	// Interval to restart a failed trigger.
+	triggerRestartInterval = 5 * time.Second
 )
 
 // Option configures a Watch instance with customizable settings.
@@ -43,12 +61,48 @@ type Watch struct {
 	lockName string
 	// Distributed lock instance.
 	locker distlock.Locker
+	// How watchers are distributed across replicas, ModeLeader or ModePerWatcher.
+	mode string
+	// Identifier of this replica, used as the owner of the locks.
+	ownerID string
+	// Function to create the lock of a watcher in per-watcher mode.
+	newLocker LockerFactory
+	// Store to record the runs of watchers.
+	history history.Store
+	// Store to persist the runtime states of watchers.
+	states state.Store
+	// Default timeout of each run of a context watcher, 0 means no timeout.
+	jobTimeout time.Duration
+	// Default retry policy of context watchers.
+	retryPolicy registry.RetryPolicy
+	// Registry of the Prometheus metrics served on `/metrics`.
+	registry *prometheus.Registry
+	// Metrics of the watchers.
+	metrics *metrics
+	// leader reports whether this replica holds the distributed lock in leader mode.
+	leader atomic.Bool
+	// Protects jobs, their specs and the run context.
+	mu sync.RWMutex
+	// Serializes the changes of the watcher states, from reading the current
+	// state to applying the saved one.
+	stateMu sync.Mutex
+	// Watchers added to the cron or run by triggers.
+	jobs map[string]*job
+	// Tracks the running triggers of event-driven watchers.
+	triggers sync.WaitGroup
+	// Workflows added to the cron, they are also in jobs.
+	workflows map[string]*workflowTask
+	// Context of the runs, cancelled when the watch server is stopped or the leadership is lost.
+	runCtx    context.Context
+	cancelRun context.CancelFunc
 	// healthzPort is the port number for the health check endpoint.
 	healthzPort int
+	// Authentication middleware of the control routes, they are not served when it is nil.
+	controlAuth func(http.Handler) http.Handler
 	// List of watcher names that should be disabled.
 	disableWatchers []string
 	// Function for internal initialization of watchers.
-	initializer initializer.WatcherInitializer
+	initializer initializer.PoolInitializer
 	// Function for external initialization of watchers.
 	externalInitializer initializer.WatcherInitializer
 }
@@ -68,18 +122,85 @@ func WithLogger(logger Logger) Option {
 	}
 }
 
+// WithLockerFactory returns an Option function that sets the function used to
+// create the lock of each watcher in per-watcher mode. By default a GORM lock
+// named `<lock-name>/<watcher>` is used.
+func WithLockerFactory(factory LockerFactory) Option {
+	return func(w *Watch) {
+		w.newLocker = factory
+	}
+}
+
+// WithHistoryStore returns an Option function that sets the store used to
+// record the runs of watchers. By default the latest runs are kept in memory.
+func WithHistoryStore(store history.Store) Option {
+	return func(w *Watch) {
+		w.history = store
+	}
+}
+
+// WithStateStore returns an Option function that sets the store used to persist
+// the runtime states of watchers, e.g. paused watchers. By default the states
+// are stored in the database of the distributed lock.
+func WithStateStore(store state.Store) Option {
+	return func(w *Watch) {
+		w.states = store
+	}
+}
+
+// WithMetricsRegistry returns an Option function that sets the Prometheus
+// registry the metrics of watchers are registered to. By default a new
+// registry is created, and it is served on `/metrics` of the health check server.
+func WithMetricsRegistry(registry *prometheus.Registry) Option {
+	return func(w *Watch) {
+		w.registry = registry
+	}
+}
+
+// WithControlAPI returns an Option function that serves the routes which
+// pause, resume, trigger and reschedule watchers on the health check server.
+// The routes are wrapped in auth, which must reject unauthenticated requests
+// since the server listens on every interface. The routes are not served by
+// default.
+func WithControlAPI(auth func(http.Handler) http.Handler) Option {
+	return func(w *Watch) {
+		w.controlAuth = auth
+	}
+}
+
 // NewWatch creates a new Watch monitoring system with the provided options.
 func NewWatch(opts *Options, db *gorm.DB, withOptions ...Option) (*Watch, error) {
 	logger := empty.NewLogger()
+	hostname, _ := os.Hostname()
 
 	// Create a new Watch with default settings.
 	w := &Watch{
 		lockName:        opts.LockName,
+		mode:            opts.Mode,
+		ownerID:         hostname + "-" + rand.String(8),
 		healthzPort:     opts.HealthzPort,
 		logger:          logger,
 		disableWatchers: opts.DisableWatchers,
 		db:              db,
 		maxWorkers:      opts.MaxWorkers,
+		history:         history.NewMemoryStore(opts.HistorySize),
+		jobs:            make(map[string]*job),
+		workflows:       make(map[string]*workflowTask),
+		jobTimeout:      opts.JobTimeout,
+		retryPolicy: registry.RetryPolicy{
+			MaxRetries: opts.MaxRetries,
+			Backoff:    opts.RetryBackoff,
+			MaxBackoff: maxRetryBackoff,
+		},
+	}
+
+	w.newLocker = func(name string) (distlock.Locker, error) {
+		return distlock.NewGORMLocker(
+			w.db,
+			distlock.WithLockName(w.lockName+"/"+name),
+			distlock.WithLockTimeout(defaultExpiration),
+			distlock.WithOwnerID(w.ownerID),
+		)
 	}
 
 	// Apply user-defined options to the Watch.
@@ -87,15 +208,45 @@ func NewWatch(opts *Options, db *gorm.DB, withOptions ...Option) (*Watch, error)
 		opt(w)
 	}
 
+	if w.registry == nil {
+		w.registry = prometheus.NewRegistry()
+	}
+	m, err := newMetrics(w.registry, func() map[string]*pool.Pool { return w.initializer.Pools() })
+	if err != nil {
+		return nil, err
+	}
+	w.metrics = m
+
+	if w.states == nil {
+		if db == nil {
+			w.states = state.NewMemoryStore()
+		} else {
+			store, err := state.NewGORMStore(db)
+			if err != nil {
+				return nil, err
+			}
+			w.states = store
+		}
+	}
+
+	// Runs are delayed while the previous run of the watcher is still running by
+	// the job itself, which counts the delayed runs, see job.run.
 	runner := cron.New(
 		cron.WithSeconds(),
 		cron.WithLogger(w.logger),
-		cron.WithChain(cron.DelayIfStillRunning(w.logger), cron.Recover(w.logger)),
+		cron.WithChain(cron.Recover(w.logger)),
 	)
 
 	// Initialize the job manager and the watcher initializer.
 	w.jm = manager.NewJobManager(manager.WithCron(runner))
-	w.initializer = initializer.NewInitializer(w.jm, w.maxWorkers)
+	w.initializer = initializer.NewInitializer(
+		w.jm,
+		w.maxWorkers,
+		initializer.WithPoolOptions(pool.Options{QueueSize: opts.PoolQueueSize, Rate: opts.PoolRate, Burst: opts.PoolBurst}),
+		initializer.WithPoolPanicHandler(func(name string, err error) {
+			w.logger.Error(err, "Recovered from the panic of a pool task", "watcher", name)
+		}),
+	)
 
 	if err := w.addWatchers(); err != nil {
 		return nil, err
@@ -106,74 +257,356 @@ func NewWatch(opts *Options, db *gorm.DB, withOptions ...Option) (*Watch, error)
 
 // addWatchers initializes all registered watchers and adds them as Cron jobs.
 // It skips the watchers that are specified in the disableWatchers slice.
+// The steps of workflows are added by addWorkflow instead.
 func (w *Watch) addWatchers() error {
-	for jobName, watcher := range registry.ListWatchers() {
+	// The registry.Watcher and registry.ContextWatcher by name.
+	watchers := make(map[string]any)
+	for name, watcher := range registry.ListWatchers() {
+		watchers[name] = watcher
+	}
+	for name, watcher := range registry.ListContextWatchers() {
+		watchers[name] = watcher
+	}
+	workflows := registry.ListWorkflows()
+
+	steps := make(map[string]bool)
+	for _, wf := range workflows {
+		for _, step := range wf.Steps {
+			steps[step] = true
+		}
+	}
+
+	for _, jobName := range slices.Sorted(maps.Keys(watchers)) {
 		if stringsutil.StringIn(jobName, w.disableWatchers) {
 			continue
 		}
 
-		w.initializer.Initialize(watcher)
+		watcher := watchers[jobName]
+		w.initialize(jobName, watcher)
+
+		if steps[jobName] {
+			continue
+		}
+		if _, ok := watcher.(registry.IDependsOn); ok {
+			w.logger.Info("Dependencies are only applied to the steps of workflows", "watcher", jobName)
+		}
+
+		if err := w.addWatcher(jobName, watcher); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(workflows)) {
+		if stringsutil.StringIn(name, w.disableWatchers) {
+			continue
+		}
+
+		if err := w.addWorkflow(name, workflows[name], watchers); err != nil {
+			w.logger.Error(err, "Failed to add workflow", "workflow", name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// initialize sets the resources wanted by a registry.Watcher or a
+// registry.ContextWatcher.
+func (w *Watch) initialize(jobName string, watcher any) {
+	switch typed := watcher.(type) {
+	case registry.ContextWatcher:
+		w.initializer.InitializeNamedContext(jobName, typed)
+		if obj, ok := w.externalInitializer.(initializer.ContextWatcherInitializer); ok {
+			obj.InitializeContext(typed)
+		}
+	case registry.Watcher:
+		w.initializer.InitializeNamed(jobName, typed)
 		if w.externalInitializer != nil {
-			w.externalInitializer.Initialize(watcher)
+			w.externalInitializer.Initialize(typed)
 		}
+	}
+}
 
-		spec := registry.Every3Seconds
-		if obj, ok := watcher.(registry.ISpec); ok {
-			spec = obj.Spec()
+// addWatcher adds an initialized registry.Watcher or registry.ContextWatcher
+// as a Cron job. A watcher with a trigger other than trigger.CronTrigger is
+// run by the events of the trigger once the jobs are started instead.
+func (w *Watch) addWatcher(jobName string, watcher any) error {
+	spec := registry.Every3Seconds
+	if obj, ok := watcher.(registry.ISpec); ok {
+		spec = obj.Spec()
+	}
+
+	var source trigger.Trigger
+	if obj, ok := watcher.(registry.ITrigger); ok {
+		source = obj.Trigger()
+		if c, ok := source.(*trigger.CronTrigger); ok {
+			spec, source = c.Spec(), nil
 		}
+	}
+	if source != nil {
+		spec = ""
+	}
 
-		if _, err := w.jm.AddJob(jobName, spec, watcher); err != nil {
+	var debounce registry.DebouncePolicy
+	if obj, ok := watcher.(registry.IDebounce); ok {
+		debounce = obj.Debounce()
+	}
+
+	t, err := w.newTask(watcher)
+	if err != nil {
+		w.logger.Error(err, "Failed to add job to the cron", "watcher", jobName)
+		return err
+	}
+
+	return w.addJob(jobName, spec, source, debounce, t)
+}
+
+// addJob adds the task of a watcher or a workflow as a Cron job, or runs it
+// by the events of source when source is not nil.
+func (w *Watch) addJob(jobName, spec string, source trigger.Trigger, debounce registry.DebouncePolicy, t task) error {
+	j := &job{
+		name:        jobName,
+		defaultSpec: spec,
+		spec:        spec,
+		source:      source,
+		debounce:    debounce,
+		metrics:     w.metrics,
+		logger:      w.logger,
+	}
+	if source == nil {
+		j.scheduled = func() time.Time {
+			entry, _ := w.jm.GetEntry(jobName)
+			return entry.Prev
+		}
+	}
+	j.next = &recordedJob{
+		name: jobName,
+		task: t,
+		// The events are set by job.run before the run.
+		ctx:     func() context.Context { return trigger.NewContext(w.runContext(), j.events) },
+		replica: w.ownerID,
+		store:   w.history,
+		metrics: w.metrics,
+		logger:  w.logger,
+	}
+	if w.mode == ModePerWatcher {
+		locked, err := newLockedJob(jobName, spec, j.next, w.newLocker, w.logger)
+		if err != nil {
+			w.logger.Error(err, "Failed to create the lock of watcher", "watcher", jobName)
+			return err
+		}
+		locked.metrics = w.metrics
+		j.locked = locked
+		j.next = locked
+	}
+
+	if source == nil {
+		if _, err := w.jm.AddJob(jobName, spec, j); err != nil {
 			w.logger.Error(err, "Failed to add job to the cron", "watcher", jobName)
 			return err
 		}
 	}
 
+	w.mu.Lock()
+	w.jobs[jobName] = j
+	w.mu.Unlock()
+
 	return nil
 }
 
 // Start attempts to acquire a distributed lock and starts the Cron job scheduler.
-// It retries acquiring the lock until successful.
+// It retries acquiring the lock until successful. When the lock is lost later,
+// the running watchers are cancelled and the lock is acquired again. In
+// per-watcher mode the Cron job scheduler is started immediately and every run
+// takes the lock of its watcher.
 func (w *Watch) Start(stopCh <-chan struct{}) {
 	if w.healthzPort != 0 {
 		go w.serveHealthz()
 	}
 
+	ctx := wait.ContextForChannel(stopCh)
+	if w.mode == ModePerWatcher {
+		w.startJobs(ctx)
+		w.logger.Info("Successfully started watch server", "mode", w.mode)
+		return
+	}
+
 	opts := []distlock.Option{
 		distlock.WithLockTimeout(defaultExpiration),
 		distlock.WithLockName(w.lockName),
+		distlock.WithOwnerID(w.ownerID),
 	}
 	w.locker, _ = distlock.NewGORMLocker(w.db, opts...)
+	if !w.acquireLock(ctx) {
+		return
+	}
+
+	w.setLeader(true)
+	w.startJobs(ctx)
+	go w.keepLeadership(ctx)
+
+	w.logger.Info("Successfully started watch server")
+}
+
+// acquireLock retries acquiring the distributed lock until successful or ctx is done.
+func (w *Watch) acquireLock(ctx context.Context) bool {
 	ticker := time.NewTicker(defaultExpiration + (5 * time.Second))
-	ctx := wait.ContextForChannel(stopCh)
-	var err error
+	defer ticker.Stop()
+
 	for {
 		// Obtain a lock for our given mutex. After this is successful, no one else
 		// can obtain the same lock (the same mutex name) until we unlock it.
-		if err := w.locker.Lock(ctx); err == nil {
+		err := w.locker.Lock(ctx)
+		if err == nil {
 			w.logger.Debug("Successfully acquired lock", "lockName", w.lockName)
-			break
+			return true
 		}
 
 		w.logger.Debug("Failed to acquire lock.", "lockName", w.lockName, "err", err)
-		<-ticker.C
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
 	}
+}
+
+// keepLeadership checks the distributed lock periodically. When the lock is
+// lost, the jobs are stopped until the lock is acquired again.
+func (w *Watch) keepLeadership(ctx context.Context) {
+	ticker := time.NewTicker(leadershipCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := w.locker.Renew(ctx); err == nil || ctx.Err() != nil {
+			continue
+		}
+
+		w.logger.Error(errors.New("lost the leadership"), "Stop running watchers", "lockName", w.lockName)
+		w.setLeader(false)
+		w.stopJobs()
+		if !w.acquireLock(ctx) {
+			return
+		}
+		w.setLeader(true)
+		w.startJobs(ctx)
+	}
+}
+
+// setLeader records whether this replica holds the distributed lock in leader mode.
+func (w *Watch) setLeader(leader bool) {
+	w.leader.Store(leader)
+	w.metrics.setLeader(leader)
+}
+
+// startJobs applies the persisted states of watchers, e.g. the changes made
+// on the previous leader, and starts the Cron job scheduler.
+func (w *Watch) startJobs(ctx context.Context) {
+	runCtx, cancel := context.WithCancel(ctx)
+	w.mu.Lock()
+	w.runCtx, w.cancelRun = runCtx, cancel
+	w.mu.Unlock()
+
+	if err := w.syncStates(runCtx); err != nil {
+		w.logger.Error(err, "Failed to load the states of watchers")
+	}
+	go w.watchStates(runCtx)
 
 	w.jm.Start()
 
-	w.logger.Info("Successfully started watch server")
+	w.mu.RLock()
+	for _, j := range w.jobs {
+		if j.source != nil {
+			w.triggers.Add(1)
+			go w.runTrigger(runCtx, j)
+		}
+	}
+	w.mu.RUnlock()
 }
 
-// Stop blocks until all jobs are completed and releases the distributed lock.
-func (w *Watch) Stop() {
+// runTrigger runs an event-driven watcher on the events of its trigger until
+// ctx is done. The trigger is restarted when it fails.
+func (w *Watch) runTrigger(ctx context.Context, j *job) {
+	defer w.triggers.Done()
+
+	batcher := trigger.NewBatcher(j.debounce.Wait, j.debounce.MaxWait, j.fire)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		batcher.Run(ctx)
+	}()
+
+	for ctx.Err() == nil {
+		err := j.source.Start(ctx, batcher.Add)
+		if ctx.Err() != nil {
+			break
+		}
+		if err == nil {
+			err = errors.New("trigger stopped")
+		}
+		w.logger.Error(err, "Failed to watch the trigger of watcher", "watcher", j.name, "trigger", j.source.String())
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(triggerRestartInterval):
+		}
+	}
+
+	<-done
+}
+
+// stopJobs cancels the running watchers and stops the Cron job scheduler, it
+// blocks until all jobs are completed or jobStopTimeout is reached.
+func (w *Watch) stopJobs() context.Context {
+	w.mu.RLock()
+	if w.cancelRun != nil {
+		w.cancelRun()
+	}
+	w.mu.RUnlock()
+
 	ctx := w.jm.Stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		w.triggers.Wait()
+	}()
+
 	select {
-	case <-ctx.Done():
+	case <-done:
 	case <-time.After(jobStopTimeout):
 		w.logger.Error(errors.New("context was not done immediately"), "timeout", jobStopTimeout.String())
 	}
+	return ctx
+}
 
-	if err := w.locker.Unlock(ctx); err != nil {
-		w.logger.Debug("Failed to release lock", "err", err)
+// Stop cancels the running watchers, blocks until all jobs and the queued
+// tasks of their worker pools are completed and releases the distributed lock.
+func (w *Watch) Stop() {
+	w.setLeader(false)
+	ctx := w.stopJobs()
+	if w.initializer != nil {
+		w.initializer.Close()
+	}
+
+	w.mu.RLock()
+	for _, j := range w.jobs {
+		if j.locked != nil {
+			j.locked.Unlock()
+		}
+	}
+	w.mu.RUnlock()
+
+	if w.locker != nil {
+		if err := w.locker.Unlock(ctx); err != nil {
+			w.logger.Debug("Failed to release lock", "err", err)
+		}
 	}
 
 	w.logger.Info("Successfully stopped watch server")
@@ -181,21 +614,47 @@ func (w *Watch) Stop() {
 
 // serveHealthz starts the health check server for the Watch instance.
 func (w *Watch) serveHealthz() {
-	r := mux.NewRouter()
-	r.HandleFunc("/healthz", healthzHandler).Methods(http.MethodGet)
-
 	address := fmt.Sprintf("0.0.0.0:%d", w.healthzPort)
 
-	if err := http.ListenAndServe(address, r); err != nil {
+	if err := http.ListenAndServe(address, w.router()); err != nil {
 		w.logger.Error(err, "Error serving health check endpoint")
 	}
 
 	w.logger.Info("Successfully started health check server", "address", address)
 }
 
-// healthzHandler handles the health check requests for the service.
-func healthzHandler(rw http.ResponseWriter, r *http.Request) {
+// router returns the routes of the health check server. The control routes
+// are only served when WithControlAPI is used.
+func (w *Watch) router() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/healthz", w.healthzHandler).Methods(http.MethodGet)
+	r.HandleFunc("/metrics", w.metricsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/jobs", w.jobsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/jobs/{name}/runs", w.runsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/workflows", w.workflowsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/workflows/{name}", w.workflowHandler).Methods(http.MethodGet)
+
+	if w.controlAuth != nil {
+		r.Handle("/jobs/{name}/pause", w.controlAuth(w.controlHandler(w.Pause))).Methods(http.MethodPost)
+		r.Handle("/jobs/{name}/resume", w.controlAuth(w.controlHandler(w.Resume))).Methods(http.MethodPost)
+		r.Handle("/jobs/{name}/trigger", w.controlAuth(w.controlHandler(w.Trigger))).Methods(http.MethodPost)
+		r.Handle("/jobs/{name}/schedule", w.controlAuth(http.HandlerFunc(w.scheduleHandler))).Methods(http.MethodPut)
+	}
+
+	return r
+}
+
+// healthzHandler handles the health check requests for the service. It fails
+// when this replica holds the distributed lock but the Cron job scheduler is
+// not running, so no watcher runs on any replica.
+func (w *Watch) healthzHandler(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Set("Content-type", "application/json")
+	if w.leader.Load() && !w.jm.Running() {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		rw.Write([]byte(`{"status": "unhealthy", "reason": "the lock is held but the cron is not running"}`))
+		return
+	}
+
 	rw.WriteHeader(http.StatusOK)
 	rw.Write([]byte(`{"status": "ok"}`))
 }
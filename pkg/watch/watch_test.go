@@ -0,0 +1,35 @@
+package watch
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/history"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/logger/empty"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/manager"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+)
+
+// newTestWatch creates a Watch without registered watchers, watchers are added with addWatcher.
+func newTestWatch(states state.Store) *Watch {
+	logger := empty.NewLogger()
+	runner := cron.New(
+		cron.WithSeconds(),
+		cron.WithLogger(logger),
+		cron.WithChain(cron.Recover(logger)),
+	)
+	registry := prometheus.NewRegistry()
+	m, _ := newMetrics(registry, nil)
+
+	return &Watch{
+		jm:        manager.NewJobManager(manager.WithCron(runner)),
+		logger:    logger,
+		mode:      ModeLeader,
+		history:   history.NewMemoryStore(10),
+		states:    states,
+		jobs:      make(map[string]*job),
+		workflows: make(map[string]*workflowTask),
+		registry:  registry,
+		metrics:   m,
+	}
+}
@@ -0,0 +1,159 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+
+	stringsutil "github.com/onexstack/onexstack/pkg/util/strings"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/manager"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/workflow"
+)
+
+// workflowTask runs a workflow as the task of a job, so a workflow is
+// scheduled, locked, paused and recorded the same as a watcher.
+type workflowTask struct {
+	dag *workflow.DAG
+	// last is the running or the last run of the workflow on this replica.
+	last atomic.Pointer[workflow.Run]
+}
+
+func (t *workflowTask) run(ctx context.Context) (int, error) {
+	run := t.dag.NewRun()
+	t.last.Store(run)
+	return 1, t.dag.Run(ctx, run)
+}
+
+// addWorkflow adds a registered workflow as a Cron job, its steps are the
+// initialized watchers. The watchers are registry.Watcher or registry.ContextWatcher.
+func (w *Watch) addWorkflow(name string, wf registry.Workflow, watchers map[string]any) error {
+	steps := make([]workflow.Step, 0, len(wf.Steps))
+	for _, stepName := range wf.Steps {
+		if stringsutil.StringIn(stepName, w.disableWatchers) {
+			return fmt.Errorf("step %s is disabled", stepName)
+		}
+
+		watcher, ok := watchers[stepName]
+		if !ok {
+			return fmt.Errorf("%w %q", workflow.ErrUnknownStep, stepName)
+		}
+
+		t, err := w.newTask(watcher)
+		if err != nil {
+			return err
+		}
+
+		step := workflow.Step{
+			Name: stepName,
+			Run: func(ctx context.Context) error {
+				_, err := t.run(ctx)
+				return err
+			},
+		}
+		if obj, ok := watcher.(registry.IDependsOn); ok {
+			step.DependsOn = obj.DependsOn()
+		}
+		steps = append(steps, step)
+	}
+
+	dag, err := workflow.New(steps...)
+	if err != nil {
+		return err
+	}
+
+	spec := wf.Spec
+	if spec == "" {
+		spec = registry.Every3Seconds
+	}
+
+	t := &workflowTask{dag: dag}
+	if err := w.addJob(name, spec, nil, registry.DebouncePolicy{}, t); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.workflows[name] = t
+	w.mu.Unlock()
+
+	return nil
+}
+
+// workflowStep is a step of a workflow served on `/workflows`.
+type workflowStep struct {
+	Name      string   `json:"name"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// workflowStatus is the status of a workflow served on `/workflows`.
+type workflowStatus struct {
+	*jobStatus
+	// Steps are the steps of the workflow in a topological order.
+	Steps []workflowStep `json:"steps"`
+	// Run is the running or the last run of the workflow on this replica.
+	Run *workflow.RunState `json:"run,omitempty"`
+}
+
+// workflowStatus returns the status of a workflow.
+func (w *Watch) workflowStatus(ctx context.Context, name string) (*workflowStatus, error) {
+	w.mu.RLock()
+	t, ok := w.workflows[name]
+	w.mu.RUnlock()
+	if !ok {
+		return nil, &manager.JobNotFoundError{JobName: name}
+	}
+
+	js, err := w.jobStatus(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &workflowStatus{jobStatus: js}
+	for _, step := range t.dag.Order() {
+		status.Steps = append(status.Steps, workflowStep{Name: step, DependsOn: t.dag.DependsOn(step)})
+	}
+	if run := t.last.Load(); run != nil {
+		state := run.State()
+		status.Run = &state
+	}
+
+	return status, nil
+}
+
+// workflowsHandler lists the workflows with the state of their last run.
+func (w *Watch) workflowsHandler(rw http.ResponseWriter, r *http.Request) {
+	w.mu.RLock()
+	names := make([]string, 0, len(w.workflows))
+	for name := range w.workflows {
+		names = append(names, name)
+	}
+	w.mu.RUnlock()
+	sort.Strings(names)
+
+	workflows := make([]*workflowStatus, 0, len(names))
+	for _, name := range names {
+		status, err := w.workflowStatus(r.Context(), name)
+		if err != nil {
+			writeControlError(rw, err)
+			return
+		}
+		workflows = append(workflows, status)
+	}
+
+	writeJSON(rw, http.StatusOK, workflows)
+}
+
+// workflowHandler serves the status of a workflow and the states of its steps.
+func (w *Watch) workflowHandler(rw http.ResponseWriter, r *http.Request) {
+	status, err := w.workflowStatus(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		writeControlError(rw, err)
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, status)
+}
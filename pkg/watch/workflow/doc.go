@@ -0,0 +1,5 @@
+// Package workflow runs a DAG of steps, e.g. the watchers of a pipeline where
+// a watcher must run after the watchers it depends on succeeded. The output of
+// a step is passed to the steps depending on it, and the steps downstream of a
+// failed step are skipped.
+package workflow // import "github.com/ydcloud-dy/publicPkg/pkg/watch/workflow"
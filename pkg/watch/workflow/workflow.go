@@ -0,0 +1,339 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Statuses of a run and its steps.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	// StatusSkipped means the step did not run because a step it depends on failed.
+	StatusSkipped = "skipped"
+)
+
+var (
+	// ErrUnknownStep is returned when a step depends on a step which is not in the DAG.
+	ErrUnknownStep = errors.New("unknown step")
+	// ErrDuplicateStep is returned when two steps of a DAG have the same name.
+	ErrDuplicateStep = errors.New("duplicate step")
+	// ErrCycle is returned when the dependencies of the steps contain a cycle.
+	ErrCycle = errors.New("dependency cycle")
+)
+
+// Step is a node of the DAG.
+type Step struct {
+	Name string
+	// DependsOn are the names of the steps which must succeed before the step runs.
+	DependsOn []string
+	// Run runs the step, it can read the outputs of its dependencies by Input
+	// and set its own output by SetOutput.
+	Run func(ctx context.Context) error
+}
+
+// DAG is a validated set of steps.
+type DAG struct {
+	steps map[string]*Step
+	// order is a topological order of the steps.
+	order []string
+	// dependents are the steps depending on each step.
+	dependents map[string][]string
+}
+
+// New validates the steps and creates a DAG of them.
+func New(steps ...Step) (*DAG, error) {
+	d := &DAG{
+		steps:      make(map[string]*Step, len(steps)),
+		dependents: make(map[string][]string, len(steps)),
+	}
+
+	for i := range steps {
+		step := &steps[i]
+		if _, ok := d.steps[step.Name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateStep, step.Name)
+		}
+		d.steps[step.Name] = step
+	}
+
+	indegree := make(map[string]int, len(steps))
+	for _, step := range steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := d.steps[dep]; !ok {
+				return nil, fmt.Errorf("%w %q required by %q", ErrUnknownStep, dep, step.Name)
+			}
+			d.dependents[dep] = append(d.dependents[dep], step.Name)
+		}
+		indegree[step.Name] = len(step.DependsOn)
+	}
+
+	// Kahn's algorithm, the steps are visited in the registration order.
+	var queue []string
+	for _, step := range steps {
+		if indegree[step.Name] == 0 {
+			queue = append(queue, step.Name)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		d.order = append(d.order, name)
+		for _, dependent := range d.dependents[name] {
+			if indegree[dependent]--; indegree[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	if len(d.order) != len(steps) {
+		var cyclic []string
+		for _, step := range steps {
+			if indegree[step.Name] > 0 {
+				cyclic = append(cyclic, step.Name)
+			}
+		}
+		return nil, fmt.Errorf("%w between %v", ErrCycle, cyclic)
+	}
+
+	return d, nil
+}
+
+// Order returns the names of the steps in a topological order.
+func (d *DAG) Order() []string {
+	return slices.Clone(d.order)
+}
+
+// DependsOn returns the dependencies of a step.
+func (d *DAG) DependsOn(name string) []string {
+	if step, ok := d.steps[name]; ok {
+		return slices.Clone(step.DependsOn)
+	}
+	return nil
+}
+
+// NewRun creates the state of a run of the DAG with all the steps pending.
+func (d *DAG) NewRun() *Run {
+	r := &Run{state: RunState{Status: StatusPending, Steps: make([]StepState, 0, len(d.order))}, index: make(map[string]int, len(d.order))}
+	for i, name := range d.order {
+		r.state.Steps = append(r.state.Steps, StepState{Name: name, DependsOn: d.DependsOn(name), Status: StatusPending})
+		r.index[name] = i
+	}
+	return r
+}
+
+type result struct {
+	name   string
+	output any
+	err    error
+}
+
+// Run runs the steps of the DAG and records their states in run. A step starts
+// as soon as all its dependencies succeeded, so independent steps run
+// concurrently. When a step fails, the steps downstream of it are skipped and
+// the other steps still run. It returns the errors of the failed steps.
+func (d *DAG) Run(ctx context.Context, run *Run) error {
+	run.start()
+
+	var (
+		remaining = make(map[string]int, len(d.steps))
+		outputs   = make(map[string]any, len(d.steps))
+		results   = make(chan result)
+		active    int
+		errs      []error
+	)
+	for name, step := range d.steps {
+		remaining[name] = len(step.DependsOn)
+	}
+
+	launch := func(name string) {
+		step := d.steps[name]
+		inputs := make(map[string]any, len(step.DependsOn))
+		for _, dep := range step.DependsOn {
+			inputs[dep] = outputs[dep]
+		}
+
+		active++
+		run.setStatus(name, StatusRunning, nil)
+		go func() {
+			output, err := runStep(ctx, step, inputs)
+			results <- result{name: name, output: output, err: err}
+		}()
+	}
+
+	for _, name := range d.order {
+		if remaining[name] == 0 {
+			launch(name)
+		}
+	}
+
+	for active > 0 {
+		r := <-results
+		active--
+
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("step %s: %w", r.name, r.err))
+			run.setStatus(r.name, StatusFailed, r.err)
+			d.skipDownstream(run, r.name)
+			continue
+		}
+
+		outputs[r.name] = r.output
+		run.setStatus(r.name, StatusSucceeded, nil)
+		for _, dependent := range d.dependents[r.name] {
+			if remaining[dependent]--; remaining[dependent] == 0 && run.status(dependent) == StatusPending {
+				launch(dependent)
+			}
+		}
+	}
+
+	err := errors.Join(errs...)
+	run.finish(err)
+	return err
+}
+
+// skipDownstream marks the pending steps depending on the failed step as skipped.
+func (d *DAG) skipDownstream(run *Run, failed string) {
+	queue := slices.Clone(d.dependents[failed])
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if run.status(name) != StatusPending {
+			continue
+		}
+		run.setStatus(name, StatusSkipped, nil)
+		queue = append(queue, d.dependents[name]...)
+	}
+}
+
+// runStep runs a step, a panic of the step is returned as an error.
+func runStep(ctx context.Context, step *Step, inputs map[string]any) (output any, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	sc := &stepContext{inputs: inputs}
+	err = step.Run(context.WithValue(ctx, stepKey{}, sc))
+	return sc.output, err
+}
+
+// StepState is the state of a step in a run.
+type StepState struct {
+	Name       string     `json:"name"`
+	DependsOn  []string   `json:"dependsOn,omitempty"`
+	Status     string     `json:"status"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// RunState is a snapshot of a run of a DAG.
+type RunState struct {
+	Status     string      `json:"status"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Steps      []StepState `json:"steps"`
+}
+
+// Run records the state of a run, it is safe for concurrent use so the state
+// can be served while the run is in progress.
+type Run struct {
+	mu    sync.Mutex
+	state RunState
+	index map[string]int
+}
+
+// State returns a snapshot of the run.
+func (r *Run) State() RunState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.state
+	state.Steps = slices.Clone(r.state.Steps)
+	return state
+}
+
+func (r *Run) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.state.Status = StatusRunning
+	r.state.StartedAt = &now
+}
+
+func (r *Run) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.state.FinishedAt = &now
+	r.state.Status = StatusSucceeded
+	if err != nil {
+		r.state.Status = StatusFailed
+		r.state.Error = err.Error()
+	}
+}
+
+func (r *Run) status(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.state.Steps[r.index[name]].Status
+}
+
+func (r *Run) setStatus(name, status string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	step := &r.state.Steps[r.index[name]]
+	step.Status = status
+	switch status {
+	case StatusRunning:
+		step.StartedAt = &now
+	case StatusSucceeded, StatusFailed:
+		step.FinishedAt = &now
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+}
+
+type stepKey struct{}
+
+// stepContext carries the inputs and the output of a running step.
+type stepContext struct {
+	inputs map[string]any
+	output any
+}
+
+// Input returns the output of the dependency of the running step.
+func Input(ctx context.Context, dependency string) (any, bool) {
+	sc, ok := ctx.Value(stepKey{}).(*stepContext)
+	if !ok {
+		return nil, false
+	}
+	output, ok := sc.inputs[dependency]
+	return output, ok
+}
+
+// SetOutput sets the output of the running step, it is passed to the steps
+// depending on it. It does nothing outside a workflow.
+func SetOutput(ctx context.Context, output any) {
+	if sc, ok := ctx.Value(stepKey{}).(*stepContext); ok {
+		sc.output = output
+	}
+}
@@ -0,0 +1,121 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+
+	d, err := New(
+		Step{Name: "c", DependsOn: []string{"a", "b"}, Run: noop},
+		Step{Name: "a", Run: noop},
+		Step{Name: "b", DependsOn: []string{"a"}, Run: noop},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, d.Order())
+	assert.Equal(t, []string{"a", "b"}, d.DependsOn("c"))
+
+	_, err = New(Step{Name: "a", DependsOn: []string{"missing"}, Run: noop})
+	assert.ErrorIs(t, err, ErrUnknownStep)
+
+	_, err = New(Step{Name: "a", Run: noop}, Step{Name: "a", Run: noop})
+	assert.ErrorIs(t, err, ErrDuplicateStep)
+
+	_, err = New(
+		Step{Name: "a", DependsOn: []string{"b"}, Run: noop},
+		Step{Name: "b", DependsOn: []string{"a"}, Run: noop},
+		Step{Name: "c", Run: noop},
+	)
+	assert.ErrorIs(t, err, ErrCycle)
+}
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, name)
+	}
+
+	d, err := New(
+		Step{Name: "extract", Run: func(ctx context.Context) error {
+			record("extract")
+			SetOutput(ctx, 2)
+			return nil
+		}},
+		Step{Name: "transform", DependsOn: []string{"extract"}, Run: func(ctx context.Context) error {
+			record("transform")
+			input, ok := Input(ctx, "extract")
+			require.True(t, ok)
+			SetOutput(ctx, input.(int)*10)
+			return nil
+		}},
+		Step{Name: "load", DependsOn: []string{"transform"}, Run: func(ctx context.Context) error {
+			record("load")
+			input, _ := Input(ctx, "transform")
+			assert.Equal(t, 20, input)
+			return nil
+		}},
+	)
+	require.NoError(t, err)
+
+	run := d.NewRun()
+	require.NoError(t, d.Run(context.Background(), run))
+	assert.Equal(t, []string{"extract", "transform", "load"}, ran)
+
+	state := run.State()
+	assert.Equal(t, StatusSucceeded, state.Status)
+	for _, step := range state.Steps {
+		assert.Equal(t, StatusSucceeded, step.Status, step.Name)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	failed := errors.New("failed")
+	var independent bool
+
+	d, err := New(
+		Step{Name: "a", Run: func(ctx context.Context) error { return failed }},
+		Step{Name: "b", DependsOn: []string{"a"}, Run: func(ctx context.Context) error {
+			t.Error("b must be skipped")
+			return nil
+		}},
+		Step{Name: "c", DependsOn: []string{"b"}, Run: func(ctx context.Context) error {
+			t.Error("c must be skipped")
+			return nil
+		}},
+		Step{Name: "d", Run: func(ctx context.Context) error {
+			independent = true
+			return nil
+		}},
+		Step{Name: "e", DependsOn: []string{"d"}, Run: func(ctx context.Context) error { panic("boom") }},
+	)
+	require.NoError(t, err)
+
+	run := d.NewRun()
+	err = d.Run(context.Background(), run)
+	assert.ErrorIs(t, err, failed)
+	assert.ErrorContains(t, err, "panic: boom")
+	assert.True(t, independent)
+
+	statuses := make(map[string]string)
+	for _, step := range run.State().Steps {
+		statuses[step.Name] = step.Status
+	}
+	assert.Equal(t, map[string]string{
+		"a": StatusFailed,
+		"b": StatusSkipped,
+		"c": StatusSkipped,
+		"d": StatusSucceeded,
+		"e": StatusFailed,
+	}, statuses)
+	assert.Equal(t, StatusFailed, run.State().Status)
+}
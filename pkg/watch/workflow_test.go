@@ -0,0 +1,77 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/registry"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/state"
+	"github.com/ydcloud-dy/publicPkg/pkg/watch/workflow"
+)
+
+// stepWatcher is a step of a workflow for tests.
+type stepWatcher struct {
+	dependsOn []string
+	run       func(ctx context.Context) error
+}
+
+func (w *stepWatcher) Run(ctx context.Context) error { return w.run(ctx) }
+
+func (w *stepWatcher) DependsOn() []string { return w.dependsOn }
+
+func TestWorkflow(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWatch(state.NewMemoryStore())
+
+	var loaded any
+	watchers := map[string]any{
+		"extract": &stepWatcher{run: func(ctx context.Context) error {
+			workflow.SetOutput(ctx, "rows")
+			return nil
+		}},
+		"load": &stepWatcher{dependsOn: []string{"extract"}, run: func(ctx context.Context) error {
+			loaded, _ = workflow.Input(ctx, "extract")
+			return nil
+		}},
+	}
+	require.NoError(t, w.addWorkflow("etl", registry.Workflow{Spec: "@every 1h", Steps: []string{"load", "extract"}}, watchers))
+	assert.True(t, w.jm.JobExists("etl"))
+	assert.False(t, w.jm.JobExists("extract"))
+
+	w.jobs["etl"].Run()
+	assert.Equal(t, "rows", loaded)
+	assert.Equal(t, "succeeded", lastRun(t, w, "etl").Outcome)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/workflows/{name}", w.workflowHandler).Methods(http.MethodGet)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/workflows/etl", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"steps":[{"name":"extract"},{"name":"load","dependsOn":["extract"]}]`)
+	assert.Contains(t, rec.Body.String(), `"status":"succeeded"`)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/workflows/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The steps downstream of a failed step are skipped.
+	watchers["extract"] = &stepWatcher{run: func(ctx context.Context) error { return errors.New("failed") }}
+	require.NoError(t, w.addWorkflow("broken", registry.Workflow{Steps: []string{"extract", "load"}}, watchers))
+	w.jobs["broken"].Run()
+	assert.Equal(t, "failed", lastRun(t, w, "broken").Outcome)
+
+	status, err := w.workflowStatus(ctx, "broken")
+	require.NoError(t, err)
+	assert.Equal(t, workflow.StatusSkipped, status.Run.Steps[1].Status)
+
+	err = w.addWorkflow("missing", registry.Workflow{Steps: []string{"load"}}, watchers)
+	assert.ErrorIs(t, err, workflow.ErrUnknownStep)
+}